@@ -5,4 +5,7 @@ go 1.18
 require (
 	github.com/gorilla/mux v1.8.0
 	github.com/gorilla/websocket v1.5.0
+	github.com/icheka/sonar-lang/sonar-lang v0.0.0
 )
+
+replace github.com/icheka/sonar-lang/sonar-lang => ../sonar-lang
@@ -0,0 +1,81 @@
+package lsp
+
+import (
+	"net/url"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/lexer"
+	"github.com/icheka/sonar-lang/sonar-lang/parser"
+)
+
+const diagnosticSource = "sonar"
+
+// Diagnose parses source and returns one diagnostic per syntax error. The
+// result is never nil, so that publishing it clears stale squiggles.
+func Diagnose(uri string, source string) []Diagnostic {
+	p := parser.New(lexer.New(source, &lexer.LexerOptions{Path: uriToPath(uri)}))
+	p.ParseProgram()
+
+	diagnostics := []Diagnostic{}
+	for _, err := range p.Errors() {
+		diagnostics = append(diagnostics, toDiagnostic(err))
+	}
+	return diagnostics
+}
+
+// toDiagnostic converts the one-based line and column of err into an LSP
+// range covering the offending character.
+func toDiagnostic(err errors.Error) Diagnostic {
+	start := Position{Line: clamp(err.Line - 1), Character: clamp(err.Column - 1)}
+	end := Position{Line: start.Line, Character: start.Character + 1}
+	if offset := err.LineTextTokenPosition; len(err.LineText) > 0 && offset <= len(err.LineText) {
+		// columns count bytes, LSP characters count UTF-16 code units
+		start.Character = utf16Length(err.LineText[:offset])
+		end.Character = start.Character + utf16Length(firstRune(err.LineText[offset:]))
+	}
+
+	message := err.Message
+	if len(err.Hint) > 0 {
+		message += "\n" + err.Hint
+	}
+
+	return Diagnostic{
+		Range:    Range{Start: start, End: end},
+		Severity: SeverityError,
+		Code:     string(err.Type),
+		Source:   diagnosticSource,
+		Message:  message,
+	}
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return u.Path
+}
+
+// utf16Length returns the number of UTF-16 code units in s
+func utf16Length(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// firstRune returns the first character of s, or a space if s is empty so
+// that a range starting at its end still covers one character
+func firstRune(s string) string {
+	if len(s) == 0 {
+		return " "
+	}
+	_, size := utf8.DecodeRuneInString(s)
+	return s[:size]
+}
+
+func clamp(n int) int {
+	if n < 0 {
+		return 0
+	}
+	return n
+}
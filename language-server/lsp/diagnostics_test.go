@@ -0,0 +1,28 @@
+package lsp
+
+import "testing"
+
+func TestDiagnoseCountsUTF16CodeUnits(t *testing.T) {
+	tests := []struct {
+		source        string
+		expectedStart int
+		expectedEnd   int
+	}{
+		{`let x = "a"; let = 1`, 17, 18},
+		{`let é = "é"; let = 1`, 17, 18},
+		{`let s = "😀"; let = 1`, 18, 19},
+		{`let s = "😀"; 😀`, 14, 16},
+	}
+
+	for _, tt := range tests {
+		diagnostics := Diagnose("file:///tmp/main.sn", tt.source)
+		if len(diagnostics) == 0 {
+			t.Errorf("%q: expected a diagnostic", tt.source)
+			continue
+		}
+		r := diagnostics[0].Range
+		if r.Start.Character != tt.expectedStart || r.End.Character != tt.expectedEnd {
+			t.Errorf("%q: expected characters %d-%d, got=%d-%d (%s)", tt.source, tt.expectedStart, tt.expectedEnd, r.Start.Character, r.End.Character, diagnostics[0].Message)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// JSON-RPC error codes used by the server
+const (
+	ParseError           = -32700
+	InvalidRequest       = -32600
+	MethodNotFound       = -32601
+	InvalidParams        = -32602
+	ServerNotInitialized = -32002
+)
+
+// Message is a JSON-RPC 2.0 request, response or notification. Requests have
+// an ID and a Method, notifications only a Method, responses only an ID.
+type Message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *ResponseError   `json:"error,omitempty"`
+}
+
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (m *Message) IsNotification() bool { return m.ID == nil }
+
+// MalformedMessageError is returned by ReadMessage for a message that is
+// framed correctly but whose body is not JSON. The messages after it can
+// still be read.
+type MalformedMessageError struct {
+	Err error
+}
+
+func (e *MalformedMessageError) Error() string { return "malformed message: " + e.Err.Error() }
+
+func (e *MalformedMessageError) Unwrap() error { return e.Err }
+
+// ReadMessage reads one message framed by a Content-Length header, as
+// described by the base protocol of the LSP specification. A body that is
+// not JSON gives a *MalformedMessageError; any other error means the input
+// cannot be read any further.
+func ReadMessage(r *bufio.Reader) (*Message, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length header: %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	var msg Message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, &MalformedMessageError{Err: err}
+	}
+	return &msg, nil
+}
+
+// WriteMessage writes msg to w, preceded by its Content-Length header.
+func WriteMessage(w io.Writer, msg *Message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
@@ -0,0 +1,167 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"sync"
+)
+
+const serverName = "sonar-language-server"
+
+// Server is a language server that publishes parse errors as diagnostics.
+// Documents are synchronised in full on every change.
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	mu          sync.Mutex // guards writes to out
+	documents   map[string]string
+	initialized bool
+	shutdown    bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: make(map[string]string),
+	}
+}
+
+// Serve handles messages until the client sends `exit` or closes the input.
+// Messages that are not JSON are answered with a ParseError; only input
+// that is not framed as the protocol says stops it.
+func (s *Server) Serve() error {
+	for {
+		msg, err := ReadMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		var malformed *MalformedMessageError
+		if errors.As(err, &malformed) {
+			log.Print(err)
+			// the ID of a message that cannot be parsed is unknown, so the
+			// error is sent with a null one
+			id := json.RawMessage("null")
+			s.write(&Message{ID: &id, Error: &ResponseError{Code: ParseError, Message: malformed.Err.Error()}})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			return nil
+		}
+		s.handle(msg)
+	}
+}
+
+// ShutdownReceived reports whether the client asked the server to shut down
+// before exiting; the process should exit with 1 if it did not.
+func (s *Server) ShutdownReceived() bool { return s.shutdown }
+
+func (s *Server) handle(msg *Message) {
+	if !s.initialized && msg.Method != "initialize" {
+		if !msg.IsNotification() {
+			s.replyError(msg, ServerNotInitialized, "server not initialized")
+		}
+		return
+	}
+
+	switch msg.Method {
+	case "initialize":
+		s.initialized = true
+		s.reply(msg, InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync: TextDocumentSyncOptions{
+					OpenClose: true,
+					Change:    TextDocumentSyncKindFull,
+				},
+			},
+			ServerInfo: ServerInfo{Name: serverName},
+		})
+
+	case "initialized":
+
+	case "shutdown":
+		s.shutdown = true
+		s.reply(msg, nil)
+
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if s.decode(msg, &params) {
+			s.update(params.TextDocument.URI, params.TextDocument.Text, &params.TextDocument.Version)
+		}
+
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if s.decode(msg, &params) && len(params.ContentChanges) > 0 {
+			text := params.ContentChanges[len(params.ContentChanges)-1].Text
+			s.update(params.TextDocument.URI, text, &params.TextDocument.Version)
+		}
+
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if s.decode(msg, &params) {
+			delete(s.documents, params.TextDocument.URI)
+			s.publishDiagnostics(params.TextDocument.URI, nil, []Diagnostic{})
+		}
+
+	default:
+		if !msg.IsNotification() {
+			s.replyError(msg, MethodNotFound, "method not found: "+msg.Method)
+		}
+	}
+}
+
+func (s *Server) update(uri string, text string, version *int) {
+	s.documents[uri] = text
+	s.publishDiagnostics(uri, version, Diagnose(uri, text))
+}
+
+func (s *Server) publishDiagnostics(uri string, version *int, diagnostics []Diagnostic) {
+	params, _ := json.Marshal(PublishDiagnosticsParams{
+		URI:         uri,
+		Version:     version,
+		Diagnostics: diagnostics,
+	})
+	s.write(&Message{Method: "textDocument/publishDiagnostics", Params: params})
+}
+
+// decode unmarshals the params of msg into v, replying with an error to
+// requests whose params are malformed
+func (s *Server) decode(msg *Message, v interface{}) bool {
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		if !msg.IsNotification() {
+			s.replyError(msg, InvalidParams, err.Error())
+		}
+		log.Printf("invalid params for %s: %s", msg.Method, err)
+		return false
+	}
+	return true
+}
+
+func (s *Server) reply(req *Message, result interface{}) {
+	if result == nil {
+		// `result` is required in successful responses, even when it is null
+		result = json.RawMessage("null")
+	}
+	s.write(&Message{ID: req.ID, Result: result})
+}
+
+func (s *Server) replyError(req *Message, code int, message string) {
+	s.write(&Message{ID: req.ID, Error: &ResponseError{Code: code, Message: message}})
+}
+
+func (s *Server) write(msg *Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := WriteMessage(s.out, msg); err != nil {
+		log.Printf("An error occurred while writing a message (%+v): %s", msg, err)
+	}
+}
@@ -0,0 +1,158 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func writeRequest(t *testing.T, buf *bytes.Buffer, id int, method string, params interface{}) {
+	msg := &Message{Method: method}
+	if id > 0 {
+		raw := json.RawMessage(fmt.Sprint(id))
+		msg.ID = &raw
+	}
+	if params != nil {
+		p, err := json.Marshal(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg.Params = p
+	}
+	if err := WriteMessage(buf, msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readResponses(t *testing.T, out *bytes.Buffer) []*Message {
+	r := bufio.NewReader(out)
+	messages := []*Message{}
+	for r.Buffered() > 0 || out.Len() > 0 {
+		msg, err := ReadMessage(r)
+		if err != nil {
+			t.Fatalf("could not read message: %s", err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+func TestServerPublishesDiagnostics(t *testing.T) {
+	var in, out bytes.Buffer
+	uri := "file:///tmp/main.sn"
+
+	writeRequest(t, &in, 1, "initialize", InitializeParams{})
+	writeRequest(t, &in, 0, "initialized", struct{}{})
+	writeRequest(t, &in, 0, "textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "sonar", Version: 1, Text: "let x = 1;"},
+	})
+	writeRequest(t, &in, 0, "textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let x = 1;\nlet = 5;"}},
+	})
+	writeRequest(t, &in, 2, "shutdown", nil)
+	writeRequest(t, &in, 0, "exit", nil)
+
+	s := NewServer(&in, &out)
+	if err := s.Serve(); err != nil {
+		t.Fatalf("Serve returned an error: %s", err)
+	}
+	if !s.ShutdownReceived() {
+		t.Errorf("expected shutdown to have been received")
+	}
+
+	messages := readResponses(t, &out)
+	if len(messages) != 4 {
+		t.Fatalf("expected 4 messages, got=%d", len(messages))
+	}
+
+	var result InitializeResult
+	raw, _ := json.Marshal(messages[0].Result)
+	if err := json.Unmarshal(raw, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Capabilities.TextDocumentSync.Change != TextDocumentSyncKindFull {
+		t.Errorf("expected full document sync, got=%d", result.Capabilities.TextDocumentSync.Change)
+	}
+
+	tests := []struct {
+		message         *Message
+		expectedVersion int
+		expectedLines   []int
+	}{
+		{messages[1], 1, []int{}},
+		{messages[2], 2, []int{1}},
+	}
+
+	for _, tt := range tests {
+		if tt.message.Method != "textDocument/publishDiagnostics" {
+			t.Fatalf("expected publishDiagnostics notification, got=%q", tt.message.Method)
+		}
+
+		var params PublishDiagnosticsParams
+		if err := json.Unmarshal(tt.message.Params, &params); err != nil {
+			t.Fatal(err)
+		}
+		if params.URI != uri || params.Version == nil || *params.Version != tt.expectedVersion {
+			t.Errorf("diagnostics published for the wrong document: %+v", params)
+		}
+		if len(params.Diagnostics) != len(tt.expectedLines) {
+			t.Fatalf("expected %d diagnostics, got=%+v", len(tt.expectedLines), params.Diagnostics)
+		}
+		for i, d := range params.Diagnostics {
+			if d.Range.Start.Line != tt.expectedLines[i] {
+				t.Errorf("expected diagnostic on line %d, got=%d", tt.expectedLines[i], d.Range.Start.Line)
+			}
+			if d.Severity != SeverityError || d.Source != diagnosticSource {
+				t.Errorf("unexpected diagnostic %+v", d)
+			}
+		}
+	}
+
+	if messages[3].Error != nil || string(*messages[3].ID) != "2" {
+		t.Errorf("expected a successful response to shutdown, got=%+v", messages[3])
+	}
+}
+
+func TestServerRejectsRequestsBeforeInitialize(t *testing.T) {
+	var in, out bytes.Buffer
+	writeRequest(t, &in, 1, "textDocument/hover", struct{}{})
+
+	if err := NewServer(&in, &out).Serve(); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := readResponses(t, &out)
+	if len(messages) != 1 || messages[0].Error == nil || messages[0].Error.Code != ServerNotInitialized {
+		t.Fatalf("expected a ServerNotInitialized error, got=%+v", messages)
+	}
+}
+
+func TestServerRecoversFromMalformedMessages(t *testing.T) {
+	var in, out bytes.Buffer
+	body := `{"jsonrpc": "2.0", "id": 1, "method": `
+	fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	writeRequest(t, &in, 2, "initialize", InitializeParams{})
+	in.WriteString("Content-Length: many\r\n\r\n{}")
+
+	err := NewServer(&in, &out).Serve()
+	if err == nil {
+		t.Errorf("expected a broken header to stop the server")
+	}
+
+	if !bytes.Contains(out.Bytes(), []byte(`"id":null`)) {
+		t.Errorf("expected the ParseError to be sent with a null ID, got=%q", out.String())
+	}
+	messages := readResponses(t, &out)
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got=%+v", messages)
+	}
+	if messages[0].Error == nil || messages[0].Error.Code != ParseError {
+		t.Errorf("expected a ParseError, got=%+v", messages[0])
+	}
+	if messages[1].Error != nil || string(*messages[1].ID) != "2" {
+		t.Errorf("expected the next request to be answered, got=%+v", messages[1])
+	}
+}
@@ -1,7 +1,27 @@
 package main
 
-import "language-server/server"
+import (
+	"flag"
+	"language-server/lsp"
+	"language-server/server"
+	"log"
+	"os"
+)
 
 func main() {
+	stdio := flag.Bool("stdio", false, "speak the Language Server Protocol over stdin and stdout")
+	flag.Parse()
+
+	if *stdio {
+		s := lsp.NewServer(os.Stdin, os.Stdout)
+		if err := s.Serve(); err != nil {
+			log.Fatal(err)
+		}
+		if !s.ShutdownReceived() {
+			os.Exit(1)
+		}
+		return
+	}
+
 	server.Start()
 }
@@ -2,20 +2,32 @@ package utils
 
 import (
 	"bytes"
-	"log"
-	"os/exec"
+	"context"
+	"time"
+
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+	"github.com/icheka/sonar-lang/sonar-lang/sonar"
 )
 
-func Evaluate(input string) (string, string) {
-	cmd := exec.Command("sonar-lang", "-text", input)
+// limits bounds the resources of the snippets sent by the code editor, which
+// cannot be trusted to terminate
+var limits = object.Limits{
+	Timeout:        5 * time.Second,
+	MaxSteps:       10_000_000,
+	MaxCallDepth:   1_000,
+	MaxElements:    10_000_000,
+	MaxStringBytes: 64 << 20,
+}
 
+func Evaluate(input string) (stdout string, stderr string) {
 	var outBuf, errBuf bytes.Buffer
-	cmd.Stderr = &errBuf
-	cmd.Stdout = &outBuf
 
-	if err := cmd.Run(); err != nil {
-		log.Println(err)
-	}
+	interpreter := sonar.New()
+	interpreter.Stdout = &outBuf
+	interpreter.Stderr = &errBuf
+	interpreter.Limits = limits
+
+	interpreter.Run(context.Background(), input)
 
 	return outBuf.String(), errBuf.String()
 }
@@ -17,3 +17,16 @@ func TestEvaluate(t *testing.T) {
 		t.Error("expected length of stdout to be > 0, got=0")
 	}
 }
+
+func TestEvaluateLimits(t *testing.T) {
+	for _, input := range []string{
+		`while (true) {}`,
+		`let f = func() { f() }; f()`,
+		`let s = "a"; while (true) { s += s }`,
+	} {
+		_, stdErr := Evaluate(input)
+		if !strings.Contains(stdErr, "LimitError") {
+			t.Errorf("expected %q to exceed a limit, got stderr=%q", input, stdErr)
+		}
+	}
+}
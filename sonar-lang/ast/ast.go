@@ -11,6 +11,7 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Span() token.Span // the source code the node was parsed from
 }
 
 // All statement nodes implement this
@@ -37,6 +38,13 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Span() token.Span {
+	if len(p.Statements) == 0 {
+		return token.Span{}
+	}
+	return p.Statements[0].Span().To(p.Statements[len(p.Statements)-1].Span())
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -49,13 +57,15 @@ func (p *Program) String() string {
 
 // Statements
 type LetStatement struct {
-	Token     token.Token // the token.LET token
-	Name      *Identifier
-	Value     Expression
-	TokenInfo interface{}
+	Token      token.Token // the token.LET or token.CONST token
+	Name       *Identifier
+	Value      Expression
+	Constant   bool // declared with const, so Name cannot be assigned to
+	SourceSpan token.Span
 }
 
 func (ls *LetStatement) statementNode()       {}
+func (ls *LetStatement) Span() token.Span     { return ls.SourceSpan }
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
@@ -76,10 +86,11 @@ func (ls *LetStatement) String() string {
 type ReturnStatement struct {
 	Token       token.Token // the 'return' token
 	ReturnValue Expression
-	TokenInfo   interface{}
+	SourceSpan  token.Span
 }
 
 func (rs *ReturnStatement) statementNode()       {}
+func (rs *ReturnStatement) Span() token.Span     { return rs.SourceSpan }
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
@@ -98,10 +109,11 @@ func (rs *ReturnStatement) String() string {
 type ExpressionStatement struct {
 	Token      token.Token // the first token of the expression
 	Expression Expression
-	TokenInfo  interface{}
+	SourceSpan token.Span
 }
 
 func (es *ExpressionStatement) statementNode()       {}
+func (es *ExpressionStatement) Span() token.Span     { return es.SourceSpan }
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
@@ -113,10 +125,11 @@ func (es *ExpressionStatement) String() string {
 type BlockStatement struct {
 	Token      token.Token // the { token
 	Statements []Statement
-	TokenInfo  interface{}
+	SourceSpan token.Span
 }
 
 func (bs *BlockStatement) statementNode()       {}
+func (bs *BlockStatement) Span() token.Span     { return bs.SourceSpan }
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
@@ -132,10 +145,11 @@ type WhileStatement struct {
 	Token       token.Token // the 'while' token
 	Condition   Expression
 	Consequence *BlockStatement
-	TokenInfo   interface{}
+	SourceSpan  token.Span
 }
 
 func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) Span() token.Span     { return ws.SourceSpan }
 func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
 func (ws *WhileStatement) String() string {
 	var out bytes.Buffer
@@ -157,10 +171,11 @@ type ForStatement struct {
 	Operator    token.Token // the infix operator used. For now, and maybe forever, it will always be 'in'
 	Iterable    Expression
 	Consequence *BlockStatement
-	TokenInfo   interface{}
+	SourceSpan  token.Span
 }
 
 func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) Span() token.Span     { return fs.SourceSpan }
 func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
 func (fs *ForStatement) String() string {
 	var out bytes.Buffer
@@ -180,74 +195,189 @@ func (fs *ForStatement) String() string {
 }
 
 type BreakStatement struct {
-	Token     token.Token // the 'break' token
-	TokenInfo interface{}
+	Token      token.Token // the 'break' token
+	SourceSpan token.Span
 }
 
 func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) Span() token.Span     { return bs.SourceSpan }
 func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
 func (bs *BreakStatement) String() string       { return bs.TokenLiteral() }
 
 type ContinueStatement struct {
-	Token     token.Token // the 'continue' token
-	TokenInfo interface{}
+	Token      token.Token // the 'continue' token
+	SourceSpan token.Span
 }
 
 func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) Span() token.Span     { return cs.SourceSpan }
 func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
 func (cs *ContinueStatement) String() string       { return cs.TokenLiteral() }
 
+type ThrowStatement struct {
+	Token      token.Token // the 'throw' token
+	Value      Expression
+	SourceSpan token.Span
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) Span() token.Span     { return ts.SourceSpan }
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ts.TokenLiteral() + " ")
+	if ts.Value != nil {
+		out.WriteString(ts.Value.String())
+	}
+	out.WriteString(";")
+
+	return out.String()
+}
+
+type TryStatement struct {
+	Token     token.Token // the 'try' token
+	Block     *BlockStatement
+	Parameter *Identifier // the 'e' in 'catch (e)'; nil if the error is not bound
+	// at least one of Catch and Finally is set
+	Catch      *BlockStatement
+	Finally    *BlockStatement
+	SourceSpan token.Span
+}
+
+func (ts *TryStatement) statementNode()       {}
+func (ts *TryStatement) Span() token.Span     { return ts.SourceSpan }
+func (ts *TryStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *TryStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ts.TokenLiteral())
+	out.WriteString(" {")
+	out.WriteString(ts.Block.String())
+	out.WriteString("}")
+
+	if ts.Catch != nil {
+		out.WriteString(" catch ")
+		if ts.Parameter != nil {
+			out.WriteString("(" + ts.Parameter.String() + ") ")
+		}
+		out.WriteString("{")
+		out.WriteString(ts.Catch.String())
+		out.WriteString("}")
+	}
+
+	if ts.Finally != nil {
+		out.WriteString(" finally {")
+		out.WriteString(ts.Finally.String())
+		out.WriteString("}")
+	}
+
+	return out.String()
+}
+
+type ImportStatement struct {
+	Token token.Token // the 'import' token
+	Path  *StringLiteral
+	// `import "./lib/math" as m` binds the module to Alias, which otherwise
+	// defaults to the last element of Path
+	Alias *Identifier
+	// `import { add, sub } from "./lib/math"` binds the named exports instead
+	Names      []*Identifier
+	SourceSpan token.Span
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) Span() token.Span     { return is.SourceSpan }
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(is.TokenLiteral() + " ")
+	if is.Names != nil {
+		names := []string{}
+		for _, n := range is.Names {
+			names = append(names, n.String())
+		}
+		out.WriteString("{ " + strings.Join(names, ", ") + " } from ")
+	}
+	out.WriteString(`"` + is.Path.Value + `"`)
+	if is.Alias != nil {
+		out.WriteString(" as " + is.Alias.String())
+	}
+	out.WriteString(";")
+
+	return out.String()
+}
+
+type ExportStatement struct {
+	Token      token.Token // the 'export' token
+	Statement  *LetStatement
+	SourceSpan token.Span
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) Span() token.Span     { return es.SourceSpan }
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Statement.String()
+}
+
 // *****************
 //  * Expressions *
 // *****************
 type Identifier struct {
-	Token     token.Token // the token.IDENT token
-	Value     string
-	TokenInfo interface{}
+	Token      token.Token // the token.IDENT token
+	Value      string
+	SourceSpan token.Span
 }
 
 func (i *Identifier) expressionNode()      {}
+func (i *Identifier) Span() token.Span     { return i.SourceSpan }
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
 func (i *Identifier) String() string       { return i.Value }
 
 type Boolean struct {
-	Token     token.Token
-	Value     bool
-	TokenInfo interface{}
+	Token      token.Token
+	Value      bool
+	SourceSpan token.Span
 }
 
 func (b *Boolean) expressionNode()      {}
+func (b *Boolean) Span() token.Span     { return b.SourceSpan }
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
 type IntegerLiteral struct {
-	Token     token.Token
-	Value     int64
-	TokenInfo interface{}
+	Token      token.Token
+	Value      int64
+	SourceSpan token.Span
 }
 
 func (il *IntegerLiteral) expressionNode()      {}
+func (il *IntegerLiteral) Span() token.Span     { return il.SourceSpan }
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
 type FloatLiteral struct {
-	Token     token.Token
-	Value     float64
-	TokenInfo interface{}
+	Token      token.Token
+	Value      float64
+	SourceSpan token.Span
 }
 
 func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) Span() token.Span     { return fl.SourceSpan }
 func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
 func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
 
 type PrefixExpression struct {
-	Token     token.Token // The prefix token, e.g. !
-	Operator  string
-	Right     Expression
-	TokenInfo interface{}
+	Token      token.Token // The prefix token, e.g. !
+	Operator   string
+	Right      Expression
+	SourceSpan token.Span
 }
 
 func (pe *PrefixExpression) expressionNode()      {}
+func (pe *PrefixExpression) Span() token.Span     { return pe.SourceSpan }
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
@@ -261,14 +391,15 @@ func (pe *PrefixExpression) String() string {
 }
 
 type InfixExpression struct {
-	Token     token.Token // The operator token, e.g. +
-	Left      Expression
-	Operator  string
-	Right     Expression
-	TokenInfo interface{}
+	Token      token.Token // The operator token, e.g. +
+	Left       Expression
+	Operator   string
+	Right      Expression
+	SourceSpan token.Span
 }
 
 func (oe *InfixExpression) expressionNode()      {}
+func (oe *InfixExpression) Span() token.Span     { return oe.SourceSpan }
 func (oe *InfixExpression) TokenLiteral() string { return oe.Token.Literal }
 func (oe *InfixExpression) String() string {
 	var out bytes.Buffer
@@ -282,13 +413,39 @@ func (oe *InfixExpression) String() string {
 	return out.String()
 }
 
+// LogicalExpression is `left and right` or `left or right`. Unlike an
+// InfixExpression, right is only evaluated if left does not decide the result.
+type LogicalExpression struct {
+	Token      token.Token // The and, or or ?? token
+	Left       Expression
+	Operator   string
+	Right      Expression
+	SourceSpan token.Span
+}
+
+func (le *LogicalExpression) expressionNode()      {}
+func (le *LogicalExpression) Span() token.Span     { return le.SourceSpan }
+func (le *LogicalExpression) TokenLiteral() string { return le.Token.Literal }
+func (le *LogicalExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(le.Left.String())
+	out.WriteString(" " + le.Operator + " ")
+	out.WriteString(le.Right.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
 type PostfixExpression struct {
-	Token     token.Token
-	Operator  string
-	TokenInfo interface{}
+	Token      token.Token
+	Operator   string
+	SourceSpan token.Span
 }
 
 func (oe *PostfixExpression) expressionNode()      {}
+func (oe *PostfixExpression) Span() token.Span     { return oe.SourceSpan }
 func (oe *PostfixExpression) TokenLiteral() string { return oe.Token.Literal }
 func (oe *PostfixExpression) String() string {
 	var out bytes.Buffer
@@ -306,10 +463,11 @@ type IfExpression struct {
 	Condition   Expression
 	Consequence *BlockStatement
 	Alternative *BlockStatement
-	TokenInfo   interface{}
+	SourceSpan  token.Span
 }
 
 func (ie *IfExpression) expressionNode()      {}
+func (ie *IfExpression) Span() token.Span     { return ie.SourceSpan }
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
@@ -331,10 +489,11 @@ type FunctionLiteral struct {
 	Token      token.Token // The 'fn' token
 	Parameters []*Identifier
 	Body       *BlockStatement
-	TokenInfo  interface{}
+	SourceSpan token.Span
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
+func (fl *FunctionLiteral) Span() token.Span     { return fl.SourceSpan }
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
@@ -354,13 +513,16 @@ func (fl *FunctionLiteral) String() string {
 }
 
 type CallExpression struct {
-	Token     token.Token // The '(' token
-	Function  Expression  // Identifier or FunctionLiteral
-	Arguments []Expression
-	TokenInfo interface{}
+	Token      token.Token // The '(' token
+	Function   Expression  // Identifier or FunctionLiteral
+	Arguments  []Expression
+	Optional   bool // f?.(), which is null if f is
+	Grouped    bool // (f()), see Grouped
+	SourceSpan token.Span
 }
 
 func (ce *CallExpression) expressionNode()      {}
+func (ce *CallExpression) Span() token.Span     { return ce.SourceSpan }
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
@@ -371,6 +533,9 @@ func (ce *CallExpression) String() string {
 	}
 
 	out.WriteString(ce.Function.String())
+	if ce.Optional {
+		out.WriteString("?.")
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, ", "))
 	out.WriteString(")")
@@ -379,22 +544,52 @@ func (ce *CallExpression) String() string {
 }
 
 type StringLiteral struct {
-	Token     token.Token
-	Value     string
-	TokenInfo interface{}
+	Token      token.Token
+	Value      string
+	SourceSpan token.Span
 }
 
 func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) Span() token.Span     { return sl.SourceSpan }
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
+// ConcatExpression joins the values of its parts into a string. The parser
+// builds one from each string with interpolations: "a ${b}" has the parts
+// StringLiteral("a ") and Identifier(b).
+type ConcatExpression struct {
+	Token      token.Token // the TEMPLATE_HEAD token
+	Parts      []Expression
+	SourceSpan token.Span
+}
+
+func (ce *ConcatExpression) expressionNode()      {}
+func (ce *ConcatExpression) Span() token.Span     { return ce.SourceSpan }
+func (ce *ConcatExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *ConcatExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("\"")
+	for _, part := range ce.Parts {
+		if str, ok := part.(*StringLiteral); ok {
+			out.WriteString(str.Value)
+		} else {
+			out.WriteString("${" + part.String() + "}")
+		}
+	}
+	out.WriteString("\"")
+
+	return out.String()
+}
+
 type ArrayLiteral struct {
-	Token     token.Token // the '[' token
-	Elements  []Expression
-	TokenInfo interface{}
+	Token      token.Token // the '[' token
+	Elements   []Expression
+	SourceSpan token.Span
 }
 
 func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) Span() token.Span     { return al.SourceSpan }
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
@@ -412,19 +607,35 @@ func (al *ArrayLiteral) String() string {
 }
 
 type IndexExpression struct {
-	Token     token.Token // The [ token
-	Left      Expression
-	Index     Expression
-	TokenInfo interface{}
+	Token      token.Token // The [ token, or the . or ?. of left.name
+	Left       Expression
+	Index      Expression
+	Optional   bool // left?.[index] or left?.name, which are null if left is
+	Grouped    bool // (left[index]), see Grouped
+	SourceSpan token.Span
 }
 
 func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) Span() token.Span     { return ie.SourceSpan }
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
 	out.WriteString("(")
 	out.WriteString(ie.Left.String())
+	switch ie.Token.Type {
+	case token.FULLSTOP:
+		// left.name
+		out.WriteString("." + ie.Index.String() + ")")
+		return out.String()
+	case token.OPTIONAL_CHAIN:
+		// left?.name
+		out.WriteString("?." + ie.Index.String() + ")")
+		return out.String()
+	}
+	if ie.Optional {
+		out.WriteString("?.")
+	}
 	out.WriteString("[")
 	out.WriteString(ie.Index.String())
 	out.WriteString("])")
@@ -432,20 +643,39 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// Grouped reports whether exp is an index expression or call written in
+// parentheses, which end the optional chain it is part of: (a?.b).c is an
+// error if a is null, where a?.b.c is null
+func Grouped(exp Expression) bool {
+	switch exp := exp.(type) {
+	case *IndexExpression:
+		return exp.Grouped
+	case *CallExpression:
+		return exp.Grouped
+	}
+	return false
+}
+
 type HashLiteral struct {
-	Token     token.Token // the '{' token
-	Pairs     map[Expression]Expression
-	TokenInfo interface{}
+	Token      token.Token       // the '{' token
+	Pairs      []HashLiteralPair // in source order
+	SourceSpan token.Span
+}
+
+type HashLiteralPair struct {
+	Key   Expression
+	Value Expression
 }
 
 func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) Span() token.Span     { return hl.SourceSpan }
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
 	}
 
 	out.WriteString("{")
@@ -460,13 +690,14 @@ type AssignmentExpression struct {
 	Identifier *Identifier
 	Value      Expression
 	Operator   string
-	TokenInfo  interface{}
+	SourceSpan token.Span
 }
 
 func (as *AssignmentExpression) expressionNode()      {}
+func (as *AssignmentExpression) Span() token.Span     { return as.SourceSpan }
 func (as *AssignmentExpression) TokenLiteral() string { return as.Token.Literal }
 func (as *AssignmentExpression) String() string {
-	var out *bytes.Buffer
+	var out bytes.Buffer
 
 	out.WriteString(as.Identifier.String())
 	out.WriteString(" " + as.Operator + " ")
@@ -476,17 +707,18 @@ func (as *AssignmentExpression) String() string {
 }
 
 type SquareBracketAssignment struct {
-	Token     token.Token // the [ token
-	Value     Expression
-	Key       Expression
-	Left      Expression
-	TokenInfo interface{}
+	Token      token.Token // the [ token
+	Value      Expression
+	Key        Expression
+	Left       Expression
+	SourceSpan token.Span
 }
 
 func (as *SquareBracketAssignment) expressionNode()      {}
+func (as *SquareBracketAssignment) Span() token.Span     { return as.SourceSpan }
 func (as *SquareBracketAssignment) TokenLiteral() string { return as.Token.Literal }
 func (as *SquareBracketAssignment) String() string {
-	var out *bytes.Buffer
+	var out bytes.Buffer
 
 	out.WriteString(as.Left.String())
 	out.WriteString("[")
@@ -498,10 +730,11 @@ func (as *SquareBracketAssignment) String() string {
 }
 
 type NullValueExpression struct {
-	TokenInfo interface{}
+	SourceSpan token.Span
 }
 
 func (nv *NullValueExpression) expressionNode()      {}
+func (nv *NullValueExpression) Span() token.Span     { return nv.SourceSpan }
 func (nv *NullValueExpression) TokenLiteral() string { return "null" }
 func (nv *NullValueExpression) String() string {
 	return nv.TokenLiteral()
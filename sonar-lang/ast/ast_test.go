@@ -1,6 +1,7 @@
 package ast
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/icheka/sonar-lang/sonar-lang/token"
@@ -27,3 +28,89 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestInspect(t *testing.T) {
+	// let f = func(x) { x + y }
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let"},
+				Name:  &Identifier{Token: token.Token{Type: token.IDENT, Literal: "f"}, Value: "f"},
+				Value: &FunctionLiteral{
+					Token:      token.Token{Type: token.FUNCTION, Literal: "func"},
+					Parameters: []*Identifier{{Token: token.Token{Type: token.IDENT, Literal: "x"}, Value: "x"}},
+					Body: &BlockStatement{
+						Statements: []Statement{
+							&ExpressionStatement{
+								Expression: &InfixExpression{
+									Operator: "+",
+									Left:     &Identifier{Token: token.Token{Type: token.IDENT, Literal: "x"}, Value: "x"},
+									Right:    &Identifier{Token: token.Token{Type: token.IDENT, Literal: "y"}, Value: "y"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	identifiers := []string{}
+	Inspect(program, func(n Node) bool {
+		if ident, ok := n.(*Identifier); ok {
+			identifiers = append(identifiers, ident.Value)
+		}
+		return true
+	})
+
+	expected := []string{"f", "x", "x", "y"}
+	if len(identifiers) != len(expected) {
+		t.Fatalf("wrong number of identifiers. want=%v, got=%v", expected, identifiers)
+	}
+	for i, name := range expected {
+		if identifiers[i] != name {
+			t.Errorf("identifiers[%d] wrong. want=%q, got=%q", i, name, identifiers[i])
+		}
+	}
+
+	// returning false prunes the subtree
+	count := 0
+	Inspect(program, func(n Node) bool {
+		count++
+		_, isFunction := n.(*FunctionLiteral)
+		return !isFunction
+	})
+	if count != 4 {
+		t.Errorf("expected Inspect to visit 4 nodes, visited %d", count)
+	}
+}
+
+func TestFprint(t *testing.T) {
+	// let a = -b
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let"},
+				Name:  &Identifier{Token: token.Token{Type: token.IDENT, Literal: "a"}, Value: "a"},
+				Value: &PrefixExpression{
+					Token:    token.Token{Type: token.MINUS, Literal: "-"},
+					Operator: "-",
+					Right:    &Identifier{Token: token.Token{Type: token.IDENT, Literal: "b"}, Value: "b"},
+				},
+				SourceSpan: token.Span{Start: token.Position{Line: 1, Column: 1}},
+			},
+		},
+	}
+
+	var out strings.Builder
+	Fprint(&out, program)
+	expected := `Program 1:1 "let"
+  LetStatement 1:1 "let"
+    Identifier 0:0 "a"
+    PrefixExpression 0:0 "-"
+      Identifier 0:0 "b"
+`
+	if out.String() != expected {
+		t.Errorf("wrong tree. expected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,137 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Inspect traverses an AST in depth-first order, starting with node. If f
+// returns true, Inspect is called recursively for each of node's children.
+func Inspect(node Node, f func(Node) bool) {
+	if isNilNode(node) || !f(node) {
+		return
+	}
+
+	for _, child := range children(node) {
+		Inspect(child, f)
+	}
+}
+
+// Fprint writes the tree rooted at node to w, one node per line, indented by
+// its depth: its type and position, followed by the token of nodes that have
+// children and the source of those that do not
+func Fprint(w io.Writer, node Node) {
+	fprint(w, node, 0)
+}
+
+func fprint(w io.Writer, node Node, depth int) {
+	if isNilNode(node) {
+		return
+	}
+
+	nodes := children(node)
+	text := node.String()
+	if len(nodes) != 0 {
+		text = node.TokenLiteral()
+	}
+	start := node.Span().Start
+	fmt.Fprintf(w, "%s%s %d:%d %q\n", strings.Repeat("  ", depth), reflect.TypeOf(node).Elem().Name(), start.Line, start.Column, text)
+
+	for _, child := range nodes {
+		fprint(w, child, depth+1)
+	}
+}
+
+// children returns the direct child nodes of node in source order.
+func children(node Node) []Node {
+	switch n := node.(type) {
+	case *Program:
+		return statementNodes(n.Statements)
+	case *BlockStatement:
+		return statementNodes(n.Statements)
+	case *LetStatement:
+		return []Node{n.Name, n.Value}
+	case *ReturnStatement:
+		return []Node{n.ReturnValue}
+	case *ExpressionStatement:
+		return []Node{n.Expression}
+	case *WhileStatement:
+		return []Node{n.Condition, n.Consequence}
+	case *ForStatement:
+		return []Node{n.Counter, n.Value, n.Iterable, n.Consequence}
+	case *ThrowStatement:
+		return []Node{n.Value}
+	case *TryStatement:
+		return []Node{n.Block, n.Parameter, n.Catch, n.Finally}
+	case *ImportStatement:
+		nodes := []Node{n.Path, n.Alias}
+		for _, name := range n.Names {
+			nodes = append(nodes, name)
+		}
+		return nodes
+	case *ExportStatement:
+		return []Node{n.Statement}
+	case *PrefixExpression:
+		return []Node{n.Right}
+	case *InfixExpression:
+		return []Node{n.Left, n.Right}
+	case *LogicalExpression:
+		return []Node{n.Left, n.Right}
+	case *IfExpression:
+		return []Node{n.Condition, n.Consequence, n.Alternative}
+	case *FunctionLiteral:
+		nodes := []Node{}
+		for _, p := range n.Parameters {
+			nodes = append(nodes, p)
+		}
+		return append(nodes, n.Body)
+	case *CallExpression:
+		return append([]Node{n.Function}, expressionNodes(n.Arguments)...)
+	case *ArrayLiteral:
+		return expressionNodes(n.Elements)
+	case *ConcatExpression:
+		return expressionNodes(n.Parts)
+	case *IndexExpression:
+		return []Node{n.Left, n.Index}
+	case *HashLiteral:
+		nodes := []Node{}
+		for _, pair := range n.Pairs {
+			nodes = append(nodes, pair.Key, pair.Value)
+		}
+		return nodes
+	case *AssignmentExpression:
+		return []Node{n.Identifier, n.Value}
+	case *SquareBracketAssignment:
+		return []Node{n.Left, n.Key, n.Value}
+	}
+
+	return nil
+}
+
+func statementNodes(statements []Statement) []Node {
+	nodes := make([]Node, 0, len(statements))
+	for _, s := range statements {
+		nodes = append(nodes, s)
+	}
+	return nodes
+}
+
+func expressionNodes(expressions []Expression) []Node {
+	nodes := make([]Node, 0, len(expressions))
+	for _, e := range expressions {
+		nodes = append(nodes, e)
+	}
+	return nodes
+}
+
+// isNilNode reports whether node is nil or a typed nil pointer, both of which
+// the parser leaves behind for constructs it could not parse.
+func isNilNode(node Node) bool {
+	if node == nil {
+		return true
+	}
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
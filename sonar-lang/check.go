@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/icheka/sonar-lang/sonar-lang/diagnostics"
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/inputs"
+	"github.com/icheka/sonar-lang/sonar-lang/lexer"
+	"github.com/icheka/sonar-lang/sonar-lang/parser"
+	"github.com/icheka/sonar-lang/sonar-lang/sonar"
+)
+
+// check reports the errors in the program named by args to stdout, in the
+// format given by the --format flag
+func (c *cli) check(args []string) int {
+	flags := c.flagSet("check")
+	format := flags.String("format", "text", "the `format` to report errors in: text, json or sarif")
+	eval := flags.Bool("eval", false, "also run the program, and report the error it raises")
+	if status, ok := parseFlags(flags, args); !ok {
+		return status
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return EXIT_USAGE
+	}
+
+	var write func(io.Writer, []errors.Error) error
+	switch *format {
+	case "text":
+		write = func(out io.Writer, errs []errors.Error) error {
+			diagnostics.New(out).Errors(errs)
+			return nil
+		}
+	case "json":
+		write = diagnostics.WriteJSON
+	case "sarif":
+		write = diagnostics.WriteSARIF
+	default:
+		fmt.Fprintf(c.stderr, "Unknown format %q: expected text, json or sarif\n", *format)
+		return EXIT_USAGE
+	}
+
+	path, err := filepath.Abs(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return EXIT_USAGE
+	}
+	errs, err := checkFile(path, *eval, c.stderr)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Cannot check %s: %s\n", flags.Arg(0), err)
+		return EXIT_USAGE
+	}
+
+	if err := write(c.stdout, errs); err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return EXIT_USAGE
+	}
+	if len(errs) != 0 {
+		return EXIT_ERROR
+	}
+	return EXIT_OK
+}
+
+// checkFile returns the syntax errors in the program at path or, if eval is
+// set and it has none, the error raised running it. What the program prints
+// goes to stdout.
+func checkFile(path string, eval bool, stdout io.Writer) ([]errors.Error, error) {
+	if !eval {
+		source, err := (&inputs.FileInput{Path: path}).Load()
+		if err != nil {
+			return nil, err
+		}
+		p := parser.New(lexer.New(source, &lexer.LexerOptions{Path: path}))
+		p.ParseProgram()
+		return p.Errors(), nil
+	}
+
+	interpreter := sonar.New()
+	interpreter.Stdout = stdout
+	interpreter.Stderr = io.Discard
+
+	_, err := interpreter.RunFile(context.Background(), path)
+	if sonarErr, ok := err.(*sonar.Error); ok {
+		return sonarErr.Errors, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,288 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+
+	// Binary operators
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+	OpGreaterEqual
+	OpLessEqual
+	OpIn
+
+	// Prefix operators
+	OpMinus
+	OpBang
+
+	// Postfix operators
+	OpIncrement
+	OpDecrement
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	OpJump
+	OpJumpNotTruthy
+	OpAnd
+	OpOr
+	OpNullish
+	OpJumpNull
+
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetCell
+	OpSetCell
+	OpLoadCell
+	OpGetFree
+	OpLoadFree
+	OpGetShadow
+	OpGetFreeShadow
+	OpGetName
+	OpSetName
+
+	OpArray
+	OpHash
+	OpConcat
+	OpIndex
+	OpSetIndex
+
+	OpIter
+	OpIterNext
+
+	OpClosure
+	OpCall
+	OpReturnValue
+
+	OpThrow
+	OpRethrow
+	OpTry
+	OpEndTry
+	OpCatch
+	OpFinally
+	OpEndFinally
+
+	OpImport
+	OpImportName
+)
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpAdd:          {"OpAdd", []int{}},
+	OpSub:          {"OpSub", []int{}},
+	OpMul:          {"OpMul", []int{}},
+	OpDiv:          {"OpDiv", []int{}},
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}},
+	OpLessThan:     {"OpLessThan", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+	OpIn:           {"OpIn", []int{}},
+
+	OpMinus: {"OpMinus", []int{}},
+	OpBang:  {"OpBang", []int{}},
+
+	// the operand is the constant holding the operand's source literal, used in errors
+	OpIncrement: {"OpIncrement", []int{2}},
+	OpDecrement: {"OpDecrement", []int{2}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	// jump to the operand, keeping the value on top of the stack, if it is
+	// falsy (OpAnd) or truthy (OpOr); otherwise pop it
+	OpAnd: {"OpAnd", []int{2}},
+	OpOr:  {"OpOr", []int{2}},
+	// jump to the operand, keeping the value on top of the stack, if it is not
+	// null; otherwise pop it
+	OpNullish: {"OpNullish", []int{2}},
+	// jump to the operand, keeping the value on top of the stack, if it is null
+	OpJumpNull: {"OpJumpNull", []int{2}},
+
+	OpGetGlobal: {"OpGetGlobal", []int{2}},
+	OpSetGlobal: {"OpSetGlobal", []int{2}},
+	OpGetLocal:  {"OpGetLocal", []int{1}},
+	OpSetLocal:  {"OpSetLocal", []int{1}},
+	OpGetCell:   {"OpGetCell", []int{1}},
+	OpSetCell:   {"OpSetCell", []int{1}},
+	OpLoadCell:  {"OpLoadCell", []int{1}},
+	OpGetFree:   {"OpGetFree", []int{1}},
+	OpLoadFree:  {"OpLoadFree", []int{1}},
+	OpGetName:   {"OpGetName", []int{2}},
+	OpSetName:   {"OpSetName", []int{2}},
+	// push the local (OpGetShadow) or free variable (OpGetFreeShadow) a
+	// function binds by assigning to a name of an enclosing scope, and jump
+	// to the second operand, if it has been assigned; otherwise the name it
+	// shadows is loaded by the instructions that follow
+	OpGetShadow:     {"OpGetShadow", []int{1, 2}},
+	OpGetFreeShadow: {"OpGetFreeShadow", []int{1, 2}},
+
+	OpArray:    {"OpArray", []int{2}},
+	OpHash:     {"OpHash", []int{2}},
+	OpConcat:   {"OpConcat", []int{2}},
+	OpIndex:    {"OpIndex", []int{}},
+	OpSetIndex: {"OpSetIndex", []int{}},
+
+	OpIter:     {"OpIter", []int{}},
+	OpIterNext: {"OpIterNext", []int{2}},
+
+	// constant index of the compiled function, number of free variables
+	OpClosure:     {"OpClosure", []int{2, 1}},
+	OpCall:        {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+
+	// raise the value on top of the stack as thrown by `throw` (OpThrow), or
+	// raise again the error a handler was given (OpRethrow)
+	OpThrow:   {"OpThrow", []int{}},
+	OpRethrow: {"OpRethrow", []int{}},
+	// set up a handler that an error raised before the matching OpEndTry
+	// jumps to, with the error on top of the stack
+	OpTry:    {"OpTry", []int{2}},
+	OpEndTry: {"OpEndTry", []int{}},
+	// replace the error on top of the stack with the map a catch block binds
+	OpCatch: {"OpCatch", []int{}},
+	// push the position of the next instruction and jump to the finally block
+	// at the operand, which OpEndFinally returns to
+	OpFinally:    {"OpFinally", []int{2}},
+	OpEndFinally: {"OpEndFinally", []int{}},
+
+	// push the module imported by the path in the constant at the operand,
+	// loading it unless it has already been
+	OpImport: {"OpImport", []int{2}},
+	// push the export of the module on top of the stack named by the second
+	// operand; the first is the constant holding its import path, for errors
+	OpImportName: {"OpImportName", []int{2, 2}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 { return uint8(ins[0]) }
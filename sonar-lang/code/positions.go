@@ -0,0 +1,29 @@
+package code
+
+import (
+	"sort"
+
+	"github.com/icheka/sonar-lang/sonar-lang/token"
+)
+
+// Position is the position in the source code of the instructions starting
+// at Offset
+type Position struct {
+	Offset int
+	Source token.Position
+}
+
+// Positions locates instructions in the source code they were compiled from.
+// Each entry gives the position of the instructions from its offset up to the
+// next entry's.
+type Positions []Position
+
+// At returns the position of the instruction at offset, or the zero
+// position if it is unknown
+func (p Positions) At(offset int) token.Position {
+	i := sort.Search(len(p), func(i int) bool { return p[i].Offset > offset })
+	if i == 0 {
+		return token.Position{}
+	}
+	return p[i-1].Source
+}
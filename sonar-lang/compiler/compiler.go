@@ -0,0 +1,1092 @@
+package compiler
+
+import (
+	"strconv"
+
+	"github.com/icheka/sonar-lang/sonar-lang/ast"
+	"github.com/icheka/sonar-lang/sonar-lang/code"
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+	"github.com/icheka/sonar-lang/sonar-lang/token"
+)
+
+var infixOperators = map[string]code.Opcode{
+	token.PLUS:     code.OpAdd,
+	token.MINUS:    code.OpSub,
+	token.ASTERISK: code.OpMul,
+	token.SLASH:    code.OpDiv,
+	token.EQ:       code.OpEqual,
+	token.NOT_EQ:   code.OpNotEqual,
+	token.GT:       code.OpGreaterThan,
+	token.LT:       code.OpLessThan,
+	token.GTE:      code.OpGreaterEqual,
+	token.LTE:      code.OpLessEqual,
+	token.IN:       code.OpIn,
+}
+
+// compound assignment operators and the infix operator each one applies
+var assignmentOperators = map[string]code.Opcode{
+	token.PLUS_ASSIGN:     code.OpAdd,
+	token.MINUS_ASSIGN:    code.OpSub,
+	token.ASTERISK_ASSIGN: code.OpMul,
+	token.SLASH_ASSIGN:    code.OpDiv,
+}
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Positions    code.Positions
+	Constants    []object.Object
+	Globals      map[string]int // global slots by name, for late-bound lookups
+	Exports      []string       // the names declared with `export let`, in declaration order
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type loop struct {
+	start  int   // where `continue` jumps to
+	breaks []int // positions of `break` jumps, patched once the loop's end is known
+	tries  int   // the number of try statements the loop is nested in
+}
+
+// tryBlock is a try statement being compiled, which return, break and
+// continue must leave as an error would: removing its handlers and running
+// its finally block
+type tryBlock struct {
+	handlers int  // the handlers set up for the block being compiled
+	finally  bool // whether leaving the block runs the finally block
+	// whether the finally block is being compiled, with the value of the
+	// statement (or the error to rethrow) and the position to return to on
+	// top of the stack
+	inFinally bool
+	calls     []int // positions of OpFinally, patched once the finally block's is known
+}
+
+type CompilationScope struct {
+	instructions        code.Instructions
+	positions           code.Positions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	loops               []*loop
+	tries               []*tryBlock
+}
+
+type Compiler struct {
+	constants []object.Object
+
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// the position of the node being compiled, which the instructions
+	// emitted for it are located at
+	position token.Position
+
+	exports []string
+}
+
+func New() *Compiler {
+	mainScope := CompilationScope{
+		instructions: code.Instructions{},
+	}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: NewSymbolTable(),
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+}
+
+// NewWithState returns a compiler that keeps defining globals and constants
+// where a previous compilation left off, as a REPL needs.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	position := c.position
+	c.position = node.Span().Start
+	defer func() { c.position = position }()
+
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.ExpressionStatement:
+		if node.Expression == nil {
+			return nil
+		}
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.LetStatement:
+		return c.compileLetStatement(node)
+
+	case *ast.ReturnStatement:
+		if node.ReturnValue == nil {
+			c.emit(code.OpNull)
+		} else if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.leaveTries(0, false)
+		c.emit(code.OpReturnValue)
+
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+
+	case *ast.BreakStatement:
+		l := c.currentLoop()
+		if l == nil {
+			return errors.IllegalStatementOutsideLoopError(node.TokenLiteral(), errorConfig(node))
+		}
+		c.leaveTries(l.tries, true)
+		l.breaks = append(l.breaks, c.emit(code.OpJump, 9999))
+
+	case *ast.ContinueStatement:
+		l := c.currentLoop()
+		if l == nil {
+			return errors.IllegalStatementOutsideLoopError(node.TokenLiteral(), errorConfig(node))
+		}
+		c.leaveTries(l.tries, true)
+		c.emit(code.OpJump, l.start)
+
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+
+	case *ast.TryStatement:
+		if err := c.compileTryStatement(node); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.ImportStatement:
+		return c.compileImportStatement(node)
+
+	case *ast.ExportStatement:
+		if err := c.compileLetStatement(node.Statement); err != nil {
+			return err
+		}
+		c.exports = append(c.exports, node.Statement.Name.Value)
+
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.NullValueExpression:
+		c.emit(code.OpNull)
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case token.BANG:
+			c.emit(code.OpBang)
+		case token.MINUS:
+			c.emit(code.OpMinus)
+		default:
+			return errors.UnknownPrefixOperatorError(node.Operator, "", errorConfig(node))
+		}
+
+	case *ast.InfixExpression:
+		op, ok := infixOperators[node.Operator]
+		if !ok {
+			return errors.UnknownOperatorError(node.Operator, "", "", errorConfig(node))
+		}
+
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+
+	case *ast.LogicalExpression:
+		return c.compileLogicalExpression(node)
+
+	case *ast.PostfixExpression:
+		return c.compilePostfixExpression(node)
+
+	case *ast.AssignmentExpression:
+		return c.compileAssignmentExpression(node)
+
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+
+	case *ast.Identifier:
+		c.loadName(node.Value)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node, "")
+
+	case *ast.CallExpression:
+		return c.compileChain(node)
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.ConcatExpression:
+		for _, part := range node.Parts {
+			if err := c.Compile(part); err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpConcat, len(node.Parts))
+
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		return c.compileChain(node)
+
+	case *ast.SquareBracketAssignment:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Key); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpSetIndex)
+	}
+
+	return nil
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Positions:    c.scopes[c.scopeIndex].positions,
+		Constants:    c.constants,
+		Globals:      c.globalTable().Globals(),
+		Exports:      c.exports,
+	}
+}
+
+func (c *Compiler) compileLetStatement(node *ast.LetStatement) error {
+	name := node.Name.Value
+	if c.symbolTable.Defined(name) {
+		return errors.IdentifierAlreadyDefinedError(name, errorConfig(node.Name))
+	}
+	define := c.symbolTable.Define
+	if node.Constant {
+		define = c.symbolTable.DefineConstant
+	}
+
+	// a function may refer to the name it is being bound to, so define it first
+	if fn, ok := node.Value.(*ast.FunctionLiteral); ok {
+		symbol := define(name)
+		if err := c.compileFunctionLiteral(fn, name); err != nil {
+			return err
+		}
+		c.storeSymbol(symbol)
+		return nil
+	}
+
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+	c.storeSymbol(define(name))
+	return nil
+}
+
+// compileImportStatement binds the module imported by node, or the names it
+// exports that node lists, as const statements would
+func (c *Compiler) compileImportStatement(node *ast.ImportStatement) error {
+	path := c.addConstant(&object.String{Value: node.Path.Value})
+
+	// errors loading the module are reported at its path
+	c.position = node.Path.Span().Start
+	c.emit(code.OpImport, path)
+
+	if node.Names == nil {
+		name := node.Alias
+		if name == nil {
+			name = &ast.Identifier{Value: object.ModuleName(node.Path.Value), SourceSpan: node.Path.SourceSpan}
+		}
+		return c.bindImport(name)
+	}
+
+	for _, name := range node.Names {
+		c.position = name.Span().Start
+		c.emit(code.OpImportName, path, c.addConstant(&object.String{Value: name.Value}))
+		if err := c.bindImport(name); err != nil {
+			return err
+		}
+	}
+	c.emit(code.OpPop)
+	return nil
+}
+
+// bindImport declares name with the value on top of the stack
+func (c *Compiler) bindImport(name *ast.Identifier) error {
+	if c.symbolTable.Defined(name.Value) {
+		return errors.IdentifierAlreadyDefinedError(name.Value, errorConfig(name))
+	}
+	c.storeSymbol(c.symbolTable.DefineConstant(name.Value))
+	return nil
+}
+
+func (c *Compiler) compileAssignmentExpression(node *ast.AssignmentExpression) error {
+	if node.Identifier == nil {
+		return errors.ExpectedIdentifierInAssignmentError(node.TokenLiteral(), errorConfig(node))
+	}
+	name := node.Identifier.Value
+
+	symbol, ok := c.symbolTable.Resolve(name)
+	if ok && symbol.Readonly {
+		return errors.ConstantAssignmentError(name, errorConfig(node))
+	}
+
+	if node.Operator != token.ASSIGN {
+		op, ok := assignmentOperators[node.Operator]
+		if !ok {
+			return errors.UnknownOperatorError(node.Operator, "", "", errorConfig(node))
+		}
+
+		c.loadName(name)
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(op)
+	} else {
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if symbol.Shadow {
+			// like the evaluator, only assign to names defined somewhere
+			c.loadSymbol(symbol)
+			c.emit(code.OpPop)
+		}
+	}
+
+	c.storeName(name)
+	c.loadName(name)
+	return nil
+}
+
+func (c *Compiler) compilePostfixExpression(node *ast.PostfixExpression) error {
+	literal := node.Token.Literal
+
+	op := code.OpIncrement
+	step := int64(1)
+	if node.Operator == token.POST_DECR {
+		op = code.OpDecrement
+		step = -1
+	}
+
+	switch node.Token.Type {
+	case token.INT:
+		value, err := strconv.ParseInt(literal, 10, 64)
+		if err != nil {
+			return errors.IllegalTokenError(literal, errorConfig(node))
+		}
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: value + step}))
+
+	case token.IDENT:
+		if symbol, ok := c.symbolTable.Resolve(literal); ok && symbol.Readonly {
+			return errors.ConstantAssignmentError(literal, errorConfig(node))
+		}
+
+		c.loadName(literal)
+		c.emit(op, c.addConstant(&object.String{Value: literal}))
+		c.storeName(literal)
+		c.loadName(literal)
+
+	default:
+		return errors.IllegalTokenError(literal, errorConfig(node))
+	}
+
+	return nil
+}
+
+// compileChain compiles a chain of index expressions and calls, such as
+// a?.b.c(d), where an optional link that finds null skips the rest of the
+// chain, leaving null as its value
+func (c *Compiler) compileChain(node ast.Expression) error {
+	jumps, err := c.compileChainLink(node)
+	if err != nil {
+		return err
+	}
+	for _, pos := range jumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	return nil
+}
+
+// compileChainLink compiles one link of a chain, and returns the positions of
+// the jumps to the end of the chain of its optional links
+func (c *Compiler) compileChainLink(node ast.Expression) ([]int, error) {
+	var left ast.Expression
+	var optional bool
+	switch node := node.(type) {
+	case *ast.IndexExpression:
+		left, optional = node.Left, node.Optional
+	case *ast.CallExpression:
+		left, optional = node.Function, node.Optional
+	default:
+		return nil, c.Compile(node)
+	}
+
+	// a chain in parentheses is one link of this one
+	var jumps []int
+	var err error
+	if ast.Grouped(left) {
+		err = c.Compile(left)
+	} else {
+		jumps, err = c.compileChainLink(left)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if optional {
+		jumps = append(jumps, c.emit(code.OpJumpNull, 9999))
+	}
+
+	switch node := node.(type) {
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Index); err != nil {
+			return nil, err
+		}
+		c.emit(code.OpIndex)
+	case *ast.CallExpression:
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return nil, err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+	}
+	return jumps, nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBlockValue(node.Alternative); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileLogicalExpression leaves the left operand on the stack, and skips the
+// right one, if the left decides the result
+func (c *Compiler) compileLogicalExpression(node *ast.LogicalExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	op := code.OpAnd
+	switch node.Operator {
+	case token.OR:
+		op = code.OpOr
+	case token.NULLISH:
+		op = code.OpNullish
+	}
+	jumpPos := c.emit(op, 9999)
+
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	l := c.enterLoop()
+
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	exitPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.Compile(node.Consequence); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, l.start)
+
+	c.changeOperand(exitPos, len(c.currentInstructions()))
+	c.leaveLoop()
+
+	// like the evaluator, a loop evaluates to null
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+	return nil
+}
+
+// compileForStatement lowers `for (counter, value in iterable) { ... }`. The
+// iterator stays on the stack for the duration of the loop. As in the
+// evaluator, the counter is scoped to the loop and read-only, while the value
+// is bound in the enclosing scope.
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	// a value that cannot be iterated over is reported at the iterable
+	c.position = node.Iterable.Span().Start
+	c.emit(code.OpIter)
+	c.position = node.Span().Start
+
+	outer := c.symbolTable
+	c.symbolTable = NewBlockSymbolTable(outer)
+	defer func() { c.symbolTable = outer }()
+
+	counterName := node.Counter.String()
+	valueName := node.Value.String()
+
+	counter := c.symbolTable.DefineInBlock(counterName)
+	c.symbolTable.MarkReadonly(counterName)
+
+	value, ok := outer.Resolve(valueName)
+	if !ok || !value.Shadow && !outer.Defined(valueName) {
+		value = outer.Define(valueName)
+	}
+
+	l := c.enterLoop()
+	exitPos := c.emit(code.OpIterNext, 9999)
+	c.storeSymbol(value)
+	c.storeSymbol(counter)
+
+	if err := c.Compile(node.Consequence); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, l.start)
+
+	c.changeOperand(exitPos, len(c.currentInstructions()))
+	c.leaveLoop()
+
+	// discard the iterator; a loop evaluates to null
+	c.emit(code.OpPop)
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+	return nil
+}
+
+// compileTryStatement leaves the value of a try statement on the stack. An
+// error raised in the try block jumps to the catch block, and one raised in
+// either of them to the finally block, which is compiled once and entered by
+// OpFinally on every way out of the statement.
+func (c *Compiler) compileTryStatement(node *ast.TryStatement) error {
+	t := &tryBlock{finally: node.Finally != nil}
+	c.scopes[c.scopeIndex].tries = append(c.scopes[c.scopeIndex].tries, t)
+	defer func() {
+		tries := c.scopes[c.scopeIndex].tries
+		c.scopes[c.scopeIndex].tries = tries[:len(tries)-1]
+	}()
+
+	var finallyHandlerPos int
+	if t.finally {
+		finallyHandlerPos = c.emit(code.OpTry, 9999)
+		t.handlers++
+	}
+
+	if node.Catch == nil {
+		if err := c.compileBlockValue(node.Block); err != nil {
+			return err
+		}
+	} else {
+		catchHandlerPos := c.emit(code.OpTry, 9999)
+		t.handlers++
+
+		if err := c.compileBlockValue(node.Block); err != nil {
+			return err
+		}
+		c.emit(code.OpEndTry)
+		t.handlers--
+		jumpPos := c.emit(code.OpJump, 9999)
+
+		c.changeOperand(catchHandlerPos, len(c.currentInstructions()))
+		if err := c.compileCatch(node); err != nil {
+			return err
+		}
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+	}
+
+	if !t.finally {
+		return nil
+	}
+
+	c.emit(code.OpEndTry)
+	t.handlers--
+	t.calls = append(t.calls, c.emit(code.OpFinally, 9999))
+	endPos := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(finallyHandlerPos, len(c.currentInstructions()))
+	t.calls = append(t.calls, c.emit(code.OpFinally, 9999))
+	c.emit(code.OpRethrow)
+
+	for _, pos := range t.calls {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	t.finally, t.inFinally = false, true
+	if err := c.Compile(node.Finally); err != nil {
+		return err
+	}
+	c.emit(code.OpEndFinally)
+
+	c.changeOperand(endPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileCatch compiles the catch block of node, entered with the error it
+// catches on top of the stack. As in the evaluator, the parameter is scoped
+// to the block.
+func (c *Compiler) compileCatch(node *ast.TryStatement) error {
+	if node.Parameter == nil {
+		c.emit(code.OpPop)
+		return c.compileBlockValue(node.Catch)
+	}
+
+	outer := c.symbolTable
+	c.symbolTable = NewBlockSymbolTable(outer)
+	defer func() { c.symbolTable = outer }()
+
+	c.emit(code.OpCatch)
+	c.storeSymbol(c.symbolTable.DefineInBlock(node.Parameter.Value))
+	return c.compileBlockValue(node.Catch)
+}
+
+// leaveTries leaves the try statements being compiled down to the one at
+// depth, innermost first, removing their handlers and running their finally
+// blocks. Unless pop is false, the values finally blocks being left were
+// entered with are discarded.
+func (c *Compiler) leaveTries(depth int, pop bool) {
+	tries := c.scopes[c.scopeIndex].tries
+	for i := len(tries) - 1; i >= depth; i-- {
+		t := tries[i]
+		for j := 0; j < t.handlers; j++ {
+			c.emit(code.OpEndTry)
+		}
+		if t.finally {
+			t.calls = append(t.calls, c.emit(code.OpFinally, 9999))
+		}
+		if t.inFinally && pop {
+			c.emit(code.OpPop)
+			c.emit(code.OpPop)
+		}
+	}
+}
+
+// compileFunctionLiteral compiles a function, named after the name it is
+// bound to by a let statement, if any
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral, name string) error {
+	c.enterScope(capturedNames(node.Body), assignedNames(node.Body))
+
+	for _, p := range node.Parameters {
+		symbol := c.symbolTable.Define(p.Value)
+		if symbol.Cell {
+			c.emit(code.OpGetLocal, symbol.Index)
+			c.emit(code.OpSetCell, symbol.Index)
+		}
+	}
+
+	if err := c.compileBlockValue(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.NumDefinitions()
+	localNames := c.symbolTable.Names()
+	instructions, positions := c.leaveScope()
+
+	for _, s := range freeSymbols {
+		c.loadCell(s)
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Name:          name,
+		Instructions:  instructions,
+		Positions:     positions,
+		NumLocals:     numLocals,
+		LocalNames:    localNames,
+		NumParameters: len(node.Parameters),
+		Parameters:    node.Parameters,
+		Body:          node.Body,
+	}
+
+	c.emit(code.OpClosure, c.addConstant(compiledFn), len(freeSymbols))
+	return nil
+}
+
+// compileBlockValue compiles block and leaves the value the evaluator would
+// give it on the stack: that of its last statement, or null.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if block == nil {
+		c.emit(code.OpNull)
+		return nil
+	}
+
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if len(block.Statements) == 0 {
+		c.emit(code.OpNull)
+		return nil
+	}
+
+	switch last := block.Statements[len(block.Statements)-1].(type) {
+	case *ast.LetStatement:
+		c.loadName(last.Name.Value)
+	default:
+		if c.lastInstructionIs(code.OpPop) {
+			c.removeLastPop()
+		} else {
+			c.emit(code.OpNull)
+		}
+	}
+
+	return nil
+}
+
+func (c *Compiler) loadName(name string) {
+	if symbol, ok := c.symbolTable.Resolve(name); ok {
+		c.loadSymbol(symbol)
+		return
+	}
+
+	// globals defined further down and builtins are looked up when executed
+	c.emit(code.OpGetName, c.addConstant(&object.String{Value: name}))
+}
+
+func (c *Compiler) storeName(name string) {
+	if symbol, ok := c.symbolTable.Resolve(name); ok {
+		c.storeSymbol(symbol)
+		return
+	}
+
+	c.emit(code.OpSetName, c.addConstant(&object.String{Value: name}))
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	if s.Shadow {
+		c.loadShadow(s)
+		return
+	}
+
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		if s.Cell {
+			c.emit(code.OpGetCell, s.Index)
+		} else {
+			c.emit(code.OpGetLocal, s.Index)
+		}
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	}
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case LocalScope:
+		if s.Cell {
+			c.emit(code.OpSetCell, s.Index)
+		} else {
+			c.emit(code.OpSetLocal, s.Index)
+		}
+	}
+}
+
+// loadShadow loads the local s if it has been assigned, and the name it
+// shadows otherwise
+func (c *Compiler) loadShadow(s Symbol) {
+	op := code.OpGetShadow
+	if s.Scope == FreeScope {
+		op = code.OpGetFreeShadow
+	}
+	pos := c.emit(op, s.Index, 9999)
+
+	if s.Shadowed == nil {
+		c.emit(code.OpGetName, c.addConstant(&object.String{Value: s.Name}))
+	} else {
+		c.loadSymbol(*s.Shadowed)
+	}
+
+	c.replaceInstruction(pos, code.Make(op, s.Index, len(c.currentInstructions())))
+}
+
+// loadCell pushes the cell behind a captured variable, for OpClosure
+func (c *Compiler) loadCell(s Symbol) {
+	if s.Scope == FreeScope {
+		c.emit(code.OpLoadFree, s.Index)
+	} else {
+		c.emit(code.OpLoadCell, s.Index)
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+	c.setPosition(pos)
+
+	return pos
+}
+
+// setPosition locates the instructions from pos onwards at the node being
+// compiled
+func (c *Compiler) setPosition(pos int) {
+	positions := c.scopes[c.scopeIndex].positions
+	if n := len(positions); n > 0 && positions[n-1].Source == c.position {
+		return
+	}
+	c.scopes[c.scopeIndex].positions = append(positions, code.Position{Offset: pos, Source: c.position})
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	updatedInstructions := append(c.currentInstructions(), ins...)
+
+	c.scopes[c.scopeIndex].instructions = updatedInstructions
+
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	old := c.currentInstructions()
+	new := old[:last.Position]
+
+	c.scopes[c.scopeIndex].instructions = new
+	c.scopes[c.scopeIndex].lastInstruction = previous
+
+	positions := c.scopes[c.scopeIndex].positions
+	for len(positions) > 0 && positions[len(positions)-1].Offset >= last.Position {
+		positions = positions[:len(positions)-1]
+	}
+	c.scopes[c.scopeIndex].positions = positions
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) enterScope(captured, assigned map[string]bool) {
+	scope := CompilationScope{
+		instructions: code.Instructions{},
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable, captured, assigned)
+}
+
+func (c *Compiler) leaveScope() (code.Instructions, code.Positions) {
+	instructions := c.currentInstructions()
+	positions := c.scopes[c.scopeIndex].positions
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions, positions
+}
+
+func (c *Compiler) enterLoop() *loop {
+	l := &loop{start: len(c.currentInstructions()), tries: len(c.scopes[c.scopeIndex].tries)}
+	c.scopes[c.scopeIndex].loops = append(c.scopes[c.scopeIndex].loops, l)
+	return l
+}
+
+func (c *Compiler) leaveLoop() {
+	loops := c.scopes[c.scopeIndex].loops
+	l := loops[len(loops)-1]
+
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+
+	c.scopes[c.scopeIndex].loops = loops[:len(loops)-1]
+}
+
+func (c *Compiler) currentLoop() *loop {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return nil
+	}
+	return loops[len(loops)-1]
+}
+
+func (c *Compiler) globalTable() *SymbolTable {
+	s := c.symbolTable
+	for s.Outer != nil {
+		s = s.Outer
+	}
+	return s
+}
+
+// capturedNames returns the names referenced from functions nested in body.
+// It over-approximates the variables those functions capture, which is all
+// the compiler needs to decide which locals to store in cells.
+func capturedNames(body *ast.BlockStatement) map[string]bool {
+	names := map[string]bool{}
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		fn, ok := n.(*ast.FunctionLiteral)
+		if !ok {
+			return true
+		}
+
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Identifier:
+				names[n.Value] = true
+			case *ast.PostfixExpression:
+				names[n.Token.Literal] = true
+			}
+			return true
+		})
+		return false
+	})
+
+	return names
+}
+
+// assignedNames returns the names assigned to in body, outside the functions
+// nested in it
+func assignedNames(body *ast.BlockStatement) map[string]bool {
+	names := map[string]bool{}
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.AssignmentExpression:
+			if n.Identifier != nil {
+				names[n.Identifier.Value] = true
+			}
+		case *ast.PostfixExpression:
+			if n.Token.Type == token.IDENT {
+				names[n.Token.Literal] = true
+			}
+		case *ast.ForStatement:
+			names[n.Value.String()] = true
+		}
+		return true
+	})
+
+	return names
+}
+
+func errorConfig(node ast.Node) errors.ErrorConfig {
+	return errors.NewErrorConfig(node.Span().Start)
+}
@@ -0,0 +1,222 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL"
+	LocalScope  SymbolScope = "LOCAL"
+	FreeScope   SymbolScope = "FREE"
+)
+
+type Symbol struct {
+	Name     string
+	Scope    SymbolScope
+	Index    int
+	Cell     bool // a local captured by a nested function, stored in an object.Cell
+	Readonly bool
+
+	// Shadow is set on the local a function binds a name of an enclosing
+	// scope to by assigning to it. Until it is assigned, the name refers to
+	// Shadowed, or is looked up by name if Shadowed is nil.
+	Shadow   bool
+	Shadowed *Symbol
+}
+
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+	FreeSymbols    []Symbol
+	names          []string // the name bound to each slot, for error messages
+
+	// block tables hold names scoped to a block (a for loop's counter) and
+	// allocate their slots from the enclosing function's table
+	block bool
+	// names referenced from functions nested inside this table's function.
+	// Locals with these names are stored in cells so closures share them.
+	captured map[string]bool
+	// names assigned to in this table's function, which it binds itself
+	// unless it declares them
+	assigned map[string]bool
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol)}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable, captured, assigned map[string]bool) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	s.captured = captured
+	s.assigned = assigned
+	return s
+}
+
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	s.block = true
+	return s
+}
+
+// Define binds name in the nearest function (or global) scope. Like the
+// evaluator, a `let` inside a block binds in the enclosing scope.
+func (s *SymbolTable) Define(name string) Symbol {
+	if s.block {
+		return s.Outer.Define(name)
+	}
+	return s.define(s, name)
+}
+
+// DefineConstant is like Define, but name cannot be assigned to afterwards
+func (s *SymbolTable) DefineConstant(name string) Symbol {
+	if s.block {
+		return s.Outer.DefineConstant(name)
+	}
+	symbol := s.define(s, name)
+	symbol.Readonly = true
+	s.store[name] = symbol
+	return symbol
+}
+
+// DefineInBlock binds name in s itself; for block tables the name is
+// invisible outside the block but still occupies a slot of the enclosing scope.
+func (s *SymbolTable) DefineInBlock(name string) Symbol {
+	return s.define(s.owner(), name)
+}
+
+func (s *SymbolTable) define(owner *SymbolTable, name string) Symbol {
+	// a name declared after the function assigned to it keeps its slot
+	if symbol, ok := s.store[name]; ok && symbol.Shadow {
+		symbol.Shadow, symbol.Shadowed = false, nil
+		s.store[name] = symbol
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: owner.numDefinitions}
+	if owner.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+		symbol.Cell = owner.captured[name]
+	}
+
+	s.store[name] = symbol
+	owner.numDefinitions++
+	owner.names = append(owner.names, name)
+	return symbol
+}
+
+// Defined reports whether name is already bound in the scope Define binds in.
+func (s *SymbolTable) Defined(name string) bool {
+	if s.block {
+		return s.Outer.Defined(name)
+	}
+	symbol, ok := s.store[name]
+	return ok && symbol.Scope != FreeScope && !symbol.Shadow
+}
+
+func (s *SymbolTable) MarkReadonly(name string) {
+	if symbol, ok := s.store[name]; ok {
+		symbol.Readonly = true
+		s.store[name] = symbol
+	}
+}
+
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if ok || s.Outer == nil {
+		return symbol, ok
+	}
+	if s.assigned[name] {
+		return s.defineShadow(name), true
+	}
+
+	symbol, ok = s.Outer.Resolve(name)
+	if !ok || s.block || symbol.Scope == GlobalScope {
+		return symbol, ok
+	}
+
+	symbol = s.capture(symbol)
+	s.store[name] = symbol
+	return symbol, true
+}
+
+// defineShadow binds name, which the function assigns to without declaring
+// it, to a local. As in the evaluator, where the assignment stores name in
+// the function's own environment, the name refers to the enclosing scope
+// until then.
+func (s *SymbolTable) defineShadow(name string) Symbol {
+	symbol := Symbol{
+		Name:   name,
+		Scope:  LocalScope,
+		Index:  s.numDefinitions,
+		Cell:   s.captured[name],
+		Shadow: true,
+	}
+
+	if outer, ok := s.Outer.Resolve(name); ok {
+		if outer.Scope != GlobalScope {
+			outer = s.capture(outer)
+		}
+		symbol.Shadowed = &outer
+		symbol.Readonly = outer.Readonly
+	}
+
+	s.store[name] = symbol
+	s.numDefinitions++
+	s.names = append(s.names, name)
+	return symbol
+}
+
+// capture makes the variable original, of an enclosing function, free in
+// this one, along with those it shadows
+func (s *SymbolTable) capture(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{
+		Name:     original.Name,
+		Index:    len(s.FreeSymbols) - 1,
+		Scope:    FreeScope,
+		Readonly: original.Readonly,
+		Shadow:   original.Shadow,
+	}
+
+	if shadowed := original.Shadowed; shadowed != nil {
+		if shadowed.Scope != GlobalScope {
+			captured := s.capture(*shadowed)
+			shadowed = &captured
+		}
+		symbol.Shadowed = shadowed
+	}
+	return symbol
+}
+
+func (s *SymbolTable) owner() *SymbolTable {
+	if s.block {
+		return s.Outer.owner()
+	}
+	return s
+}
+
+// NumDefinitions is the number of slots needed by the scope s belongs to.
+func (s *SymbolTable) NumDefinitions() int {
+	return s.owner().numDefinitions
+}
+
+// Names returns the name bound to each slot of the scope s belongs to.
+func (s *SymbolTable) Names() []string {
+	return s.owner().names
+}
+
+// Globals maps every global name to its slot.
+func (s *SymbolTable) Globals() map[string]int {
+	globals := make(map[string]int)
+	for name, symbol := range s.store {
+		if symbol.Scope == GlobalScope {
+			globals[name] = symbol.Index
+		}
+	}
+	return globals
+}
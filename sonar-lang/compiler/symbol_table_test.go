@@ -0,0 +1,103 @@
+package compiler
+
+import "testing"
+
+func TestDefine(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	if a != (Symbol{Name: "a", Scope: GlobalScope, Index: 0}) {
+		t.Errorf("expected a to be global 0, got=%+v", a)
+	}
+
+	local := NewEnclosedSymbolTable(global, map[string]bool{"c": true}, nil)
+	b := local.Define("b")
+	if b != (Symbol{Name: "b", Scope: LocalScope, Index: 0}) {
+		t.Errorf("expected b to be local 0, got=%+v", b)
+	}
+	c := local.Define("c")
+	if c != (Symbol{Name: "c", Scope: LocalScope, Index: 1, Cell: true}) {
+		t.Errorf("expected c to be local cell 1, got=%+v", c)
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	first := NewEnclosedSymbolTable(global, map[string]bool{"b": true}, nil)
+	first.Define("b")
+
+	second := NewEnclosedSymbolTable(first, nil, nil)
+	second.Define("c")
+
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GlobalScope, Index: 0},
+		"b": {Name: "b", Scope: FreeScope, Index: 0},
+		"c": {Name: "c", Scope: LocalScope, Index: 0},
+	}
+	for name, want := range expected {
+		got, ok := second.Resolve(name)
+		if !ok {
+			t.Errorf("name %s not resolvable", name)
+			continue
+		}
+		if got != want {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", name, want, got)
+		}
+	}
+
+	if len(second.FreeSymbols) != 1 || second.FreeSymbols[0].Name != "b" {
+		t.Errorf("expected b to be the only free symbol, got=%+v", second.FreeSymbols)
+	}
+}
+
+func TestBlockSymbolTable(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	block := NewBlockSymbolTable(global)
+	i := block.DefineInBlock("i")
+	if i.Index != 1 || i.Scope != GlobalScope {
+		t.Errorf("expected i to take global slot 1, got=%+v", i)
+	}
+	block.Define("b")
+
+	if _, ok := global.Resolve("i"); ok {
+		t.Errorf("expected i to be invisible outside its block")
+	}
+	if _, ok := global.Resolve("b"); !ok {
+		t.Errorf("expected b to be defined in the enclosing scope")
+	}
+	if global.NumDefinitions() != 3 {
+		t.Errorf("expected 3 definitions, got=%d", global.NumDefinitions())
+	}
+}
+
+func TestResolveShadow(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	first := NewEnclosedSymbolTable(global, map[string]bool{"a": true}, map[string]bool{"a": true, "b": true})
+	a, _ := first.Resolve("a")
+	if !a.Shadow || a.Scope != LocalScope || !a.Cell || a.Shadowed == nil || a.Shadowed.Scope != GlobalScope {
+		t.Errorf("expected a to be a local cell shadowing global a, got=%+v", a)
+	}
+	if first.Defined("a") {
+		t.Errorf("expected a shadow not to count as a declaration")
+	}
+	if b, _ := first.Resolve("b"); !b.Shadow || b.Shadowed != nil {
+		t.Errorf("expected b to shadow a name looked up when executed, got=%+v", b)
+	}
+
+	// a nested function reads a through the shadow of the enclosing one
+	second := NewEnclosedSymbolTable(first, nil, nil)
+	a, _ = second.Resolve("a")
+	if !a.Shadow || a.Scope != FreeScope || a.Shadowed == nil || a.Shadowed.Scope != GlobalScope {
+		t.Errorf("expected a to be a free shadow of global a, got=%+v", a)
+	}
+
+	// declaring a shadowed name keeps its slot
+	if b := first.Define("b"); b.Shadow || b.Index != 1 || first.NumDefinitions() != 2 {
+		t.Errorf("expected b to become local 1, got=%+v", b)
+	}
+}
@@ -0,0 +1,218 @@
+// Package diagnostics reports errors in Sonar programs to the people running
+// them:
+//
+//	SyntaxError: Illegal or unexpected token. Expected token to be 'IDENTIFIER', got '='.
+//	  --> ./main.sonar:1:5
+//	   |
+//	 1 | let = 3
+//	   |     ^
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+)
+
+// ANSI escape codes for the parts of a report that are highlighted
+const (
+	reset = "\x1b[0m"
+	bold  = "\x1b[1m"
+	red   = "\x1b[1;31m"
+	blue  = "\x1b[1;34m"
+	cyan  = "\x1b[36m"
+)
+
+// INPUT names source that was not read from a file
+const INPUT = "<input>"
+
+// REPEATED_FRAMES is how many of a run of identical frames a traceback shows
+// before it only counts the rest, as in deep recursion
+const REPEATED_FRAMES = 3
+
+type Renderer struct {
+	Out io.Writer
+	// Color highlights reports with ANSI escape codes
+	Color bool
+}
+
+// New returns a Renderer writing to out, in color if out is a terminal
+func New(out io.Writer) *Renderer {
+	return &Renderer{Out: out, Color: IsTerminal(out)}
+}
+
+// IsTerminal reports whether w is a terminal that can show colors: one that
+// is not a dumb terminal, unless NO_COLOR is set
+func IsTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok || len(os.Getenv("NO_COLOR")) != 0 || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Errors reports every distinct error in errs, in source order
+func (r *Renderer) Errors(errs []errors.Error) {
+	for i, err := range distinctErrors(errs) {
+		if i > 0 {
+			io.WriteString(r.Out, "\n")
+		}
+		r.Error(err)
+	}
+}
+
+// Error reports err: its type and message, where it occurred, the line it
+// occurred on with a caret under the column, and its hint
+func (r *Renderer) Error(err errors.Error) {
+	r.write(red, string(err.Type))
+	r.write(bold, ": "+err.Message)
+	io.WriteString(r.Out, "\n")
+
+	if err.Line > 0 {
+		r.write(blue, "  --> ")
+		io.WriteString(r.Out, fmt.Sprintf("%s:%d:%d\n", fileName(err.File), err.Line, err.Column))
+	}
+
+	if len(err.LineText) != 0 {
+		number := strconv.Itoa(err.Line)
+		gutter := strings.Repeat(" ", len(number)+1)
+
+		r.write(blue, gutter+" |\n")
+		r.write(blue, " "+number+" | ")
+		io.WriteString(r.Out, err.LineText+"\n")
+		r.write(blue, gutter+" | ")
+		io.WriteString(r.Out, indent(err.LineText, err.LineTextTokenPosition))
+		r.write(red, "^")
+		io.WriteString(r.Out, "\n")
+	}
+
+	if len(err.Hint) != 0 {
+		r.write(cyan, "  = hint: ")
+		io.WriteString(r.Out, err.Hint+"\n")
+	}
+}
+
+// Traceback reports the calls err propagated out of, most recent call last,
+// quoting each line from source when the frame is in file. Runs of identical
+// frames are shown REPEATED_FRAMES times, followed by how many more there are.
+func (r *Renderer) Traceback(err *object.Error, file string, source string) {
+	if len(err.Traceback) == 0 {
+		return
+	}
+
+	io.WriteString(r.Out, "Traceback (most recent call last):\n")
+	repeated := 0
+	for i := len(err.Traceback) - 1; i >= 0; i-- {
+		frame := err.Traceback[i]
+		if i < len(err.Traceback)-1 && frame == err.Traceback[i+1] {
+			repeated++
+		} else {
+			r.repeatedFrames(repeated)
+			repeated = 0
+		}
+		if repeated >= REPEATED_FRAMES {
+			continue
+		}
+
+		io.WriteString(r.Out, fmt.Sprintf("  File \"%s\", line %d, in %s\n", fileName(frame.File), frame.Line, frame.Function))
+
+		if frame.File == file {
+			if text := strings.TrimSpace(errors.LineText(source, frame.Line)); len(text) != 0 {
+				io.WriteString(r.Out, fmt.Sprintf("    %s\n", text))
+			}
+		}
+	}
+	r.repeatedFrames(repeated)
+	io.WriteString(r.Out, "\n")
+}
+
+// repeatedFrames reports how many frames were left out of a run in which
+// the same frame repeated the given number of times after its first
+func (r *Renderer) repeatedFrames(repeated int) {
+	if more := repeated - (REPEATED_FRAMES - 1); more > 0 {
+		io.WriteString(r.Out, fmt.Sprintf("  [Previous line repeated %d more times]\n", more))
+	}
+}
+
+// RuntimeError reports err, raised by the program read from file, the calls
+// it propagated out of and the errors raised along with it
+func (r *Renderer) RuntimeError(err *object.Error, file string, source string) {
+	r.Traceback(err, file, source)
+
+	conf, ok := err.Conf.(errors.Error)
+	if !ok {
+		io.WriteString(r.Out, err.Inspect()+"\n")
+		return
+	}
+	// runtime errors only know their position, so look up the line they occurred on
+	if len(conf.LineText) == 0 && conf.File == file {
+		conf.LineText = errors.LineText(source, conf.Line)
+	}
+	r.Error(conf)
+	for _, related := range err.Related {
+		io.WriteString(r.Out, "\n")
+		r.Error(related)
+	}
+}
+
+// fileName returns how text reports refer to the file at path: as
+// relativePath does, or INPUT if there is no file
+func fileName(path string) string {
+	if len(path) == 0 {
+		return INPUT
+	}
+	return relativePath(path)
+}
+
+// write writes s in the given color, if r uses them
+func (r *Renderer) write(color string, s string) {
+	if r.Color {
+		s = color + s + reset
+	}
+	io.WriteString(r.Out, s)
+}
+
+// indent returns the whitespace that lines a caret up under the byte at
+// offset in line, keeping its tabs so that it lines up however wide they are
+func indent(line string, offset int) string {
+	if offset > len(line) {
+		offset = len(line)
+	}
+
+	var out strings.Builder
+	for _, ch := range line[:offset] {
+		if ch == '\t' {
+			out.WriteRune('\t')
+		} else {
+			out.WriteRune(' ')
+		}
+	}
+	return out.String()
+}
+
+// distinctErrors drops repeated errors and sorts the rest by position
+func distinctErrors(errs []errors.Error) []errors.Error {
+	seen := map[errors.Error]bool{}
+	distinct := []errors.Error{}
+	for _, e := range errs {
+		if !seen[e] {
+			seen[e] = true
+			distinct = append(distinct, e)
+		}
+	}
+
+	sort.SliceStable(distinct, func(i, j int) bool {
+		if distinct[i].Line != distinct[j].Line {
+			return distinct[i].Line < distinct[j].Line
+		}
+		return distinct[i].Column < distinct[j].Column
+	})
+	return distinct
+}
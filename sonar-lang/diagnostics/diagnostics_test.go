@@ -0,0 +1,117 @@
+package diagnostics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+)
+
+func TestError(t *testing.T) {
+	err := errors.NewError(errors.ErrorConfig{
+		Line:                  12,
+		Column:                7,
+		Message:               "Identifier 'b' has not been defined",
+		Hint:                  "Declare b with let",
+		LineText:              "\tlet a = b",
+		LineTextTokenPosition: 9,
+	}, errors.REFERENCE_ERROR)
+
+	var out strings.Builder
+	(&Renderer{Out: &out}).Error(err)
+	expected := `ReferenceError: Identifier 'b' has not been defined
+  --> <input>:12:7
+    |
+ 12 | 	let a = b
+    | 	        ^
+  = hint: Declare b with let
+`
+	if out.String() != expected {
+		t.Errorf("wrong report. expected=\n%s\ngot=\n%s", expected, out.String())
+	}
+
+	out.Reset()
+	(&Renderer{Out: &out, Color: true}).Error(err)
+	if !strings.HasPrefix(out.String(), red+"ReferenceError"+reset) {
+		t.Errorf("expected the error type to be highlighted, got=%q", out.String())
+	}
+}
+
+func TestErrors(t *testing.T) {
+	second := errors.NewError(errors.ErrorConfig{Line: 2, Column: 1, Message: "second"}, errors.SYNTAX_ERROR)
+	first := errors.NewError(errors.ErrorConfig{Line: 1, Column: 3, Message: "first"}, errors.SYNTAX_ERROR)
+
+	var out strings.Builder
+	(&Renderer{Out: &out}).Errors([]errors.Error{second, first, second})
+	expected := "SyntaxError: first\n  --> <input>:1:3\n\nSyntaxError: second\n  --> <input>:2:1\n"
+	if out.String() != expected {
+		t.Errorf("expected distinct errors in source order, got=%q", out.String())
+	}
+}
+
+func TestRuntimeError(t *testing.T) {
+	source := "let f = func() {\n  1 + \"a\"\n}\nf()"
+	err := &object.Error{
+		Conf: errors.NewError(errors.ErrorConfig{
+			Line:                  2,
+			Column:                3,
+			Message:               "Cannot add",
+			LineTextTokenPosition: 2,
+		}, errors.TYPE_ERROR),
+		Traceback: []object.Frame{
+			{Function: "f", Line: 2},
+			{Function: object.MODULE_FRAME, Line: 4},
+		},
+	}
+
+	var out strings.Builder
+	(&Renderer{Out: &out}).RuntimeError(err, "", source)
+	expected := `Traceback (most recent call last):
+  File "<input>", line 4, in <module>
+    f()
+  File "<input>", line 2, in f
+    1 + "a"
+
+TypeError: Cannot add
+  --> <input>:2:3
+   |
+ 2 |   1 + "a"
+   |   ^
+`
+	if out.String() != expected {
+		t.Errorf("wrong report. expected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestTracebackCollapsesRepeatedFrames(t *testing.T) {
+	source := "let f = func(n) {\n  if (n == 0) { 1 + \"a\" }\n  f(n - 1)\n}\nf(5)"
+	recursion := object.Frame{Function: "f", Line: 3}
+	err := &object.Error{
+		Traceback: []object.Frame{
+			{Function: "f", Line: 2},
+			recursion, recursion, recursion, recursion, recursion,
+			{Function: object.MODULE_FRAME, Line: 5},
+		},
+	}
+
+	var out strings.Builder
+	(&Renderer{Out: &out}).Traceback(err, "", source)
+	expected := `Traceback (most recent call last):
+  File "<input>", line 5, in <module>
+    f(5)
+  File "<input>", line 3, in f
+    f(n - 1)
+  File "<input>", line 3, in f
+    f(n - 1)
+  File "<input>", line 3, in f
+    f(n - 1)
+  [Previous line repeated 2 more times]
+  File "<input>", line 2, in f
+    if (n == 0) { 1 + "a" }
+
+`
+	if out.String() != expected {
+		t.Errorf("wrong traceback. expected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,166 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+)
+
+// Diagnostic is an error as WriteJSON reports it
+type Diagnostic struct {
+	File    string `json:"file,omitempty"` // relative to the working directory when inside it
+	Line    int    `json:"line"`           // one-based
+	Column  int    `json:"column"`         // one-based, in bytes
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// WriteJSON writes every distinct error in errs to out, in source order, as
+// {"diagnostics": [...]}
+func WriteJSON(out io.Writer, errs []errors.Error) error {
+	diagnostics := []Diagnostic{}
+	for _, err := range distinctErrors(errs) {
+		diagnostics = append(diagnostics, Diagnostic{
+			File:    relativePath(err.File),
+			Line:    err.Line,
+			Column:  err.Column,
+			Type:    string(err.Type),
+			Message: err.Message,
+			Hint:    err.Hint,
+		})
+	}
+	return writeJSON(out, map[string][]Diagnostic{"diagnostics": diagnostics})
+}
+
+// The subset of SARIF 2.1.0 WriteSARIF writes. Field names follow the
+// specification so they marshal to the format unchanged.
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// WriteSARIF writes every distinct error in errs to out, in source order, as
+// a SARIF log with one rule per type of error
+func WriteSARIF(out io.Writer, errs []errors.Error) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "sonar-lang",
+			InformationURI: "https://github.com/icheka/sonar-lang",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	rules := map[errors.ErrorType]bool{}
+	for _, err := range distinctErrors(errs) {
+		if !rules[err.Type] {
+			rules[err.Type] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: string(err.Type)})
+		}
+
+		result := sarifResult{RuleID: string(err.Type), Level: "error", Message: sarifMessage{Text: err.Message}}
+		if len(err.File) != 0 {
+			location := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: artifactURI(err.File)}}
+			if err.Line > 0 {
+				location.Region = &sarifRegion{StartLine: err.Line, StartColumn: err.Column}
+			}
+			result.Locations = []sarifLocation{{PhysicalLocation: location}}
+		}
+		if len(err.Hint) != 0 {
+			result.Properties = map[string]string{"hint": err.Hint}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	return writeJSON(out, sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// artifactURI returns the URI SARIF locates the file at path by: relative to
+// the working directory when it is inside it, so that it matches the paths
+// of the repository being checked, otherwise absolute
+func artifactURI(path string) string {
+	if rel := relativePath(path); !filepath.IsAbs(rel) {
+		return filepath.ToSlash(rel)
+	}
+	return "file://" + filepath.ToSlash(path)
+}
+
+// relativePath returns path relative to the working directory if it is
+// inside it, and path unchanged otherwise
+func relativePath(path string) string {
+	if len(path) == 0 || !filepath.IsAbs(path) {
+		return path
+	}
+	if pwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(pwd, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return rel
+		}
+	}
+	return path
+}
+
+func writeJSON(out io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
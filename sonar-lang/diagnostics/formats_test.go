@@ -0,0 +1,114 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+)
+
+func testErrors() []errors.Error {
+	pwd, _ := os.Getwd()
+	file := filepath.Join(pwd, "main.sonar")
+	return []errors.Error{
+		errors.NewError(errors.ErrorConfig{File: file, Line: 3, Column: 1, Message: "b"}, errors.REFERENCE_ERROR),
+		errors.NewError(errors.ErrorConfig{File: file, Line: 1, Column: 5, Message: "a", Hint: "h"}, errors.SYNTAX_ERROR),
+		errors.NewError(errors.ErrorConfig{File: "/elsewhere/lib.sonar", Line: 2, Column: 2, Message: "c"}, errors.SYNTAX_ERROR),
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var out strings.Builder
+	if err := WriteJSON(&out, testErrors()); err != nil {
+		t.Fatal(err)
+	}
+
+	var report struct{ Diagnostics []Diagnostic }
+	if err := json.Unmarshal([]byte(out.String()), &report); err != nil {
+		t.Fatalf("invalid JSON %q: %s", out.String(), err)
+	}
+	expected := []Diagnostic{
+		{File: "main.sonar", Line: 1, Column: 5, Type: "SyntaxError", Message: "a", Hint: "h"},
+		{File: "/elsewhere/lib.sonar", Line: 2, Column: 2, Type: "SyntaxError", Message: "c"},
+		{File: "main.sonar", Line: 3, Column: 1, Type: "ReferenceError", Message: "b"},
+	}
+	if len(report.Diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got=%+v", len(expected), report.Diagnostics)
+	}
+	for i, d := range report.Diagnostics {
+		if d != expected[i] {
+			t.Errorf("diagnostic %d wrong. expected=%+v, got=%+v", i, expected[i], d)
+		}
+	}
+
+	out.Reset()
+	WriteJSON(&out, nil)
+	if strings.Join(strings.Fields(out.String()), "") != `{"diagnostics":[]}` {
+		t.Errorf("expected an empty list of diagnostics, got=%q", out.String())
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var out strings.Builder
+	if err := WriteSARIF(&out, testErrors()); err != nil {
+		t.Fatal(err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal([]byte(out.String()), &log); err != nil {
+		t.Fatalf("invalid JSON %q: %s", out.String(), err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("expected one SARIF 2.1.0 run, got=%+v", log)
+	}
+
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 || run.Tool.Driver.Rules[0].ID != "SyntaxError" || run.Tool.Driver.Rules[1].ID != "ReferenceError" {
+		t.Errorf("expected a rule per type of error, got=%+v", run.Tool.Driver.Rules)
+	}
+	if len(run.Results) != 3 {
+		t.Fatalf("expected 3 results, got=%+v", run.Results)
+	}
+
+	first := run.Results[0]
+	location := first.Locations[0].PhysicalLocation
+	if first.RuleID != "SyntaxError" || first.Level != "error" || first.Message.Text != "a" || first.Properties["hint"] != "h" {
+		t.Errorf("first result wrong. got=%+v", first)
+	}
+	if location.ArtifactLocation.URI != "main.sonar" || *location.Region != (sarifRegion{StartLine: 1, StartColumn: 5}) {
+		t.Errorf("first location wrong. got=%+v %+v", location.ArtifactLocation, location.Region)
+	}
+	if uri := run.Results[1].Locations[0].PhysicalLocation.ArtifactLocation.URI; uri != "file:///elsewhere/lib.sonar" {
+		t.Errorf("expected files outside the working directory to have absolute URIs, got=%q", uri)
+	}
+}
+
+func TestRelativePath(t *testing.T) {
+	pwd, _ := os.Getwd()
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{filepath.Join(pwd, "main.sonar"), "main.sonar"},
+		{filepath.Join(pwd, "lib", "math.sonar"), filepath.Join("lib", "math.sonar")},
+		{pwd + "tmp/tb.sonar", pwd + "tmp/tb.sonar"},
+		{filepath.Join(pwd, "..", "sibling.sonar"), filepath.Join(pwd, "..", "sibling.sonar")},
+		{"main.sonar", "main.sonar"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := relativePath(tt.path); got != tt.expected {
+			t.Errorf("wrong path for %q. expected=%q, got=%q", tt.path, tt.expected, got)
+		}
+	}
+
+	var out strings.Builder
+	(&Renderer{Out: &out}).Error(errors.NewError(errors.ErrorConfig{File: pwd + "tmp/tb.sonar", Line: 1, Column: 1, Message: "a"}, errors.SYNTAX_ERROR))
+	if !strings.Contains(out.String(), "--> "+pwd+"tmp/tb.sonar:1:1") {
+		t.Errorf("expected a file sharing a prefix with the working directory to keep its path, got=%q", out.String())
+	}
+}
@@ -2,12 +2,12 @@ package errors
 
 import "fmt"
 
-func NewAssignmentError(msg string) Error {
-	conf := ErrorConfig{Message: msg}
+func NewAssignmentError(msg string, conf ErrorConfig) Error {
+	conf.Message = msg
 	return NewError(conf, ASSIGNMENT_ERROR)
 }
 
-func ConstantAssignmentError(id string) Error {
+func ConstantAssignmentError(id string, conf ErrorConfig) Error {
 	msg := fmt.Sprintf("Illegal assignment to constant '%s'", id)
-	return NewAssignmentError(msg)
+	return NewAssignmentError(msg, conf)
 }
@@ -1,6 +1,11 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/icheka/sonar-lang/sonar-lang/token"
+)
 
 const (
 	SYNTAX_ERROR     = "SyntaxError"
@@ -9,6 +14,9 @@ const (
 	TYPE_ERROR       = "TypeError"
 	ARITY_ERROR      = "ArityError"
 	ASSIGNMENT_ERROR = "AssignmentError"
+	IMPORT_ERROR     = "ImportError"
+	LIMIT_ERROR      = "LimitError"
+	THROWN_ERROR     = "Error" // the type of errors thrown by scripts, unless they give their own
 )
 
 type ErrorType string
@@ -26,6 +34,10 @@ type Error struct {
 
 func (e *Error) String() string { return fmt.Sprintf("%s: %s", e.Type, e.Message) }
 
+// Error implements the built-in error interface so an Error can be returned
+// from APIs that report failures as Go errors.
+func (e Error) Error() string { return e.String() }
+
 type ErrorConfig struct {
 	File                  string
 	Line                  int
@@ -48,3 +60,25 @@ func NewError(conf ErrorConfig, t ErrorType) Error {
 		LineTextTokenPosition: conf.LineTextTokenPosition,
 	}
 }
+
+// NewErrorConfig returns an ErrorConfig locating an error at pos
+func NewErrorConfig(pos token.Position) ErrorConfig {
+	conf := ErrorConfig{File: pos.File, Line: pos.Line, Column: pos.Column}
+	if pos.Column > 0 {
+		conf.LineTextTokenPosition = pos.Column - 1
+	}
+	return conf
+}
+
+// LineText returns the one-based line of source, without its line terminator
+func LineText(source string, line int) string {
+	if line < 1 {
+		return ""
+	}
+
+	lines := strings.SplitN(source, "\n", line+1)
+	if line > len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[line-1], "\r")
+}
@@ -0,0 +1,27 @@
+package errors
+
+import (
+	"fmt"
+	"strings"
+)
+
+func NewImportError(msg string, conf ErrorConfig) Error {
+	conf.Message = msg
+	return NewError(conf, IMPORT_ERROR)
+}
+
+func ModuleNotFoundError(module, path string, conf ErrorConfig) Error {
+	msg := fmt.Sprintf("Cannot find module '%s' (looked for %s)", module, path)
+	return NewImportError(msg, conf)
+}
+
+func ImportCycleError(cycle []string, conf ErrorConfig) Error {
+	msg := fmt.Sprintf("Import cycle: %s", strings.Join(cycle, " -> "))
+	conf.Hint = "Move the bindings the modules share into a module that imports neither of them"
+	return NewImportError(msg, conf)
+}
+
+func NotExportedError(module, name string, conf ErrorConfig) Error {
+	msg := fmt.Sprintf("Module '%s' does not export '%s'", module, name)
+	return NewImportError(msg, conf)
+}
@@ -0,0 +1,30 @@
+package errors
+
+import "fmt"
+
+func NewLimitError(msg string) Error {
+	conf := ErrorConfig{Message: msg}
+	return NewError(conf, LIMIT_ERROR)
+}
+
+func StepLimitExceededError(max int64) Error {
+	return NewLimitError(fmt.Sprintf("Execution exceeded the limit of %d steps", max))
+}
+
+func CallDepthExceededError(max int) Error {
+	return NewLimitError(fmt.Sprintf("Maximum call depth of %d exceeded", max))
+}
+
+func ElementLimitExceededError(max int64) Error {
+	return NewLimitError(fmt.Sprintf("Execution exceeded the limit of %d allocated elements", max))
+}
+
+func StringBytesLimitExceededError(max int64) Error {
+	return NewLimitError(fmt.Sprintf("Execution exceeded the limit of %d allocated string bytes", max))
+}
+
+// ExecutionCancelledError is raised when the context a program runs in is
+// cancelled, or its deadline passes
+func ExecutionCancelledError(reason error) Error {
+	return NewLimitError(fmt.Sprintf("Execution stopped: %s", reason))
+}
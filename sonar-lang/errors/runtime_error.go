@@ -21,3 +21,61 @@ func ZeroDivisionError(t string) Error {
 	msg := fmt.Sprintf("Division by zero (%s/0)", t)
 	return NewRuntimeError(msg)
 }
+
+func StackOverflowError() Error {
+	return NewRuntimeError("Maximum call stack size exceeded")
+}
+
+func ReduceOfEmptyArrayError() Error {
+	return NewRuntimeError("Cannot reduce an empty array without an initial value")
+}
+
+func ComparatorResultError(fn, given string) Error {
+	msg := fmt.Sprintf("Comparator given to '%s' must return a number or a boolean, '%s' given", fn, given)
+	return NewRuntimeError(msg)
+}
+
+func EmptyArgumentsError(fn string) Error {
+	msg := fmt.Sprintf("'%s' requires at least one number", fn)
+	return NewRuntimeError(msg)
+}
+
+func InvalidClampBoundsError(lo, hi string) Error {
+	msg := fmt.Sprintf("Lower bound given to 'clamp' (%s) is greater than its upper bound (%s)", lo, hi)
+	return NewRuntimeError(msg)
+}
+
+func InvalidJSONError(detail string) Error {
+	msg := fmt.Sprintf("Invalid JSON: %s", detail)
+	return NewRuntimeError(msg)
+}
+
+func UnserializableValueError(value string) Error {
+	msg := fmt.Sprintf("Cannot convert %s to JSON", value)
+	return NewRuntimeError(msg)
+}
+
+func CyclicValueError(t string) Error {
+	msg := fmt.Sprintf("Cannot convert %s to JSON: it contains itself", t)
+	return NewRuntimeError(msg)
+}
+
+func UnserializableKeyError(key string, t string) Error {
+	msg := fmt.Sprintf("Cannot convert map key %s to JSON: keys must be STRINGs, %s given", key, t)
+	return NewRuntimeError(msg)
+}
+
+func StringTooLongError(fn string, max int64) Error {
+	msg := fmt.Sprintf("String made by '%s' would be longer than %d bytes", fn, max)
+	return NewRuntimeError(msg)
+}
+
+func InternalError(reason interface{}) Error {
+	msg := fmt.Sprintf("Internal error: %v", reason)
+	return NewRuntimeError(msg)
+}
+
+func IntegerOverflowError(fn, given string) Error {
+	msg := fmt.Sprintf("Result of '%s' for %s does not fit in an INTEGER", fn, given)
+	return NewRuntimeError(msg)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/icheka/sonar-lang/sonar-lang/keys"
 	"github.com/icheka/sonar-lang/sonar-lang/token"
 )
 
@@ -23,7 +24,10 @@ func IllegalTokenError(t string, conf ErrorConfig) Error {
 }
 
 func NoPrefixParseFnError(s string, t token.TokenType, conf ErrorConfig) Error {
-	conf.Hint = fmt.Sprintf("No prefix parse function for %s found", t)
+	// only of interest to those working on the parser
+	if keys.Keys.MODE == "DEV" {
+		conf.Hint = fmt.Sprintf("No prefix parse function for %s found", t)
+	}
 	return IllegalTokenError(s, conf)
 }
 
@@ -97,3 +101,23 @@ func UnacceptableLHSInPostfixExpression(operator, left string, conf ErrorConfig)
 	msg := fmt.Sprintf("Unacceptable type on left-hand side of postfix expression: '%s%s'", operator, left)
 	return NewSyntaxError(msg, conf)
 }
+
+func IllegalStatementOutsideLoopError(keyword string, conf ErrorConfig) Error {
+	msg := fmt.Sprintf("Illegal '%s' statement outside of a loop", keyword)
+	return NewSyntaxError(msg, conf)
+}
+
+func StatementNotAtTopLevelError(keyword string, conf ErrorConfig) Error {
+	msg := fmt.Sprintf("'%s' statements are only allowed at the top level of a file", keyword)
+	return NewSyntaxError(msg, conf)
+}
+
+func InvalidEscapeSequenceError(sequence string, conf ErrorConfig) Error {
+	msg := fmt.Sprintf("Invalid escape sequence '%s' in string", sequence)
+	conf.Hint = `Supported escapes are \n, \t, \r, \0, \", \\, \$ and \u{...}`
+	return NewSyntaxError(msg, conf)
+}
+
+func UnterminatedStringError(conf ErrorConfig) Error {
+	return NewSyntaxError("Unterminated string literal", conf)
+}
@@ -0,0 +1,8 @@
+package errors
+
+// NewThrownError returns the error raised by a `throw` statement. Scripts may
+// give it any type, so that they can tell their own errors apart.
+func NewThrownError(t ErrorType, msg string, conf ErrorConfig) Error {
+	conf.Message = msg
+	return NewError(conf, t)
+}
@@ -9,7 +9,7 @@ import (
 
 var ArrayBuiltins = map[string]*object.Builtin{
 	"push": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) < 2 {
 				return NewError(errors.RequiresXArgumentsError(2, len(args), "push"))
 			}
@@ -26,7 +26,7 @@ var ArrayBuiltins = map[string]*object.Builtin{
 		},
 	},
 	"pop": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) < 1 {
 				return NewError(errors.RequiresAtLeastXArgumentsError("pop", 1, len(args)))
 			}
@@ -59,7 +59,7 @@ var ArrayBuiltins = map[string]*object.Builtin{
 func arrayContains(obj *object.Array, elm object.Object) bool {
 	elements := obj.Elements
 	for _, v := range elements {
-		if v.Inspect() == elm.Inspect() && v.Type() == elm.Type() {
+		if object.Equal(v, elm) {
 			return true
 		}
 	}
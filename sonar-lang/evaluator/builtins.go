@@ -3,7 +3,12 @@ package evaluator
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"math"
+	"os"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/icheka/sonar-lang/sonar-lang/errors"
 	"github.com/icheka/sonar-lang/sonar-lang/object"
@@ -12,7 +17,7 @@ import (
 
 var builtins = map[string]*object.Builtin{
 	"len": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return NewError(errors.RequiresXArgumentsError(1, len(args), "len"))
 			}
@@ -21,7 +26,7 @@ var builtins = map[string]*object.Builtin{
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			case *object.Hash:
 				return &object.Integer{Value: int64(len(arg.Pairs))}
 			default:
@@ -29,23 +34,9 @@ var builtins = map[string]*object.Builtin{
 			}
 		},
 	},
-	"print": {
-		Fn: func(args ...object.Object) object.Object {
-			arr := []string{}
-			for _, arg := range args {
-				if arg.Type() == object.STRING_OBJ {
-					arr = append(arr, arg.(*object.String).FormattedInspect())
-				} else {
-					arr = append(arr, arg.Inspect())
-				}
-			}
-			fmt.Println(strings.Join(arr, ", "))
-
-			return NULL
-		},
-	},
+	"print": NewPrintBuiltin(os.Stdout),
 	"slice": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			/*
 				slice(arr)
 				-- returns a copy of arr
@@ -72,13 +63,7 @@ var builtins = map[string]*object.Builtin{
 			case object.ARRAY_OBJ:
 				return SliceArray(args...)
 			case object.STRING_OBJ:
-				arr := strings.Split(obj.(*object.String).Value, "")
-				objs := []object.Object{}
-				for _, v := range arr {
-					objs = append(objs, &object.String{Value: v})
-				}
-				args[0] = &object.Array{Elements: objs}
-				// newArr := SliceArray(&object.Array{Elements: objs}, args[])
+				args[0] = &object.Array{Elements: obj.(*object.String).Iters()}
 				newArr := SliceArray(args...)
 				if newArr.Type() == object.ERROR_OBJ {
 					return newArr
@@ -95,7 +80,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"contains": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return NewError(errors.RequiresXArgumentsError(2, len(args), "contains"))
 			}
@@ -113,7 +98,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"copy": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return NewError(errors.RequiresXArgumentsError(1, len(args), "copy"))
 			}
@@ -133,18 +118,23 @@ var builtins = map[string]*object.Builtin{
 				return &object.String{Value: obj.(*object.String).Value}
 
 			case object.FUNCTION_OBJ:
-				fn := obj.(*object.Function)
-				return &object.Function{
-					Parameters: fn.Parameters,
-					Body:       fn.Body,
-					Env:        fn.Env,
+				switch fn := obj.(type) {
+				case *object.Function:
+					return &object.Function{
+						Parameters: fn.Parameters,
+						Body:       fn.Body,
+						Env:        fn.Env,
+					}
+				case *object.Closure:
+					return &object.Closure{Fn: fn.Fn, Free: fn.Free, Unit: fn.Unit}
 				}
+				return NewError(errors.TypeCannotBeCopiedError(string(obj.Type())))
 
 			case object.ARRAY_OBJ:
 				return &object.Array{Elements: obj.(*object.Array).Elements}
 
 			case object.HASH_OBJ:
-				return &object.Hash{Pairs: obj.(*object.Hash).Pairs}
+				return obj.(*object.Hash).Copy()
 
 			default:
 				return NewError(errors.TypeCannotBeCopiedError(string(obj.Type())))
@@ -152,7 +142,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"type": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return NewError(errors.RequiresXArgumentsError(1, len(args), "type"))
 			}
@@ -162,7 +152,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"index": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return NewError(errors.RequiresXArgumentsError(2, len(args), "index"))
 			}
@@ -171,10 +161,7 @@ var builtins = map[string]*object.Builtin{
 			case object.ARRAY_OBJ:
 				return ArrayIndexOf(args[0].(*object.Array), args[1])
 			case object.STRING_OBJ:
-				elements := []object.Object{}
-				for _, v := range strings.Split(args[0].(*object.String).Value, "") {
-					elements = append(elements, &object.String{Value: v})
-				}
+				elements := args[0].(*object.String).Iters()
 				return ArrayIndexOf(&object.Array{Elements: elements}, args[1])
 
 			default:
@@ -183,7 +170,10 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"sort": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			if len(args) == 2 {
+				return sortWithComparator(caller, args...)
+			}
 			if len(args) != 1 {
 				return NewError(errors.RequiresXArgumentsError(1, len(args), "sort"))
 			}
@@ -197,11 +187,10 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"reverse": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return NewError(errors.RequiresXArgumentsError(1, len(args), "reverse"))
 			}
-			fmt.Println(args[0].Inspect())
 			switch args[0].Type() {
 			case object.ARRAY_OBJ:
 				return &object.Array{Elements: utils.ReverseSlice(args[0].(*object.Array).Elements)}
@@ -212,7 +201,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"range": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
 			if len(args) < 2 {
 				return NewError(errors.RequiresAtLeastXArgumentsError("range", len(args), 2))
 			}
@@ -239,6 +228,9 @@ var builtins = map[string]*object.Builtin{
 			// if start == end, return []
 			// if step is a negative number, start must be > end
 			// if step is a positive number, start must be < end
+			if St == 0 {
+				return NewError(errors.ArgumentToXMustBeYError("step", "range", "a non-zero INTEGER", step.Inspect()))
+			}
 			if S == E {
 				return &object.Array{Elements: []object.Object{}}
 			}
@@ -249,20 +241,14 @@ var builtins = map[string]*object.Builtin{
 				return &object.Array{Elements: []object.Object{}}
 			}
 
-			result := []int64{}
-			if St > 0 {
-				for i := S; i < E; i += St {
-					result = append(result, i)
-				}
-			} else {
-				for i := S; i > E; i += St {
-					result = append(result, i)
-				}
+			n := rangeLength(S, E, St)
+			if err := caller.Allocate(n, 0); err != nil {
+				return err
 			}
 
-			arr := []object.Object{}
-			for _, v := range result {
-				arr = append(arr, &object.Integer{Value: v})
+			arr := make([]object.Object, 0, n)
+			for i := int64(0); i < n; i++ {
+				arr = append(arr, &object.Integer{Value: S + i*St})
 			}
 
 			return &object.Array{Elements: arr}
@@ -270,24 +256,100 @@ var builtins = map[string]*object.Builtin{
 	},
 }
 
+// rangeLength returns the number of elements of range(start, end, step),
+// where step is not 0 and moves start towards end
+func rangeLength(start, end, step int64) int64 {
+	// the distance can exceed the largest int64, but not the largest uint64
+	distance, stride := uint64(end)-uint64(start), uint64(step)
+	if step < 0 {
+		distance, stride = uint64(start)-uint64(end), -uint64(step)
+	}
+
+	n := (distance-1)/stride + 1
+	if n > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(n)
+}
+
+// NewPrintBuiltin returns the print builtin, writing to out
+func NewPrintBuiltin(out io.Writer) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			arr := []string{}
+			for _, arg := range args {
+				if arg.Type() == object.STRING_OBJ {
+					arr = append(arr, arg.(*object.String).FormattedInspect())
+				} else {
+					arr = append(arr, arg.Inspect())
+				}
+			}
+			fmt.Fprintln(out, strings.Join(arr, ", "))
+
+			return NULL
+		},
+	}
+}
+
+var initStdlib sync.Once
+
+// InitStdlib adds the standard library to the default builtins
 func InitStdlib() {
-	var stdlibFunctions = []map[string]*object.Builtin{
+	initStdlib.Do(func() {
+		for _, f := range stdlib() {
+			for k, v := range f {
+				builtins[k] = v
+			}
+		}
+	})
+}
+
+func stdlib() []map[string]*object.Builtin {
+	return []map[string]*object.Builtin{
 		ArrayBuiltins,
 		MapBuiltins,
+		MathBuiltins,
+		StringBuiltins,
 		TypesBuiltins,
+		FunctionalBuiltins,
+	}
+}
+
+// constants are the values other than builtins that every program can use
+var constants = []map[string]object.Object{
+	MathConstants,
+	{"json": JSONModule},
+}
+
+// LookupConstant returns the value of a constant such as PI or json
+func LookupConstant(name string) (object.Object, bool) {
+	for _, table := range constants {
+		if val, ok := table[name]; ok {
+			return val, true
+		}
 	}
+	return nil, false
+}
 
-	for _, f := range stdlibFunctions {
+// NewBuiltins returns a copy of the default builtins and the standard
+// library, for a program to be given its own with Environment.SetBuiltins
+func NewBuiltins() map[string]*object.Builtin {
+	table := make(map[string]*object.Builtin)
+	for k, v := range builtins {
+		table[k] = v
+	}
+	for _, f := range stdlib() {
 		for k, v := range f {
-			builtins[k] = v
+			table[k] = v
 		}
 	}
+	return table
 }
 
 func ArrayIndexOf(arr *object.Array, element object.Object) *object.Integer {
 	elements := arr.Elements
 	for i, v := range elements {
-		if v.Inspect() == element.Inspect() && v.Type() == element.Type() {
+		if object.Equal(v, element) {
 			return &object.Integer{Value: int64(i)}
 		}
 	}
@@ -18,6 +18,8 @@ func TestRangeBuiltin(t *testing.T) {
 		{"range(0, 5, -2)", "[]"},
 		{"range(10, 5, -2)", "[10, 8, 6]"},
 		{"range(-4, -2)", "[-4, -3]"},
+		{"range(0, 7, 3)", "[0, 3, 6]"},
+		{"range(5, -2, -3)", "[5, 2, -1]"},
 	}
 
 	for _, tt := range tests {
@@ -26,6 +28,7 @@ func TestRangeBuiltin(t *testing.T) {
 
 	tests2 := []string{
 		"range(0)",
+		"range(0, 5, 0)",
 	}
 	for _, tt := range tests2 {
 		evaluated := testEval(tt)
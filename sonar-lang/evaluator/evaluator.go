@@ -2,6 +2,7 @@ package evaluator
 
 import (
 	"fmt"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -20,6 +21,15 @@ var (
 	CONTINUE = &object.Continue{}
 )
 
+// assignmentOperators maps compound assignment operators to the infix
+// operators they apply
+var assignmentOperators = map[token.TokenType]token.TokenType{
+	token.PLUS_ASSIGN:     token.PLUS,
+	token.MINUS_ASSIGN:    token.MINUS,
+	token.ASTERISK_ASSIGN: token.ASTERISK,
+	token.SLASH_ASSIGN:    token.SLASH,
+}
+
 func Eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 
@@ -49,35 +59,40 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return val
 		}
 		if _, ok := env.Store[node.Name.Value]; ok {
-			return NewError(errors.IdentifierAlreadyDefinedError(node.Name.Value, node.TokenInfo.(errors.ErrorConfig)))
+			return NewError(errors.IdentifierAlreadyDefinedError(node.Name.Value, errorConfig(node.Name)))
+		}
+		if fn, ok := val.(*object.Function); ok && len(fn.Name) == 0 {
+			fn.Name = node.Name.Value
+		}
+		if node.Constant {
+			return env.SetConstant(node.Name.Value, val)
 		}
 		return env.Set(node.Name.Value, val)
 
 	case *ast.AssignmentExpression:
+		if env.IsConstant(node.Identifier.Value) {
+			return NewError(errors.ConstantAssignmentError(node.Identifier.Value, errorConfig(node)))
+		}
 		right := Eval(node.Value, env)
 		if isError(right) {
 			return right
 		}
 		oldValue, ok := env.Get(node.Identifier.Value)
 		if !ok {
-			r, _ := node.TokenInfo.(errors.ErrorConfig)
+			r := errorConfig(node)
 			return NewError(errors.IdentifierNotDefinedError(node.Identifier.Value, r))
 		}
 
 		result := right
 
 		if node.Operator != token.ASSIGN {
-			operators := map[token.TokenType]token.TokenType{
-				(token.PLUS_ASSIGN):     token.PLUS,
-				(token.MINUS_ASSIGN):    token.MINUS,
-				(token.ASTERISK_ASSIGN): token.ASTERISK,
-				(token.SLASH_ASSIGN):    token.SLASH,
-			}
-
 			// re-use evalInfixExpression...
 			// ... for example, if node.Operator is token.PLUS_ASSIGN, this will evaluate oldValue + right and return to result
-			t := ast.InfixExpression{TokenInfo: node.TokenInfo}
-			result = evalInfixExpression(string(operators[token.TokenType(node.Operator)]), oldValue, right, t)
+			operator := string(assignmentOperators[token.TokenType(node.Operator)])
+			if err := reserveInfix(env, operator, oldValue, right); err != nil {
+				return withPosition(err, node)
+			}
+			result = evalInfixExpression(operator, oldValue, right, node)
 
 			// catch errors from evalInfixhere
 			if isError(result) {
@@ -98,6 +113,27 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.ContinueStatement:
 		return CONTINUE
 
+	case *ast.ThrowStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return evalThrowStatement(val, node)
+
+	case *ast.TryStatement:
+		return evalTryStatement(node, env)
+
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+
+	case *ast.ExportStatement:
+		val := Eval(node.Statement, env)
+		if isError(val) {
+			return val
+		}
+		env.Exports = append(env.Exports, node.Statement.Name.Value)
+		return val
+
 	// Expressions
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
@@ -108,6 +144,17 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
+	case *ast.ConcatExpression:
+		parts := evalExpressions(node.Parts, env)
+		if len(parts) == 1 && isError(parts[0]) {
+			return parts[0]
+		}
+		result := concat(parts)
+		if err := allocate(env, result); err != nil {
+			return withPosition(err, node)
+		}
+		return result
+
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 
@@ -116,7 +163,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right, *node)
+		return withPosition(evalPrefixExpression(node.Operator, right, node), node)
 
 	case *ast.InfixExpression:
 		left := Eval(node.Left, env)
@@ -129,7 +176,13 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return right
 		}
 
-		return evalInfixExpression(node.Operator, left, right, *node)
+		if err := reserveInfix(env, node.Operator, left, right); err != nil {
+			return withPosition(err, node)
+		}
+		return withPosition(evalInfixExpression(node.Operator, left, right, node), node)
+
+	case *ast.LogicalExpression:
+		return evalLogicalExpression(node, env)
 
 	case *ast.PostfixExpression:
 		return evalPostfixExpression(env, node)
@@ -146,38 +199,30 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return &object.Function{Parameters: params, Env: env, Body: body}
 
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
-		}
-
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
-		}
-
-		return applyFunction(function, args)
+		result, _ := evalChainLink(node, env)
+		return result
 
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		array := &object.Array{Elements: elements}
+		if err := allocate(env, array); err != nil {
+			return withPosition(err, node)
+		}
+		return array
 
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
-			return left
-		}
-		index := Eval(node.Index, env)
-		if isError(index) {
-			return index
-		}
-		return evalIndexExpression(left, index, node)
+		result, _ := evalChainLink(node, env)
+		return result
 
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		hash := evalHashLiteral(node, env)
+		if err := allocate(env, hash); err != nil {
+			return withPosition(err, node)
+		}
+		return hash
 
 	case *ast.SquareBracketAssignment:
 		left := Eval(node.Left, env)
@@ -193,33 +238,34 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(value) {
 			return value
 		}
-
-		switch left.Type() {
-		case object.ARRAY_OBJ:
-			if i, ok := index.(*object.Integer); !ok {
-				if r, ok := node.TokenInfo.(errors.ErrorConfig); ok {
-					return NewError(errors.UnacceptableIndexError(i.Inspect(), string(i.Type()), object.ARRAY_OBJ, r))
-				}
-			}
-			return evalArraySquareBracketExpression(&left, index, value, node)
-
-		case object.HASH_OBJ:
-			return evalMapSquareBracketExpression(&left, index, value, node)
-
-		default:
-			if r, ok := node.TokenInfo.(errors.ErrorConfig); ok {
-				return NewError(errors.UnacceptableTypeInKeyAssignmentError(string(left.Type()), r))
-			}
+		if err := reserveKey(env, left, index); err != nil {
+			return withPosition(err, node)
 		}
 
+		return evalSquareBracketAssignment(left, index, value, node)
+
 	case *ast.NullValueExpression:
-		return &object.Null{}
+		return NULL
 	}
 
 	return nil
 }
 
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	// the file being run counts as a module being evaluated, so that modules
+	// importing it are reported as a cycle
+	if file := program.Span().Start.File; len(file) > 0 {
+		if abs, err := filepath.Abs(file); err == nil && env.Modules().Begin(abs) == nil {
+			defer env.Modules().End(abs)
+		}
+	}
+
+	return evalStatements(program, env, token.Position{})
+}
+
+// evalStatements evaluates the statements of a program or module. callSite is
+// where the module was imported, or the zero position for the program.
+func evalStatements(program *ast.Program, env *object.Environment, callSite token.Position) object.Object {
 	var result object.Object
 
 	for _, statement := range program.Statements {
@@ -229,6 +275,7 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 		case *object.ReturnValue:
 			return result.Value
 		case *object.Error:
+			result.Unwind(object.MODULE_FRAME, callSite)
 			return result
 		}
 	}
@@ -263,6 +310,10 @@ func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.
 	// -- evaluate consequence
 
 	for {
+		if err := step(env, ws); err != nil {
+			return err
+		}
+
 		condition := Eval(ws.Condition, env)
 		if isError(condition) {
 			return condition
@@ -271,8 +322,11 @@ func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.
 			break
 		}
 		result := Eval(ws.Consequence, env)
-		// if there is a return, error or break, return immediately
-		if isError(result) || isBreak(result) || result.Type() == object.RETURN_VALUE_OBJ {
+		if isBreak(result) {
+			break
+		}
+		// if there is a return or error, return immediately
+		if isError(result) || isReturnValue(result) {
 			return result
 		}
 	}
@@ -285,9 +339,7 @@ func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Obje
 	iterable, ok := Eval(fs.Iterable, env).(object.Iterable)
 
 	if !ok {
-		if r, ok := fs.TokenInfo.(errors.ErrorConfig); ok {
-			return NewError(errors.NonIterableInForLoopError(r))
-		}
+		return NewError(errors.NonIterableInForLoopError(errorConfig(fs.Iterable)))
 	}
 
 	// iterable.Iters
@@ -300,6 +352,10 @@ func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Obje
 	scope := object.NewEphemeralScope(allowed, readonly, env)
 
 	for i, v := range iters {
+		if err := step(env, fs); err != nil {
+			return err
+		}
+
 		// allow the counter to be mutated only to allow setting counter to iter
 		scope.Readonly = make(map[string]bool)
 
@@ -336,8 +392,11 @@ func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Obje
 		if isContinue(result) {
 			continue
 		}
+		if isBreak(result) {
+			break
+		}
 
-		if isError(result) || isBreak(result) || result.Type() == object.RETURN_VALUE_OBJ {
+		if isError(result) || isReturnValue(result) {
 			return result
 		}
 
@@ -346,6 +405,109 @@ func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Obje
 	return &object.Null{}
 }
 
+// evalThrowStatement raises val as an error located at the throw statement.
+// Strings become the message of the error; maps may give its "message" and
+// "type". Any other value is kept on the error so that catch can return it.
+func evalThrowStatement(val object.Object, node ast.Node) *object.Error {
+	errorType := errors.ErrorType(errors.THROWN_ERROR)
+	msg := val.Inspect()
+	var value object.Object
+
+	switch val := val.(type) {
+	case *object.String:
+		msg = val.Value
+	case *object.Hash:
+		m, ok := hashGet(val, "message").(*object.String)
+		if !ok {
+			value = val
+			break
+		}
+		msg = m.Value
+		if t, ok := hashGet(val, "type").(*object.String); ok {
+			errorType = errors.ErrorType(t.Value)
+		}
+	default:
+		value = val
+	}
+
+	err := NewError(errors.NewThrownError(errorType, msg, errorConfig(node)))
+	err.Value = value
+	return err
+}
+
+func evalTryStatement(ts *ast.TryStatement, env *object.Environment) object.Object {
+	result := Eval(ts.Block, env)
+	if isLimitError(result) {
+		return result
+	}
+
+	if err, ok := result.(*object.Error); ok && ts.Catch != nil {
+		scope := env
+		if ts.Parameter != nil {
+			scope = object.NewEphemeralScope([]string{ts.Parameter.Value}, make(map[string]bool), env)
+			scope.Set(ts.Parameter.Value, caughtError(err))
+		}
+		result = Eval(ts.Catch, scope)
+	}
+
+	if ts.Finally != nil {
+		// finally runs however the try and catch blocks ended, and only
+		// overrides their result if it ends abruptly itself
+		final := Eval(ts.Finally, env)
+		if final != nil {
+			ft := final.Type()
+			if ft == object.RETURN_VALUE_OBJ || ft == object.ERROR_OBJ || ft == object.BREAK_OBJ || ft == object.CONTINUE_OBJ {
+				return final
+			}
+		}
+	}
+
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// caughtError returns the map bound to the parameter of a catch block
+func caughtError(err *object.Error) object.Object {
+	var conf errors.Error
+	if c, ok := err.Conf.(errors.Error); ok {
+		conf = c
+	}
+
+	hash := object.NewHash()
+	set := func(key string, value object.Object) {
+		k := &object.String{Value: key}
+		hash.Set(k.HashKey(), object.HashPair{Key: k, Value: value})
+	}
+
+	set("type", &object.String{Value: string(conf.Type)})
+	set("message", &object.String{Value: conf.Message})
+	set("file", &object.String{Value: conf.File})
+	set("line", &object.Integer{Value: int64(conf.Line)})
+	set("column", &object.Integer{Value: int64(conf.Column)})
+	if err.Value != nil {
+		set("value", err.Value)
+	}
+
+	return hash
+}
+
+// hashGet returns the value stored under the string key in hash, or nil
+func hashGet(hash *object.Hash, key string) object.Object {
+	pair, ok := hash.Pairs[(&object.String{Value: key}).HashKey()]
+	if !ok {
+		return nil
+	}
+	return pair.Value
+}
+
+// isNull reports whether obj is null, including the nil some statements
+// evaluate to
+func isNull(obj object.Object) bool {
+	return obj == nil || obj.Type() == object.NULL_OBJ
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return TRUE
@@ -353,73 +515,105 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return FALSE
 }
 
-func evalPrefixExpression(operator string, right object.Object, node ast.PrefixExpression) object.Object {
+func evalPrefixExpression(operator string, right object.Object, node ast.Node) object.Object {
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(right)
 	case "-":
-		return evalMinusPrefixOperatorExpression(right, &node)
+		return evalMinusPrefixOperatorExpression(right, node)
 	default:
-		r, _ := node.TokenInfo.(errors.ErrorConfig)
+		r := errorConfig(node)
 		return NewError(errors.UnknownPrefixOperatorError(operator, string(right.Type()), r))
 	}
 }
 
+// evalLogicalExpression returns the operand that decides the result: left if
+// it is falsy (for and) or truthy (for or), otherwise right. right is only
+// evaluated if it is needed.
+func evalLogicalExpression(node *ast.LogicalExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	if left == nil {
+		left = NULL
+	}
+
+	if node.Operator == token.NULLISH {
+		if !isNull(left) {
+			return left
+		}
+	} else if isTruthy(left) == (node.Operator == token.OR) {
+		return left
+	}
+
+	right := Eval(node.Right, env)
+	if right == nil {
+		return NULL
+	}
+	return right
+}
+
+// concat joins values into a string, as str() would convert each of them
+func concat(values []object.Object) *object.String {
+	var out strings.Builder
+	for _, value := range values {
+		switch value := value.(type) {
+		case *object.String:
+			out.WriteString(value.Value)
+		case nil:
+			out.WriteString(NULL.Inspect())
+		default:
+			out.WriteString(value.Inspect())
+		}
+	}
+	return &object.String{Value: out.String()}
+}
+
 func evalInfixExpression(
 	operator string,
-	left, right object.Object, node ast.InfixExpression,
+	left, right object.Object, node ast.Node,
 ) object.Object {
-	switch operator {
-	case token.AND:
-		l := isTruthy(left)
-		r := isTruthy(right)
-		return nativeBoolToBooleanObject(l && r)
-	case token.OR:
-		l := isTruthy(left)
-		r := isTruthy(right)
-		return nativeBoolToBooleanObject(l || r)
-	}
-
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-		return evalIntegerInfixExpression(operator, left, right, &node)
+		return evalIntegerInfixExpression(operator, left, right, node)
 
 	case left.Type() == object.FLOAT_OBJ && right.Type() == object.FLOAT_OBJ:
-		return evalFloatInfixExpression(operator, left, right, &node)
+		return evalFloatInfixExpression(operator, left, right, node)
 
 	// if number types are mismatched, cast the integer type to float
 	case left.Type() == object.FLOAT_OBJ && right.Type() == object.INTEGER_OBJ:
 		right = &object.Float{
 			Value: float64(right.(*object.Integer).Value),
 		}
-		return evalFloatInfixExpression(operator, left, right, &node)
+		return evalFloatInfixExpression(operator, left, right, node)
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.FLOAT_OBJ:
 		left = &object.Float{
 			Value: float64(left.(*object.Integer).Value),
 		}
-		return evalFloatInfixExpression(operator, left, right, &node)
+		return evalFloatInfixExpression(operator, left, right, node)
 
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
-		return evalStringInfixExpression(operator, left, right, &node)
-
-	case left.Type() == object.ARRAY_OBJ:
-		return evalArrayInfixExpression(operator, left, right, &node)
-
-	case left.Type() == object.HASH_OBJ:
-		return evalMapInfixExpression(operator, left, right, &node)
+		return evalStringInfixExpression(operator, left, right, node)
 
 	case operator == token.EQ:
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(object.Equal(left, right))
 
 	case operator == token.NOT_EQ:
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
+
+	case left.Type() == object.ARRAY_OBJ:
+		return evalArrayInfixExpression(operator, left, right, node)
+
+	case left.Type() == object.HASH_OBJ:
+		return evalMapInfixExpression(operator, left, right, node)
 
 	case left.Type() != right.Type():
-		r, _ := node.TokenInfo.(errors.ErrorConfig)
+		r := errorConfig(node)
 		return NewError(errors.TypeMismatchError(operator, string(left.Type()), string(right.Type()), r))
 
 	default:
-		r, _ := node.TokenInfo.(errors.ErrorConfig)
+		r := errorConfig(node)
 		return NewError(errors.UnknownOperatorError(operator, string(left.Type()), string(right.Type()), r))
 	}
 }
@@ -437,9 +631,9 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 	}
 }
 
-func evalMinusPrefixOperatorExpression(right object.Object, node *ast.PrefixExpression) object.Object {
+func evalMinusPrefixOperatorExpression(right object.Object, node ast.Node) object.Object {
 	if right.Type() != object.INTEGER_OBJ && right.Type() != object.FLOAT_OBJ {
-		r, _ := node.TokenInfo.(errors.ErrorConfig)
+		r := errorConfig(node)
 		return NewError(errors.UnknownOperatorError("-", "", string(right.Type()), r))
 	}
 
@@ -458,7 +652,7 @@ func evalZeroDivision[T int64 | float64](left T) object.Object {
 
 func evalIntegerInfixExpression(
 	operator string,
-	left, right object.Object, node *ast.InfixExpression,
+	left, right object.Object, node ast.Node,
 ) object.Object {
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
@@ -488,14 +682,14 @@ func evalIntegerInfixExpression(
 	case token.GTE:
 		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	default:
-		r, _ := node.TokenInfo.(errors.ErrorConfig)
+		r := errorConfig(node)
 		return NewError(errors.UnknownOperatorError(operator, string(left.Type()), string(right.Type()), r))
 	}
 }
 
 func evalFloatInfixExpression(
 	operator string,
-	left, right object.Object, node *ast.InfixExpression,
+	left, right object.Object, node ast.Node,
 ) object.Object {
 	leftVal := left.(*object.Float).Value
 	rightVal := right.(*object.Float).Value
@@ -525,7 +719,7 @@ func evalFloatInfixExpression(
 	case token.GTE:
 		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	default:
-		r, _ := node.TokenInfo.(errors.ErrorConfig)
+		r := errorConfig(node)
 		return NewError(errors.UnknownOperatorError(operator, string(left.Type()), string(right.Type()), r))
 	}
 }
@@ -548,7 +742,7 @@ func evalNumberDivision[T int64 | float64](leftVal, rightVal T) object.Object {
 
 func evalStringInfixExpression(
 	operator string,
-	left, right object.Object, node *ast.InfixExpression,
+	left, right object.Object, node ast.Node,
 ) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
@@ -579,37 +773,31 @@ func evalStringInfixExpression(
 		return nativeBoolToBooleanObject(leftVal >= rightVal)
 
 	default:
-		r, _ := node.TokenInfo.(errors.ErrorConfig)
+		r := errorConfig(node)
 		return NewError(errors.UnknownOperatorError(operator, string(left.Type()), string(right.Type()), r))
 	}
 }
 
-func evalMapInfixExpression(operator string, left, right object.Object, node *ast.InfixExpression) object.Object {
+func evalMapInfixExpression(operator string, left, right object.Object, node ast.Node) object.Object {
 	switch operator {
 	case token.MINUS:
-		hashKey, ok := right.(object.Hashable)
+		hashKey, ok := object.HashKeyOf(right)
 		if !ok {
-			r, _ := node.TokenInfo.(errors.ErrorConfig)
+			r := errorConfig(node)
 			return NewError(errors.UnusableAsHashKeyError(right.Inspect(), r))
 		}
-		pairs := left.(*object.Hash).Pairs
-		for _, key := range pairs {
-			if key.Key.Inspect() == right.Inspect() {
-				delete(pairs, hashKey.HashKey())
-			}
-		}
+		hash := left.(*object.Hash)
+		hash.Delete(hashKey)
 
-		return &object.Hash{
-			Pairs: pairs,
-		}
+		return hash
 
 	default:
-		r, _ := node.TokenInfo.(errors.ErrorConfig)
+		r := errorConfig(node)
 		return NewError(errors.UnknownOperatorError(operator, string(left.Type()), string(right.Type()), r))
 	}
 }
 
-func evalArrayInfixExpression(operator string, left, right object.Object, node *ast.InfixExpression) object.Object {
+func evalArrayInfixExpression(operator string, left, right object.Object, node ast.Node) object.Object {
 	leftVal := left.(*object.Array).Elements
 	if right.Type() == object.ARRAY_OBJ {
 		rightVal := right.(*object.Array).Elements
@@ -618,18 +806,8 @@ func evalArrayInfixExpression(operator string, left, right object.Object, node *
 		case token.PLUS:
 			return &object.Array{Elements: append(leftVal, rightVal...)}
 
-		case token.EQ:
-			arr1, _ := left.(*object.Array)
-			arr2, _ := right.(*object.Array)
-			return &object.Boolean{Value: utils.ObjectArrayEqual(arr1, arr2)}
-
-		case token.NOT_EQ:
-			arr1, _ := left.(*object.Array)
-			arr2, _ := right.(*object.Array)
-			return &object.Boolean{Value: !utils.ObjectArrayEqual(arr1, arr2)}
-
 		default:
-			r, _ := node.TokenInfo.(errors.ErrorConfig)
+			r := errorConfig(node)
 			return NewError(errors.UnknownOperatorError(operator, string(left.Type()), string(right.Type()), r))
 		}
 	}
@@ -645,7 +823,7 @@ func evalArrayInfixExpression(operator string, left, right object.Object, node *
 
 		case token.MINUS:
 			if int(rightVal) >= len(leftVal) {
-				r, _ := node.TokenInfo.(errors.ErrorConfig)
+				r := errorConfig(node)
 				return NewError(errors.OutOfRangeError(int(rightVal), len(leftVal), r))
 			}
 			newArr := append(leftVal[0:rightVal], leftVal[rightVal+1:]...)
@@ -659,12 +837,12 @@ func evalArrayInfixExpression(operator string, left, right object.Object, node *
 			return &object.Array{Elements: newArr}
 
 		default:
-			r, _ := node.TokenInfo.(errors.ErrorConfig)
+			r := errorConfig(node)
 			return NewError(errors.UnknownOperatorError(operator, string(left.Type()), string(right.Type()), r))
 		}
 	}
 
-	r, _ := node.TokenInfo.(errors.ErrorConfig)
+	r := errorConfig(node)
 	return NewError(errors.UnacceptableRHSInArrayInfixExpressionError(operator, string(right.Type()), r))
 }
 
@@ -694,11 +872,18 @@ func evalIdentifier(
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := lookupBuiltin(node.Value, env); ok {
 		return builtin
 	}
 
-	r, _ := node.TokenInfo.(errors.ErrorConfig)
+	if node.Value == OS_MODULE {
+		return NewOSModule(env.Args())
+	}
+	if constant, ok := LookupConstant(node.Value); ok {
+		return constant
+	}
+
+	r := errorConfig(node)
 	return NewError(errors.IdentifierNotDefinedError(node.Value, r))
 }
 
@@ -716,11 +901,20 @@ func isTruthy(obj object.Object) bool {
 }
 
 func isContinue(obj object.Object) bool {
-	return obj.Type() == object.CONTINUE_OBJ
+	return obj != nil && obj.Type() == object.CONTINUE_OBJ
 }
 
 func isBreak(obj object.Object) bool {
-	return obj.Type() == object.BREAK_OBJ
+	return obj != nil && obj.Type() == object.BREAK_OBJ
+}
+
+func isReturnValue(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.RETURN_VALUE_OBJ
+}
+
+// errorConfig returns an ErrorConfig locating an error at node
+func errorConfig(node ast.Node) errors.ErrorConfig {
+	return errors.NewErrorConfig(node.Span().Start)
 }
 
 func NewError(conf errors.Error) *object.Error {
@@ -751,16 +945,54 @@ func evalExpressions(
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// caller is the context builtins called at node are given to call back into
+// the program
+type caller struct {
+	env  *object.Environment
+	node *ast.CallExpression
+	// whether the builtin being called has recorded what it allocates
+	allocated bool
+}
+
+func (c *caller) Call(fn object.Object, args ...object.Object) object.Object {
+	return traceCall(applyFunction(fn, args, c.env, c.node), fn, c.node)
+}
+
+func (c *caller) Allocate(elements, stringBytes int64) *object.Error {
+	c.allocated = true
+	execution := c.env.Execution()
+	if execution == nil {
+		return nil
+	}
+	return execution.Allocate(elements, stringBytes)
+}
+
+func applyFunction(fn object.Object, args []object.Object, env *object.Environment, node *ast.CallExpression) object.Object {
 	switch fn := fn.(type) {
 
 	case *object.Function:
+		if execution := fn.Env.Execution(); execution != nil {
+			if err := execution.Enter(); err != nil {
+				// the function never started, so the error is located at its call
+				err.SetPosition(node.Span().Start)
+				return err
+			}
+			defer execution.Leave()
+		}
+
 		extendedEnv := extendFunctionEnv(fn, args)
 		evaluated := Eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 
 	case *object.Builtin:
-		return fn.Fn(args...)
+		c := &caller{env: env, node: node}
+		result := fn.Fn(c, args...)
+		if !c.allocated {
+			if err := allocate(env, result); err != nil {
+				return err
+			}
+		}
+		return result
 
 	default:
 		return NewError(errors.TypeError(fn.Inspect(), object.FUNCTION_OBJ))
@@ -784,6 +1016,36 @@ func extendFunctionEnv(
 	return env
 }
 
+// traceCall adds the call made at node to the traceback of an error returned
+// by it. Builtins have no frame of their own, so their errors are located at
+// the call instead.
+func traceCall(result object.Object, fn object.Object, node *ast.CallExpression) object.Object {
+	err, ok := result.(*object.Error)
+	if !ok {
+		return result
+	}
+
+	switch fn := fn.(type) {
+	case *object.Function:
+		name := fn.Name
+		if len(name) == 0 {
+			name = object.ANONYMOUS_FUNCTION
+		}
+		err.Unwind(name, node.Span().Start)
+	default:
+		err.SetPosition(node.Span().Start)
+	}
+	return err
+}
+
+// withPosition locates an error raised without a position at node
+func withPosition(result object.Object, node ast.Node) object.Object {
+	if err, ok := result.(*object.Error); ok {
+		err.SetPosition(node.Span().Start)
+	}
+	return result
+}
+
 func unwrapReturnValue(obj object.Object) object.Object {
 	if returnValue, ok := obj.(*object.ReturnValue); ok {
 		return returnValue.Value
@@ -792,6 +1054,53 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
+// evalChainLink evaluates one link of a chain of index expressions and calls,
+// such as a?.b.c(d), and reports whether an optional link in it found null.
+// The rest of the chain is then skipped, and null is its value.
+func evalChainLink(node ast.Expression, env *object.Environment) (object.Object, bool) {
+	switch node := node.(type) {
+	case *ast.IndexExpression:
+		left, skipped := evalChainLeft(node.Left, env)
+		if skipped || isError(left) {
+			return left, skipped
+		}
+		if node.Optional && isNull(left) {
+			return NULL, true
+		}
+		index := Eval(node.Index, env)
+		if isError(index) {
+			return index, false
+		}
+		return withPosition(evalIndexExpression(left, index, node), node), false
+
+	case *ast.CallExpression:
+		function, skipped := evalChainLeft(node.Function, env)
+		if skipped || isError(function) {
+			return function, skipped
+		}
+		if node.Optional && isNull(function) {
+			return NULL, true
+		}
+
+		args := evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0], false
+		}
+
+		return traceCall(applyFunction(function, args, env, node), function, node), false
+	}
+	return Eval(node, env), false
+}
+
+// evalChainLeft evaluates the left of a link of a chain, which is a chain of
+// its own if it is in parentheses
+func evalChainLeft(node ast.Expression, env *object.Environment) (object.Object, bool) {
+	if ast.Grouped(node) {
+		return Eval(node, env), false
+	}
+	return evalChainLink(node, env)
+}
+
 func evalIndexExpression(left, index object.Object, node *ast.IndexExpression) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
@@ -800,25 +1109,26 @@ func evalIndexExpression(left, index object.Object, node *ast.IndexExpression) o
 		return evalStringindexExpression(left, index, node)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index, node)
+	case left.Type() == object.MODULE_OBJ && index.Type() == object.STRING_OBJ:
+		return evalModuleIndexExpression(left, index, node)
 	default:
-		r, _ := node.TokenInfo.(errors.ErrorConfig)
+		r := errorConfig(node)
 		return NewError(errors.IndexOperatorNotAllowed(string(left.Type()), r))
 	}
 }
 
 func evalStringindexExpression(str, index object.Object, node *ast.IndexExpression) object.Object {
 	/*
-		- cast to *object.String
-		- get literal of index
-		- test index literal is within bounds such that 0 <= index <= len(str)
-		- return value at index
+		- index the characters (runes) of the string, not its bytes
+		- test index is within bounds such that -len(str) <= index < len(str)
+		- return the character at index, counting from the end if it is negative
 	*/
-	strObject := str.(*object.String)
+	runes := []rune(str.(*object.String).Value)
 	idx := index.(*object.Integer).Value
-	max := int64(len(strObject.Value))
+	max := int64(len(runes) - 1)
 
-	if len(strObject.Value) == 0 || idx > max {
-		r, _ := node.TokenInfo.(errors.ErrorConfig)
+	if idx > max || idx < -max-1 {
+		r := errorConfig(node)
 		return NewError(errors.OutOfRangeError(int(idx), int(max), r))
 	}
 
@@ -827,7 +1137,7 @@ func evalStringindexExpression(str, index object.Object, node *ast.IndexExpressi
 	}
 
 	return &object.String{
-		Value: string(strObject.Value[idx]),
+		Value: string(runes[idx]),
 	}
 }
 
@@ -836,15 +1146,12 @@ func evalArrayIndexExpression(array, index object.Object, node *ast.IndexExpress
 	idx := index.(*object.Integer).Value
 	max := int64(len(arrayObject.Elements) - 1)
 
-	if len(arrayObject.Elements) == 0 {
-		r, _ := node.TokenInfo.(errors.ErrorConfig)
+	if idx > max || idx < -max-1 {
+		r := errorConfig(node)
 		return NewError(errors.OutOfRangeError(int(idx), int(max), r))
 	}
 
-	if idx > max {
-		r, _ := node.TokenInfo.(errors.ErrorConfig)
-		return NewError(errors.OutOfRangeError(int(idx), int(max), r))
-	} else if idx < 0 {
+	if idx < 0 {
 		idx = max + 1 + idx
 	}
 
@@ -855,42 +1162,41 @@ func evalHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
 ) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.HashKeyOf(key)
 		if !ok {
-			r, _ := node.TokenInfo.(errors.ErrorConfig)
+			r := errorConfig(node)
 			return NewError(errors.UnusableAsHashKeyError(key.Inspect(), r))
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(pair.Value, env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, object.HashPair{Key: key, Value: value})
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalHashIndexExpression(hash, index object.Object, node *ast.IndexExpression) object.Object {
 	hashObject := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
+	key, ok := object.HashKeyOf(index)
 	if !ok {
-		r, _ := node.TokenInfo.(errors.ErrorConfig)
+		r := errorConfig(node)
 		return NewError(errors.UnusableAsHashKeyError(index.Inspect(), r))
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Pairs[key]
 	if !ok {
 		return NULL
 	}
@@ -905,12 +1211,15 @@ func evalPostfixExpression(env *object.Environment, node *ast.PostfixExpression)
 		- if token is integer, set incremented value in env
 	*/
 	tokenLiteral := node.Token.Literal
+	if env.IsConstant(tokenLiteral) {
+		return NewError(errors.ConstantAssignmentError(tokenLiteral, errorConfig(node)))
+	}
 	tok, ok := env.Get(tokenLiteral)
 	if !ok {
 		if intValue, ok := strconv.ParseInt(tokenLiteral, 10, 64); ok == nil {
 			tok = &object.Integer{Value: intValue}
 		} else {
-			r, _ := node.TokenInfo.(errors.ErrorConfig)
+			r := errorConfig(node)
 			return NewError(errors.IllegalTokenError(tokenLiteral, r))
 		}
 	}
@@ -919,7 +1228,7 @@ func evalPostfixExpression(env *object.Environment, node *ast.PostfixExpression)
 	case token.POST_INCR:
 		integer, ok := tok.(*object.Integer)
 		if !ok {
-			r, _ := node.TokenInfo.(errors.ErrorConfig)
+			r := errorConfig(node)
 			return NewError(errors.UnacceptableLHSInPostfixExpression(node.Operator, tokenLiteral, r))
 		}
 
@@ -930,7 +1239,7 @@ func evalPostfixExpression(env *object.Environment, node *ast.PostfixExpression)
 	case token.POST_DECR:
 		integer, ok := tok.(*object.Integer)
 		if !ok {
-			r, _ := node.TokenInfo.(errors.ErrorConfig)
+			r := errorConfig(node)
 			return NewError(errors.UnacceptableLHSInPostfixExpression(node.Operator, tokenLiteral, r))
 		}
 
@@ -938,17 +1247,41 @@ func evalPostfixExpression(env *object.Environment, node *ast.PostfixExpression)
 		env.Set(tokenLiteral, newInteger)
 		return newInteger
 	}
-	r, _ := node.TokenInfo.(errors.ErrorConfig)
+	r := errorConfig(node)
 	return NewError(errors.UnknownOperatorError(node.Operator, "", "", r))
 }
 
+func evalSquareBracketAssignment(left, index, value object.Object, node *ast.SquareBracketAssignment) object.Object {
+	r := errorConfig(node)
+
+	switch left.Type() {
+	case object.ARRAY_OBJ:
+		if _, ok := index.(*object.Integer); !ok {
+			return NewError(errors.UnacceptableIndexError(index.Inspect(), string(index.Type()), object.ARRAY_OBJ, r))
+		}
+		return evalArraySquareBracketExpression(&left, index, value, node)
+
+	case object.HASH_OBJ:
+		return evalMapSquareBracketExpression(&left, index, value, node)
+
+	default:
+		return NewError(errors.UnacceptableTypeInKeyAssignmentError(string(left.Type()), r))
+	}
+}
+
 func evalArraySquareBracketExpression(left *object.Object, index, value object.Object, node *ast.SquareBracketAssignment) object.Object {
 	arr := (*left).(*object.Array)
 	idx := index.(*object.Integer).Value
+	max := int64(len(arr.Elements) - 1)
 
-	if int(idx) > len(arr.Elements) {
-		r, _ := node.TokenInfo.(errors.ErrorConfig)
-		return NewError(errors.OutOfRangeError(int(idx), len(arr.Elements), r))
+	// as when indexing, negative indexes count from the end
+	if idx > max || idx < -max-1 {
+		r := errorConfig(node)
+		return NewError(errors.OutOfRangeError(int(idx), int(max), r))
+	}
+
+	if idx < 0 {
+		idx = max + 1 + idx
 	}
 
 	arr.Elements[idx] = value
@@ -959,14 +1292,67 @@ func evalArraySquareBracketExpression(left *object.Object, index, value object.O
 func evalMapSquareBracketExpression(left *object.Object, index, value object.Object, node *ast.SquareBracketAssignment) object.Object {
 	hash := (*left).(*object.Hash)
 
-	hashKey, ok := index.(object.Hashable)
+	hashKey, ok := object.HashKeyOf(index)
 	if !ok {
-		r, _ := node.TokenInfo.(errors.ErrorConfig)
+		r := errorConfig(node)
 		return NewError(errors.UnusableAsHashKeyError(index.Inspect(), r))
 	}
-	hash.Pairs[hashKey.HashKey()] = object.HashPair{
+	hash.Set(hashKey, object.HashPair{
 		Key:   index,
 		Value: value,
-	}
+	})
 	return hash
 }
+
+// The functions below expose the evaluator's operator semantics to other
+// execution engines (see package vm), so that both agree on every result.
+
+// unlocated stands in for the node of the errors they return, which the
+// engines locate themselves
+var unlocated ast.Expression = &ast.NullValueExpression{}
+
+func EvalPrefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right, unlocated)
+}
+
+func EvalInfix(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right, unlocated)
+}
+
+func EvalConcat(values []object.Object) object.Object {
+	return concat(values)
+}
+
+func EvalIndex(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index, &ast.IndexExpression{Index: unlocated})
+}
+
+func EvalIndexAssignment(left, index, value object.Object) object.Object {
+	return evalSquareBracketAssignment(left, index, value, &ast.SquareBracketAssignment{})
+}
+
+func EvalThrow(val object.Object) *object.Error {
+	return evalThrowStatement(val, unlocated)
+}
+
+func CaughtError(err *object.Error) object.Object {
+	return caughtError(err)
+}
+
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// lookupBuiltin looks name up in the builtins of the program env belongs to
+func lookupBuiltin(name string, env *object.Environment) (*object.Builtin, bool) {
+	if table := env.Builtins(); table != nil {
+		builtin, ok := table[name]
+		return builtin, ok
+	}
+	return LookupBuiltin(name)
+}
@@ -1,9 +1,14 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
 	"github.com/icheka/sonar-lang/sonar-lang/lexer"
 	"github.com/icheka/sonar-lang/sonar-lang/object"
 	"github.com/icheka/sonar-lang/sonar-lang/parser"
@@ -51,6 +56,21 @@ for (i,_ in range(1, 6)) {
 j
 `
 	testEvalInteger(t, input, 2)
+
+	// a break ends the loop, not the function it is in
+	input = `
+let f = func() {
+	while (true) {
+		break
+	}
+	for (i, v in [1, 2]) {
+		break
+	}
+	3
+}
+f()
+`
+	testEvalInteger(t, input, 3)
 }
 
 func TestDeleteMapKeyExpression(t *testing.T) {
@@ -72,6 +92,27 @@ func TestSquareBracketAssignmentExpression(t *testing.T) {
 		t.Fatalf("expected evaluated to be ERROR, got=%T", evaluated)
 	}
 
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = []; a[0] = 1", "Index '0' out of range [-1]"},
+		{"let a = [1, 2]; a[2] = 3", "Index '2' out of range [1]"},
+		{"let a = [1, 2]; a[-3] = 3", "Index '-3' out of range [1]"},
+		{"[1][-2]", "Index '-2' out of range [0]"},
+	}
+	for _, tt := range tests {
+		err, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if msg := err.Conf.(errors.Error).Message; msg != tt.expected {
+			t.Errorf("%s: expected %q, got=%q", tt.input, tt.expected, msg)
+		}
+	}
+	testEvalType[*object.Array](t, "let a = [1, 2]; a[-1] = 3; a", "[1, 3]")
+
 	input = `
 let m = {1: 1}
 m[1] = 10
@@ -280,10 +321,25 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"1 < 1.2", true},
 		{"1.3 < 1.2", false},
 		{"1.3 > 1.2", true},
-		{"1 and 2", true},
+		{"1 == 1.0", true},
+		{"1.5 != 1", true},
+		{`"a" == "a"`, true},
+		{`"1" == 1`, false},
+		{"[1, [2, 3]] == [1, [2, 3]]", true},
+		{"[1, [2, 3]] == [1, [2, 4]]", false},
+		{"[1, 2] == [1]", false},
+		{"[1] == 1", false},
+		{`{"a": 1, "b": [2]} == {"b": [2.0], "a": 1}`, true},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{`{"a": 1} != {"a": 2}`, true},
+		{`{} == []`, false},
+		{"len == len", true},
+		{"let f = func() {}; f == func() {}", false},
+		{"let a = [1]; a[0] = a; let b = [1]; b[0] = b; a == b", true},
+		{"true and false", false},
 		{"false and 2", false},
-		{"false or 2", true},
-		{"false or 0", true},
+		{"1 and true", true},
+		{"false or true", true},
 		{"false or false", false},
 	}
 
@@ -293,6 +349,99 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestLogicalExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// the operand that decides the result is returned as it is
+		{"1 and 2", 2},
+		{"false and 2", false},
+		{"1 or 2", 1},
+		{"false or 0", 0},
+		{"let x = []; len(x) > 0 and x[0] == 1", false},
+		{"let a = [0]; let f = func() { a[0] = 1; true }; false and f(); a[0]", 0},
+		{"let a = [0]; let f = func() { a[0] = 1; true }; true or f(); a[0]", 0},
+		{"let a = [0]; let f = func() { a[0] = 1; true }; false or f(); a[0]", 1},
+		// right is not evaluated, so it cannot fail
+		{"false and 1 + true", false},
+		{"if (false or 0) { 10 } else { 20 }", 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestNullAndOptionalChaining(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"null", "null"},
+		{"let x = null; x", "null"},
+		{"null == null", "true"},
+		{`{"a": 1}["b"] == null`, "true"},
+		{"0 == null", "false"},
+		{"!null", "true"},
+		{"type(null)", "NULL"},
+		{`null ?? "default"`, "default"},
+		{`{}["a"] ?? 1`, "1"},
+		{"0 ?? 1", "0"},
+		{"false ?? 1", "false"},
+		{`"" ?? 1`, ""},
+		{"null ?? null ?? 3", "3"},
+		{"let a = [0]; let f = func() { a[0] = 1 }; 5 ?? f(); a[0]", "0"},
+		{`let m = {"a": {"b": 1}}; m?.["a"]?.["b"]`, "1"},
+		{`let m = {"a": {"b": 1}}; m["x"]?.["b"]`, "null"},
+		{`let m = {"a": {"b": 1}}; m.a?.b`, "1"},
+		{`let m = {}; m.a?.b?.c`, "null"},
+		{"let f = null; f?.(1)", "null"},
+		{"let f = func(x) { x * 2 }; f?.(2)", "4"},
+		{"let a = [0]; let f = func() { a[0] = 1 }; let g = null; g?.(f()); a[0]", "0"},
+		{"[1, 2]?.[1]", "2"},
+		{"let n = null; n?.a.b", "null"},
+		{"let n = null; n?.a[0].b()", "null"},
+		{"let n = null; n?.(1).x", "null"},
+		{"let n = null; let m = {}; m?.a ?? n?.b.c", "null"},
+		{"let a = [0]; let f = func() { a[0] = 1 }; let n = null; n?.b[f()]; a[0]", "0"},
+		{"let n = null; (n?.a)", "null"},
+		{"let n = null; (n?.a) ?? 1", "1"},
+		{`let m = {"a": {"b": 1}}; (m?.a).b`, "1"},
+		{"let n = null; ((n?.a)?.b) ?? 2", "2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if isError(evaluated) {
+			t.Errorf("%s: unexpected error %+v", tt.input, evaluated.(*object.Error).Conf)
+			continue
+		}
+		if got := concat([]object.Object{evaluated}).Value; got != tt.expected {
+			t.Errorf("%s: expected %q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	// ?. skips the rest of the chain only when the value before it is null, so
+	// indexing a null it returns fails
+	if !isError(testEval(`let m = {}; m?.["a"]["b"]`)) {
+		t.Errorf("expected indexing null to be an error")
+	}
+
+	// and parentheses end the chain it skips
+	for _, input := range []string{"let n = null; (n?.b).c", "let n = null; (n?.b)()", "let n = null; (n?.(1))[0]"} {
+		if !isError(testEval(input)) {
+			t.Errorf("%s: expected indexing or calling null to be an error", input)
+		}
+	}
+}
+
 func TestBangOperator(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -409,6 +558,62 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestConstStatements(t *testing.T) {
+	testEvalInteger(t, "const a = 5; a", 5)
+	testEvalInteger(t, "const f = func() { const a = 1; a }; const a = 2; f() + a", 3)
+	// a constant declared in a loop body is declared again on each iteration
+	testEvalInteger(t, "let s = 0; for (i, v in [1, 2, 3]) { const x = v; s += x }; s", 6)
+
+	tests := []struct {
+		input  string
+		line   int
+		column int
+	}{
+		{"const a = 1;\na = 2", 2, 1},
+		{"const a = 1;\nlet b = 0; a += 2", 2, 12},
+		{"const a = 1;\n  a++", 2, 3},
+		{"const a = 1;\na--", 2, 1},
+		{"const a = 1;\nlet f = func() { a = 2 }\nf()", 2, 18},
+	}
+
+	for _, tt := range tests {
+		err, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected an error", tt.input)
+			continue
+		}
+		if message := err.Conf.(errors.Error).Message; message != "Illegal assignment to constant 'a'" {
+			t.Errorf("%q: wrong message. got=%q", tt.input, message)
+		}
+		if pos := err.Position(); pos.Line != tt.line || pos.Column != tt.column {
+			t.Errorf("%q: expected error at %d:%d, got=%d:%d", tt.input, tt.line, tt.column, pos.Line, pos.Column)
+		}
+	}
+
+	if !isError(testEval("const a = 1; let a = 2;")) {
+		t.Errorf("expected redeclaring a constant to be an error")
+	}
+}
+
+func TestOSArgs(t *testing.T) {
+	testEvalInteger(t, "len(os.args)", 0)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`join(os.args, ",")`, "a,b"},
+		{`let a = os.args; a[0] = "x"; join(os.args, ",")`, "a,b"},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.SetArgs([]string{"a", "b"})
+		program := parser.New(lexer.New(tt.input, nil)).ParseProgram()
+		testStringObject(t, Eval(program, env), tt.expected)
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "func(x) { x + 2; };"
 
@@ -509,6 +714,36 @@ func TestStringConcatenation(t *testing.T) {
 	}
 }
 
+func TestTemplateStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Ada"; "Hello ${name}!"`, "Hello Ada!"},
+		{`let items = [1, 2]; "${len(items)} items: ${items}"`, "2 items: [1, 2]"},
+		{`"${1.5} ${true} ${{"a": 1}["a"]}"`, "1.5 true 1"},
+		{`let x = 2; "${"x is ${x}"}, twice ${x * 2}"`, "x is 2, twice 4"},
+		{`"a\tb\n\"c\" \\ \${x} \u{263A}"`, "a\tb\n\"c\" \\ ${x} ☺"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. expected=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+
+	if evaluated := testEval(`"${1 + "a"}"`); evaluated.Type() != object.ERROR_OBJ {
+		t.Errorf("expected an error, got=%s", evaluated.Inspect())
+	}
+}
+
 func TestBuiltinFunctions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -711,6 +946,34 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`{1.1: 1, 1.9: 5}[1.9]`,
+			5,
+		},
+		{
+			`{1.1: 5}[1]`,
+			nil,
+		},
+		{
+			`{2: 5}[2.0]`,
+			5,
+		},
+		{
+			`{[1, "a"]: 5}[[1, "a"]]`,
+			5,
+		},
+		{
+			`{[1, [2]]: 5}[[1, [2.0]]]`,
+			5,
+		},
+		{
+			`{[1]: 5}[[1, 1]]`,
+			nil,
+		},
+		{
+			`let k = [1]; let m = {}; m[k] = 5; k[0] = 2; m[[1]]`,
+			5,
+		},
 	}
 
 	for _, tt := range tests {
@@ -743,6 +1006,42 @@ func TestStringIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestUnicodeStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("héllo")`, 5},
+		{`len("😀")`, 1},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[-4]`, "é"},
+		{`"日本語"[2]`, "語"},
+		{`slice("héllo", 1, 3)`, "él"},
+		{`index("héllo", "l")`, 2},
+		{`let out = ""; for (_, c in "aé😀") { out = c + out }; out`, "😀éa"},
+		{`let café = "☕"; café`, "☕"},
+		{`let 名前 = 1; 名前 + 1`, 2},
+		{`"héllo"[5]`, "Index '5' out of range [4]"},
+		{`"héllo"[-6]`, "Index '-6' out of range [4]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Conf.(errors.Error).Message != expected {
+					t.Errorf("%s: expected %q, got=%q", tt.input, expected, errObj.Conf.(errors.Error).Message)
+				}
+				continue
+			}
+			testStringObject(t, evaluated, expected)
+		}
+	}
+}
+
 func testStringObject(t *testing.T, obj object.Object, expected interface{}) bool {
 	result, ok := obj.(*object.String)
 	if !ok {
@@ -923,3 +1222,323 @@ func TestEvalArrayInfixExpression(t *testing.T) {
 		testEvalType[*object.Boolean](t, tt.input, tt.expectedValue)
 	}
 }
+
+func TestErrorTraceback(t *testing.T) {
+	input := `let inner = func(x) {
+	return x + "a"
+}
+let outer = func() {
+	return inner(1)
+}
+outer()`
+
+	evaluated := testEval(input)
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("expected evaluated to be error, got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := []object.Frame{
+		{Function: "inner", Line: 2},
+		{Function: "outer", Line: 5},
+		{Function: object.MODULE_FRAME, Line: 7},
+	}
+	if len(err.Traceback) != len(expected) {
+		t.Fatalf("expected %d frames, got=%+v", len(expected), err.Traceback)
+	}
+	for i, frame := range expected {
+		if err.Traceback[i] != frame {
+			t.Errorf("frame %d: expected %+v, got=%+v", i, frame, err.Traceback[i])
+		}
+	}
+
+	// builtins have no frame, so their errors are located at the call
+	err, ok = testEval("let f = func() {\n\tlen()\n}\nf()").(*object.Error)
+	if !ok {
+		t.Fatalf("expected evaluated to be error")
+	}
+	if pos := err.Position(); pos.Line != 2 || pos.Column != 2 {
+		t.Errorf("expected builtin error at 2:2, got=%d:%d", pos.Line, pos.Column)
+	}
+	if err.Traceback[0] != (object.Frame{Function: "f", Line: 2}) {
+		t.Errorf("expected innermost frame to be f at line 2, got=%+v", err.Traceback[0])
+	}
+}
+
+func TestTryStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let x = 0; try { x = int("abc") } catch { x = -1 }; x`, -1},
+		{`let x = 0; try { x = int("12") } catch { x = -1 }; x`, 12},
+		{`try { throw "boom" } catch (e) { e["message"] }`, "boom"},
+		{`try { throw "boom" } catch (e) { e["type"] }`, "Error"},
+		{`try { throw {"type": "ValueError", "message": "bad"} } catch (e) { e["type"] + ": " + e["message"] }`, "ValueError: bad"},
+		{`try { throw 42 } catch (e) { e["value"] }`, 42},
+		{`try { int("abc") } catch (e) { e["type"] }`, "RuntimeError"},
+		{"let f = func() {\n\tthrow \"inner\"\n}\ntry { f() } catch (e) { e[\"line\"] }", 2},
+		{`let log = ""; try { log += "try;" } finally { log += "finally;" }; log`, "try;finally;"},
+		{`let log = ""; try { throw "x" } catch { log += "catch;" } finally { log += "finally;" }; log`, "catch;finally;"},
+		{`let f = func() { try { return 1 } finally { return 2 } }; f()`, 2},
+		{`let f = func() { try { return 1 } catch { return 2 } }; f()`, 1},
+		{`let n = 0; while (true) { try { break } finally { n += 1 } }; n`, 1},
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`try { throw "boom" } catch (e) { join(mapKeys(e), ",") }`, "type,message,file,line,column"},
+		{`try { throw 42 } catch (e) { let n = 0; for (k, v in e) { n += 1 }; n }`, 6},
+		{`try { throw "boom" } catch (e) { str(e) }`, "{'type': 'Error', 'message': 'boom', 'file': '', 'line': 1, 'column': 7}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		}
+	}
+
+	// the catch parameter does not leak into the enclosing scope
+	evaluated := testEval(`try { throw "x" } catch (e) { 1 }; e`)
+	if !isError(evaluated) {
+		t.Errorf("expected e to be undefined after catch, got=%s", evaluated.Inspect())
+	}
+
+	// errors without a catch block propagate after finally runs
+	err, ok := testEval(`let x = 0; try { throw "uncaught" } finally { x = 1 }`).(*object.Error)
+	if !ok {
+		t.Fatalf("expected uncaught error")
+	}
+	if conf := err.Conf.(errors.Error); conf.Message != "uncaught" || conf.Line != 1 {
+		t.Errorf("expected 'uncaught' at line 1, got=%+v", conf)
+	}
+}
+
+func TestImportStatement(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"lib/math.sonar": `export let add = func(a, b) { a + b }
+export let PI = 3
+export const TWO = 2
+let secret = 42`,
+		"lib/uses_math.sonar": `import "./math"
+export let m = math
+export let twice = func(x) { math["add"](x, x) }`,
+		"cycle_a.sonar":    `import "./cycle_b"`,
+		"cycle_b.sonar":    `import "./cycle_a"`,
+		"broken.sonar":     "export let x = 1\nlet y = x + \"a\"",
+		"exporter.sonar":   `export let f = func() { 1 }`,
+		"unparsable.sonar": "let = 1\nlet y = 2\nlet 3",
+	}
+	for name, source := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run := func(input string) (object.Object, *object.Environment) {
+		l := lexer.New(input, &lexer.LexerOptions{Path: filepath.Join(dir, "main.sonar")})
+		p := parser.New(l)
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors: %+v", p.Errors())
+		}
+		env := object.NewEnvironment()
+		return Eval(program, env), env
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`import "./lib/math"; math["add"](1, 2)`, 3},
+		{`import "./lib/math" as m; m["PI"]`, 3},
+		{`import { add, PI } from "./lib/math"; add(PI, 1)`, 4},
+		{`import { twice } from "./lib/uses_math.sonar"; twice(5)`, 10},
+		{`import { secret } from "./lib/math"`, "ImportError: Module './lib/math' does not export 'secret'"},
+		{`import "./lib/math"; math["secret"]`, "ImportError: Module 'math' does not export 'secret'"},
+		{`import "./missing"`, "ImportError: Cannot find module './missing' (looked for " + filepath.Join(dir, "missing.sonar") + ")"},
+		{`import "./cycle_a"`, "ImportError: Import cycle: cycle_a.sonar -> cycle_b.sonar -> cycle_a.sonar"},
+		{`import "./main"`, "ImportError: Import cycle: main.sonar -> main.sonar"},
+		{`let exporter = 1; import "./exporter"`, "SyntaxError: Identifier 'exporter' has already been defined"},
+		{`import { TWO } from "./lib/math"; TWO = 3`, "AssignmentError: Illegal assignment to constant 'TWO'"},
+		{`import { PI } from "./lib/math"; PI += 1`, "AssignmentError: Illegal assignment to constant 'PI'"},
+		{`import "./lib/math" as m; m = 1`, "AssignmentError: Illegal assignment to constant 'm'"},
+		{`import { TWO } from "./lib/math"; let f = func() { let TWO = 3; TWO }; f() + TWO`, 5},
+	}
+
+	for _, tt := range tests {
+		evaluated, _ := run(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			err, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("expected error for %q, got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if conf := err.Conf.(errors.Error); conf.String() != expected {
+				t.Errorf("expected %q, got=%q", expected, conf.String())
+			}
+		}
+	}
+
+	// modules are evaluated once, however many times they are imported
+	_, env := run(`import "./lib/math"; import "./lib/uses_math"`)
+	math, _ := env.Get("math")
+	usesMath, _ := env.Get("uses_math")
+	if usesMath.(*object.Module).Exports["m"] != math {
+		t.Errorf("expected math to be loaded once")
+	}
+
+	// errors raised in a module are traced back to the import
+	evaluated, _ := run("let x = 1\nimport \"./broken\"")
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("expected error, got=%T", evaluated)
+	}
+	expected := []object.Frame{
+		{Function: object.MODULE_FRAME, File: filepath.Join(dir, "broken.sonar"), Line: 2},
+		{Function: object.MODULE_FRAME, File: filepath.Join(dir, "main.sonar"), Line: 2},
+	}
+	if len(err.Traceback) != len(expected) {
+		t.Fatalf("expected %d frames, got=%+v", len(expected), err.Traceback)
+	}
+	for i, frame := range expected {
+		if err.Traceback[i] != frame {
+			t.Errorf("frame %d: expected %+v, got=%+v", i, frame, err.Traceback[i])
+		}
+	}
+
+	// every syntax error in a module is reported, not just the first
+	evaluated, _ = run(`import "./unparsable"`)
+	err, ok = evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("expected error, got=%T", evaluated)
+	}
+	lines := []int{err.Conf.(errors.Error).Line}
+	for _, related := range err.Related {
+		lines = append(lines, related.Line)
+	}
+	if len(lines) != 2 || lines[0] != 1 || lines[1] != 3 {
+		t.Errorf("expected syntax errors on lines 1 and 3, got=%v", lines)
+	}
+}
+
+func TestDefaultCallDepth(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = func(n) { f(n + 1) }; f(0)", "Maximum call stack size exceeded"},
+		{"let f = func(n) { if (n == 0) { return 0 }; 1 + f(n - 1) }; f(5000)", 5000},
+		{`let f = func(n) { f(n + 1) }; try { f(0) } catch (e) { e["type"] }`, "RuntimeError"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if err, ok := evaluated.(*object.Error); ok {
+				if msg := err.Conf.(errors.Error).Message; msg != expected {
+					t.Errorf("%s: expected %q, got=%q", tt.input, expected, msg)
+				}
+				continue
+			}
+			testStringObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestExecutionLimits(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		input    string
+		ctx      context.Context
+		limits   object.Limits
+		expected string
+	}{
+		{`while (true) {}`, context.Background(), object.Limits{MaxSteps: 100}, "Execution exceeded the limit of 100 steps"},
+		{`for (i, v in [1, 2, 3]) { v }`, context.Background(), object.Limits{MaxSteps: 2}, "Execution exceeded the limit of 2 steps"},
+		{`let f = func(n) { f(n + 1) }; f(0)`, context.Background(), object.Limits{MaxCallDepth: 50}, "Maximum call depth of 50 exceeded"},
+		{`[0] * 1000000000`, context.Background(), object.Limits{MaxElements: 1000}, "Execution exceeded the limit of 1000 allocated elements"},
+		{`let a = []; while (true) { a = push(a, 1) }`, context.Background(), object.Limits{MaxElements: 1000}, "Execution exceeded the limit of 1000 allocated elements"},
+		{`let s = "ab"; while (true) { s += s }`, context.Background(), object.Limits{MaxStringBytes: 1 << 20}, "Execution exceeded the limit of 1048576 allocated string bytes"},
+		{`range(0, 100000000)`, context.Background(), object.Limits{MaxElements: 100000}, "Execution exceeded the limit of 100000 allocated elements"},
+		{`range(0, 9223372036854775807, 2)`, context.Background(), object.Limits{MaxElements: 100000}, "Execution exceeded the limit of 100000 allocated elements"},
+		{`runes(repeat("é", 1000))`, context.Background(), object.Limits{MaxElements: 100}, "Execution exceeded the limit of 100 allocated elements"},
+		{`split(repeat("a,", 1000), ",")`, context.Background(), object.Limits{MaxElements: 100}, "Execution exceeded the limit of 100 allocated elements"},
+		{`map(range(0, 60), func(x) { x })`, context.Background(), object.Limits{MaxElements: 100}, "Execution exceeded the limit of 100 allocated elements"},
+		{`repeat("ab", 100000000)`, context.Background(), object.Limits{MaxStringBytes: 1 << 20}, "Execution exceeded the limit of 1048576 allocated string bytes"},
+		{`padLeft("", 100000000, "x")`, context.Background(), object.Limits{MaxStringBytes: 1 << 20}, "Execution exceeded the limit of 1048576 allocated string bytes"},
+		{`replace(repeat("a", 2000), "a", repeat("b", 2000))`, context.Background(), object.Limits{MaxStringBytes: 1 << 20}, "Execution exceeded the limit of 1048576 allocated string bytes"},
+		{`let m = {}; let i = 0; while (i < 5000) { m[i] = i; i++ }`, context.Background(), object.Limits{MaxElements: 1000}, "Execution exceeded the limit of 1000 allocated elements"},
+		{`while (true) { try { while (true) {} } catch {} }`, context.Background(), object.Limits{MaxSteps: 1000}, "Execution exceeded the limit of 1000 steps"},
+		{`1`, cancelled, object.Limits{}, "Execution stopped: context canceled"},
+		{`while (true) {}`, context.Background(), object.Limits{Timeout: time.Millisecond}, "Execution stopped: context deadline exceeded"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input, nil)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		InitStdlib()
+
+		evaluated := EvalContext(tt.ctx, program, object.NewEnvironment(), tt.limits)
+		err, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("expected error for %q, got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		conf := err.Conf.(errors.Error)
+		if conf.Type != errors.LIMIT_ERROR || conf.Message != tt.expected {
+			t.Errorf("expected LimitError %q for %q, got=%s", tt.expected, tt.input, conf.String())
+		}
+	}
+
+	// programs within their limits are unaffected by them
+	limits := object.Limits{MaxSteps: 100, MaxCallDepth: 10, MaxElements: 100, MaxStringBytes: 100}
+	l := lexer.New(`let f = func(n) { if (n == 0) { return 0 }; n + f(n - 1) }; f(5)`, nil)
+	evaluated := EvalContext(context.Background(), parser.New(l).ParseProgram(), object.NewEnvironment(), limits)
+	testIntegerObject(t, evaluated, 15)
+
+	// what a builtin records before allocating is not counted again
+	l = lexer.New(`len(range(0, 60))`, nil)
+	evaluated = EvalContext(context.Background(), parser.New(l).ParseProgram(), object.NewEnvironment(), limits)
+	testIntegerObject(t, evaluated, 60)
+
+	// replacing the value of an existing key allocates nothing
+	l = lexer.New(`let m = {"a": 0}; let i = 0; while (i < 5000) { m["a"] = i; i++ }; m["a"]`, nil)
+	evaluated = EvalContext(context.Background(), parser.New(l).ParseProgram(), object.NewEnvironment(), object.Limits{MaxElements: 10})
+	testIntegerObject(t, evaluated, 4999)
+}
+
+func benchmarkEval(b *testing.B, input string) {
+	program := parser.New(lexer.New(input, nil)).ParseProgram()
+	InitStdlib()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if result := Eval(program, object.NewEnvironment()); isError(result) {
+			b.Fatal(result.Inspect())
+		}
+	}
+}
+
+func BenchmarkLoop(b *testing.B) {
+	benchmarkEval(b, "let i = 0\nlet s = 0\nwhile (i < 100000) {\n\ts = s + i * 2\n\ti += 1\n}\ns")
+}
+
+func BenchmarkFibonacci(b *testing.B) {
+	benchmarkEval(b, "let fib = func(n) { if (n < 2) { return n }; fib(n - 1) + fib(n - 2) }; fib(20)")
+}
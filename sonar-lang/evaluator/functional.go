@@ -0,0 +1,239 @@
+package evaluator
+
+import (
+	"sort"
+
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+)
+
+// FunctionalBuiltins call the function they are given for each element of an
+// array, with the element and its index. `map` and `sort` with a comparator
+// are implemented here too, but registered with the other forms of their
+// builtins.
+var FunctionalBuiltins = map[string]*object.Builtin{
+	"each": {
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			arr, fn, err := callbackArguments("each", args)
+			if err != nil {
+				return err
+			}
+
+			for i, v := range arr.Elements {
+				if result := callWithIndex(caller, fn, i, v); isError(result) {
+					return result
+				}
+			}
+			return NULL
+		},
+	},
+	"filter": {
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			arr, fn, err := callbackArguments("filter", args)
+			if err != nil {
+				return err
+			}
+
+			elements := []object.Object{}
+			for i, v := range arr.Elements {
+				result := callWithIndex(caller, fn, i, v)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					elements = append(elements, v)
+				}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"reduce": {
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			/*
+				reduce(arr, fn)
+				-- folds arr into fn(fn(arr[0], arr[1], 1), arr[2], 2)...
+				reduce(arr, fn, initial)
+				-- folds arr into fn(fn(initial, arr[0], 0), arr[1], 1)...
+			*/
+			if len(args) != 2 && len(args) != 3 {
+				return NewError(errors.RequiresXArgumentsError(2, len(args), "reduce"))
+			}
+			arr, fn, err := callbackArguments("reduce", args[:2])
+			if err != nil {
+				return err
+			}
+
+			start := 0
+			var acc object.Object
+			if len(args) == 3 {
+				acc = args[2]
+			} else if len(arr.Elements) == 0 {
+				return NewError(errors.ReduceOfEmptyArrayError())
+			} else {
+				acc = arr.Elements[0]
+				start = 1
+			}
+
+			for i := start; i < len(arr.Elements); i++ {
+				acc = callWithIndex(caller, fn, i, acc, arr.Elements[i])
+				if isError(acc) {
+					return acc
+				}
+			}
+			return acc
+		},
+	},
+	"find": {
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			arr, fn, err := callbackArguments("find", args)
+			if err != nil {
+				return err
+			}
+
+			for i, v := range arr.Elements {
+				result := callWithIndex(caller, fn, i, v)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					return v
+				}
+			}
+			return NULL
+		},
+	},
+	"any": {
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			arr, fn, err := callbackArguments("any", args)
+			if err != nil {
+				return err
+			}
+
+			for i, v := range arr.Elements {
+				result := callWithIndex(caller, fn, i, v)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					return TRUE
+				}
+			}
+			return FALSE
+		},
+	},
+	"all": {
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			arr, fn, err := callbackArguments("all", args)
+			if err != nil {
+				return err
+			}
+
+			for i, v := range arr.Elements {
+				result := callWithIndex(caller, fn, i, v)
+				if isError(result) {
+					return result
+				}
+				if !isTruthy(result) {
+					return FALSE
+				}
+			}
+			return TRUE
+		},
+	},
+}
+
+// mapArray implements map(arr, fn), which returns the results of fn for each
+// element of arr
+func mapArray(caller object.Caller, args ...object.Object) object.Object {
+	arr, fn, err := callbackArguments("map", args)
+	if err != nil {
+		return err
+	}
+
+	if err := caller.Allocate(int64(len(arr.Elements)), 0); err != nil {
+		return err
+	}
+
+	elements := make([]object.Object, len(arr.Elements))
+	for i, v := range arr.Elements {
+		result := callWithIndex(caller, fn, i, v)
+		if isError(result) {
+			return result
+		}
+		elements[i] = result
+	}
+	return &object.Array{Elements: elements}
+}
+
+// sortWithComparator implements sort(arr, cmp). cmp(a, b) returns a negative
+// number if a sorts before b, or true if a sorts before b.
+func sortWithComparator(caller object.Caller, args ...object.Object) object.Object {
+	arr, cmp, err := callbackArguments("sort", args)
+	if err != nil {
+		return err
+	}
+
+	elements := make([]object.Object, len(arr.Elements))
+	copy(elements, arr.Elements)
+
+	var failed object.Object
+	sort.SliceStable(elements, func(i, j int) bool {
+		if failed != nil {
+			return false
+		}
+
+		switch result := callWithIndex(caller, cmp, -1, elements[i], elements[j]).(type) {
+		case *object.Error:
+			failed = result
+		case *object.Integer:
+			return result.Value < 0
+		case *object.Float:
+			return result.Value < 0
+		case *object.Boolean:
+			return result.Value
+		default:
+			failed = NewError(errors.ComparatorResultError("sort", string(result.Type())))
+		}
+		return false
+	})
+
+	if failed != nil {
+		return failed
+	}
+	return &object.Array{Elements: elements}
+}
+
+// callWithIndex calls fn with args followed by the index i, unless i is
+// negative. Builtins check how many arguments they are given, so they are
+// only given args.
+func callWithIndex(caller object.Caller, fn object.Object, i int, args ...object.Object) object.Object {
+	if _, ok := fn.(*object.Builtin); !ok && i >= 0 {
+		args = append(args, &object.Integer{Value: int64(i)})
+	}
+
+	// functions with empty bodies evaluate to nothing
+	if result := caller.Call(fn, args...); result != nil {
+		return result
+	}
+	return NULL
+}
+
+// callbackArguments checks the arguments of a builtin that takes an array
+// and a function to call for its elements
+func callbackArguments(name string, args []object.Object) (*object.Array, object.Object, *object.Error) {
+	if len(args) != 2 {
+		return nil, nil, NewError(errors.RequiresXArgumentsError(2, len(args), name))
+	}
+
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, nil, NewError(errors.ArgumentToXMustBeYError("array", name, object.ARRAY_OBJ, string(args[0].Type())))
+	}
+
+	switch args[1].(type) {
+	case *object.Function, *object.Builtin, *object.Closure:
+		return arr, args[1], nil
+	default:
+		return nil, nil, NewError(errors.ArgumentToXMustBeYError("function", name, object.FUNCTION_OBJ, string(args[1].Type())))
+	}
+}
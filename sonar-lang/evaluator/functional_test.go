@@ -0,0 +1,93 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+)
+
+func TestMapBuiltinWithFunction(t *testing.T) {
+	testEvalType[*object.Array](t, `map([1, 2, 3], func(x) { x * 2 })`, `[2, 4, 6]`)
+	testEvalType[*object.Array](t, `map(["a", "b"], func(x, i) { i })`, `[0, 1]`)
+	testEvalType[*object.Array](t, `map([[1], [1, 2]], len)`, `[1, 2]`)
+
+	// map with one argument still converts an array to a map
+	testEvalType[*object.Hash](t, `map([1])`, `{0: 1}`)
+}
+
+func TestFilterBuiltin(t *testing.T) {
+	testEvalType[*object.Array](t, `filter([1, 2, 3, 4], func(x) { x > 2 })`, `[3, 4]`)
+	testEvalType[*object.Array](t, `filter([], func(x) { true })`, `[]`)
+}
+
+func TestReduceBuiltin(t *testing.T) {
+	testEvalType[*object.Integer](t, `reduce([1, 2, 3, 4], func(acc, x) { acc + x })`, 10)
+	testEvalType[*object.Integer](t, `reduce([1, 2, 3], func(acc, x) { acc + x }, 10)`, 16)
+	testEvalType[*object.String](t, `reduce([], func(acc, x) { acc + x }, "empty")`, "empty")
+
+	if evaluated := testEval(`reduce([], func(acc, x) { acc + x })`); !isError(evaluated) {
+		t.Errorf("expected an error reducing an empty array, got=%s", evaluated.Inspect())
+	}
+}
+
+func TestEachBuiltin(t *testing.T) {
+	input := `let arr = [0, 0, 0]; each([3, 4, 5], func(x, i) { arr[i] = x * i }); arr`
+	testEvalType[*object.Array](t, input, `[0, 4, 10]`)
+}
+
+func TestFindAnyAllBuiltins(t *testing.T) {
+	testEvalType[*object.Integer](t, `find([1, 5, 10], func(x) { x > 3 })`, 5)
+	testNullObject(t, testEval(`find([1, 2], func(x) { x > 3 })`))
+	testEvalType[*object.Boolean](t, `any([1, 5], func(x) { x > 3 })`, "true")
+	testEvalType[*object.Boolean](t, `any([], func(x) { true })`, "false")
+	testEvalType[*object.Boolean](t, `all([4, 5], func(x) { x > 3 })`, "true")
+	testEvalType[*object.Boolean](t, `all([1, 5], func(x) { x > 3 })`, "false")
+}
+
+func TestSortBuiltinWithComparator(t *testing.T) {
+	testEvalType[*object.Array](t, `sort([3, 1, 2], func(a, b) { b - a })`, `[3, 2, 1]`)
+	testEvalType[*object.Array](t, `sort([10, 9, 100], func(a, b) { a < b })`, `[9, 10, 100]`)
+	testEvalType[*object.Array](t, `sort(["bb", "a", "ccc"], func(a, b) { len(a) - len(b) })`, `['a', 'bb', 'ccc']`)
+
+	// without a comparator, elements are compared as text
+	testEvalType[*object.Array](t, `sort([10, 9, 100])`, `[10, 100, 9]`)
+}
+
+func TestHigherOrderBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`map([1], 1)`, "'function' argument to 'map' must be FUNCTION, 'INTEGER' given"},
+		{`filter(1, func(x) { x })`, "'array' argument to 'filter' must be ARRAY, 'INTEGER' given"},
+		{`each([1])`, "Function 'each' requires 2 arguments, 1 given"},
+		{`sort([1, 2], func(a, b) { "no" })`, "Comparator given to 'sort' must return a number or a boolean, 'STRING' given"},
+		{`map([1, 2], func(x) { x + "a" })`, "Type mismatch: 'INTEGER + STRING'"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		err, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("expected error for %q, got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if msg := err.Conf.(errors.Error).Message; msg != tt.expected {
+			t.Errorf("expected %q, got=%q", tt.expected, msg)
+		}
+	}
+
+	// errors raised by callbacks are traced through the builtin's call site
+	err, ok := testEval("let double = func(x) {\n\tx + \"a\"\n}\nmap([1], double)").(*object.Error)
+	if !ok {
+		t.Fatalf("expected error")
+	}
+	expected := []object.Frame{
+		{Function: "double", Line: 2},
+		{Function: object.MODULE_FRAME, Line: 4},
+	}
+	if len(err.Traceback) != len(expected) || err.Traceback[0] != expected[0] || err.Traceback[1] != expected[1] {
+		t.Errorf("expected traceback %+v, got=%+v", expected, err.Traceback)
+	}
+}
@@ -0,0 +1,150 @@
+package evaluator
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/icheka/sonar-lang/sonar-lang/ast"
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/inputs"
+	"github.com/icheka/sonar-lang/sonar-lang/lexer"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+	"github.com/icheka/sonar-lang/sonar-lang/parser"
+	"github.com/icheka/sonar-lang/sonar-lang/token"
+)
+
+// MODULE_EXTENSION is added to import paths that do not have an extension
+const MODULE_EXTENSION = ".sonar"
+
+func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	module := loadModule(node, env)
+	if isError(module) {
+		return module
+	}
+
+	exports := module.(*object.Module).Exports
+	if node.Names != nil {
+		for _, name := range node.Names {
+			val, ok := exports[name.Value]
+			if !ok {
+				return NewError(errors.NotExportedError(node.Path.Value, name.Value, errorConfig(name)))
+			}
+			if result := bind(name, val, env); isError(result) {
+				return result
+			}
+		}
+		return NULL
+	}
+
+	name := node.Alias
+	if name == nil {
+		name = &ast.Identifier{Value: module.(*object.Module).Name, SourceSpan: node.Path.SourceSpan}
+	}
+	return bind(name, module, env)
+}
+
+// bind declares name in env, as a const statement would: imported names
+// cannot be reassigned, whether or not their module declared them const
+func bind(name *ast.Identifier, val object.Object, env *object.Environment) object.Object {
+	if _, ok := env.Store[name.Value]; ok {
+		return NewError(errors.IdentifierAlreadyDefinedError(name.Value, errorConfig(name)))
+	}
+	return env.SetConstant(name.Value, val)
+}
+
+// loadModule returns the module imported by node, evaluating it unless it has
+// already been loaded
+func loadModule(node *ast.ImportStatement, importer *object.Environment) object.Object {
+	return LoadModule(node.Path.Value, node.Path.Span().Start, importer.Modules(), func(program *ast.Program) (map[string]object.Object, *object.Error) {
+		env := object.NewModuleEnvironment(importer)
+		if result := evalStatements(program, env, node.Span().Start); isError(result) {
+			return nil, result.(*object.Error)
+		}
+
+		exports := make(map[string]object.Object)
+		for _, name := range env.Exports {
+			exports[name], _ = env.Get(name)
+		}
+		return exports, nil
+	})
+}
+
+// LoadModule returns the module imported as importPath by the import statement
+// whose path is at pos, among the modules loaded by a program. Unless it has
+// already been loaded, the module is parsed and given to run, which returns
+// the values of the names it exports.
+func LoadModule(importPath string, pos token.Position, modules *object.Modules, run func(*ast.Program) (map[string]object.Object, *object.Error)) object.Object {
+	path := resolveImportPath(importPath, pos.File)
+	if module, ok := modules.Get(path); ok {
+		return module
+	}
+
+	if cycle := modules.Begin(path); cycle != nil {
+		for i, c := range cycle {
+			cycle[i] = filepath.Base(c)
+		}
+		return NewError(errors.ImportCycleError(cycle, errors.NewErrorConfig(pos)))
+	}
+	defer modules.End(path)
+
+	source, err := (&inputs.FileInput{Path: path}).Load()
+	if err != nil {
+		return NewError(errors.ModuleNotFoundError(importPath, path, errors.NewErrorConfig(pos)))
+	}
+
+	p := parser.New(lexer.New(source, &lexer.LexerOptions{Path: path}))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 0 {
+		err := NewError(errs[0])
+		err.Related = errs[1:]
+		return err
+	}
+
+	exports, runErr := run(program)
+	if runErr != nil {
+		return runErr
+	}
+
+	module := &object.Module{
+		Name:    object.ModuleName(path),
+		Path:    path,
+		Exports: exports,
+	}
+	modules.Add(module)
+
+	return module
+}
+
+// resolveImportPath returns the absolute path of the file imported as
+// importPath by importer. Relative paths are resolved against the directory
+// of importer, or the working directory if the program was not read from a
+// file.
+func resolveImportPath(importPath, importer string) string {
+	path := importPath
+	if !filepath.IsAbs(path) {
+		dir := filepath.Dir(importer)
+		if len(importer) == 0 {
+			dir, _ = os.Getwd()
+		}
+		path = filepath.Join(dir, path)
+	}
+	if len(filepath.Ext(path)) == 0 {
+		path += MODULE_EXTENSION
+	}
+
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path
+}
+
+func evalModuleIndexExpression(module, index object.Object, node *ast.IndexExpression) object.Object {
+	m := module.(*object.Module)
+	name := index.(*object.String).Value
+
+	val, ok := m.Exports[name]
+	if !ok {
+		return NewError(errors.NotExportedError(m.Name, name, errorConfig(node.Index)))
+	}
+	return val
+}
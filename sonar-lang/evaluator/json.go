@@ -0,0 +1,246 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+)
+
+// JSONModule is the json global: json.parse(str) and json.stringify(value)
+var JSONModule = &object.Module{
+	Name: "json",
+	Exports: map[string]object.Object{
+		"parse": &object.Builtin{
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				/*
+					json.parse(str)
+					-- returns the value str encodes; integers become INTEGERs and
+					-- other numbers FLOATs
+				*/
+				str, err := stringArgument("json.parse", args)
+				if err != nil {
+					return err
+				}
+				return parseJSON(str.Value)
+			},
+		},
+		"stringify": &object.Builtin{
+			Fn: func(_ object.Caller, args ...object.Object) object.Object {
+				/*
+					json.stringify(value)
+					-- returns value encoded as JSON, with map keys in the order they were set;
+					-- the keys must be strings
+					json.stringify(value, indent)
+					-- indents nested values by indent, a number of spaces or a string
+				*/
+				if err := argumentCount("json.stringify", args, 1, 2); err != nil {
+					return err
+				}
+
+				var out bytes.Buffer
+				e := &jsonEncoder{out: &out, visiting: map[object.Object]bool{}}
+				if err := e.encode(args[0]); err != nil {
+					return err
+				}
+				if len(args) == 1 {
+					return &object.String{Value: out.String()}
+				}
+
+				indent, err := jsonIndent(args[1])
+				if err != nil {
+					return err
+				}
+				var indented bytes.Buffer
+				json.Indent(&indented, out.Bytes(), "", indent)
+				return &object.String{Value: indented.String()}
+			},
+		},
+	},
+}
+
+func parseJSON(str string) object.Object {
+	dec := json.NewDecoder(strings.NewReader(str))
+	dec.UseNumber()
+
+	val, err := decodeJSON(dec)
+	if err == nil {
+		// nothing may follow the value
+		if _, err = dec.Token(); err == io.EOF {
+			return val
+		} else if err == nil {
+			err = jsonSyntaxError("unexpected data after the value", dec)
+		}
+	}
+
+	if err == io.EOF {
+		err = jsonSyntaxError("unexpected end of input", dec)
+	}
+	return NewError(errors.InvalidJSONError(err.Error()))
+}
+
+// decodeJSON decodes the next value from dec, keeping the order of the keys
+// of objects
+func decodeJSON(dec *json.Decoder) (object.Object, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case nil:
+		return NULL, nil
+	case bool:
+		return nativeBoolToBooleanObject(tok), nil
+	case string:
+		return &object.String{Value: tok}, nil
+	case json.Number:
+		if i, err := strconv.ParseInt(string(tok), 10, 64); err == nil {
+			return &object.Integer{Value: i}, nil
+		}
+		f, err := strconv.ParseFloat(string(tok), 64)
+		if err != nil {
+			return nil, jsonSyntaxError("number "+string(tok)+" is out of range", dec)
+		}
+		return &object.Float{Value: f}, nil
+	case json.Delim:
+		if tok == '[' {
+			elements := []object.Object{}
+			for dec.More() {
+				element, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				elements = append(elements, element)
+			}
+			_, err := dec.Token()
+			return &object.Array{Elements: elements}, err
+		}
+
+		hash := object.NewHash()
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := &object.String{Value: keyTok.(string)}
+			value, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(key.HashKey(), object.HashPair{Key: key, Value: value})
+		}
+		_, err := dec.Token()
+		return hash, err
+	}
+
+	return nil, jsonSyntaxError("unexpected token", dec)
+}
+
+func jsonSyntaxError(msg string, dec *json.Decoder) error {
+	return fmt.Errorf("%s at offset %d", msg, dec.InputOffset())
+}
+
+type jsonEncoder struct {
+	out *bytes.Buffer
+	// the arrays and maps being encoded, to detect values that contain themselves
+	visiting map[object.Object]bool
+}
+
+func (e *jsonEncoder) encode(val object.Object) *object.Error {
+	switch val := val.(type) {
+	case *object.Null:
+		e.out.WriteString("null")
+	case *object.Boolean:
+		e.out.WriteString(strconv.FormatBool(val.Value))
+	case *object.Integer:
+		e.out.WriteString(strconv.FormatInt(val.Value, 10))
+	case *object.Float:
+		if math.IsNaN(val.Value) || math.IsInf(val.Value, 0) {
+			return NewError(errors.UnserializableValueError(val.Inspect()))
+		}
+		// keep a decimal point, so the value is parsed back as a float
+		s := strconv.FormatFloat(val.Value, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		e.out.WriteString(s)
+	case *object.String:
+		e.encodeString(val.Value)
+	case *object.Array:
+		if err := e.enter(val); err != nil {
+			return err
+		}
+		e.out.WriteByte('[')
+		for i, element := range val.Elements {
+			if i > 0 {
+				e.out.WriteByte(',')
+			}
+			if err := e.encode(element); err != nil {
+				return err
+			}
+		}
+		e.out.WriteByte(']')
+		delete(e.visiting, val)
+	case *object.Hash:
+		if err := e.enter(val); err != nil {
+			return err
+		}
+		e.out.WriteByte('{')
+		for i, pair := range val.Ordered() {
+			if i > 0 {
+				e.out.WriteByte(',')
+			}
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return NewError(errors.UnserializableKeyError(pair.Key.Inspect(), string(pair.Key.Type())))
+			}
+			e.encodeString(key.Value)
+			e.out.WriteByte(':')
+			if err := e.encode(pair.Value); err != nil {
+				return err
+			}
+		}
+		e.out.WriteByte('}')
+		delete(e.visiting, val)
+	default:
+		return NewError(errors.UnserializableValueError(string(val.Type())))
+	}
+	return nil
+}
+
+func (e *jsonEncoder) enter(val object.Object) *object.Error {
+	if e.visiting[val] {
+		return NewError(errors.CyclicValueError(string(val.Type())))
+	}
+	e.visiting[val] = true
+	return nil
+}
+
+func (e *jsonEncoder) encodeString(s string) {
+	enc := json.NewEncoder(e.out)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	// Encode ends the value with a newline
+	e.out.Truncate(e.out.Len() - 1)
+}
+
+// jsonIndent returns the indent argument of json.stringify as a string
+func jsonIndent(arg object.Object) (string, *object.Error) {
+	switch arg := arg.(type) {
+	case *object.Integer:
+		if arg.Value < 0 {
+			break
+		}
+		return strings.Repeat(" ", int(arg.Value)), nil
+	case *object.String:
+		return arg.Value, nil
+	}
+	return "", NewError(errors.ArgumentToXMustBeYError("indent", "json.stringify", "a non-negative INTEGER or a STRING", arg.Inspect()))
+}
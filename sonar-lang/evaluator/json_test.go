@@ -0,0 +1,102 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+)
+
+func TestJSONParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json.parse("1")`, "1"},
+		{`type(json.parse("1"))`, "INTEGER"},
+		{`type(json.parse("1.0"))`, "FLOAT"},
+		{`type(json.parse("1e3"))`, "FLOAT"},
+		{`type(json.parse("99999999999999999999"))`, "FLOAT"},
+		{`json.parse("-2.5")`, "-2.5"},
+		{`json.parse("true")`, "true"},
+		{`json.parse("null")`, "null"},
+		{`json.parse(" \"a\\u00e9\" ")`, "aé"},
+		{`json.parse("[1, [\"a\"], {}]")`, "[1, ['a'], {}]"},
+		{`json.parse("{\"a\": {\"b\": [true]}}")`, "{'a': {'b': [true]}}"},
+		{`json.parse("{\"b\": 1, \"a\": 2, \"b\": 3}")`, "{'b': 3, 'a': 2}"},
+		{`json.parse("{\"a\": 1}").a`, "1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if isError(evaluated) {
+			t.Errorf("%s: unexpected error %+v", tt.input, evaluated.(*object.Error).Conf)
+			continue
+		}
+		if got := concat([]object.Object{evaluated}).Value; got != tt.expected {
+			t.Errorf("%s: expected %q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestJSONStringify(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json.stringify(1)`, `1`},
+		{`json.stringify(1.0)`, `1.0`},
+		{`json.stringify(0.5)`, `0.5`},
+		{`json.stringify(pow(10.0, 21))`, `1e+21`},
+		{`json.stringify("a\"<b>\n")`, `"a\"<b>\n"`},
+		{`json.stringify(json.parse("null"))`, `null`},
+		{`json.stringify([1, true, "x"])`, `[1,true,"x"]`},
+		{`json.stringify({"b": 1, "a": [], "3": "c"})`, `{"b":1,"a":[],"3":"c"}`},
+		{`json.stringify([1, {"a": 2}], 2)`, "[\n  1,\n  {\n    \"a\": 2\n  }\n]"},
+		{`json.stringify([1], "\t")`, "[\n\t1\n]"},
+		{`json.stringify([], 2)`, "[]"},
+		{`json.stringify(json.parse("{\"x\": [1.5, \"y\"], \"w\": {}}"))`, `{"x":[1.5,"y"],"w":{}}`},
+		{`let a = [1]; json.stringify([a, a])`, `[[1],[1]]`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if isError(evaluated) {
+			t.Errorf("%s: unexpected error %+v", tt.input, evaluated.(*object.Error).Conf)
+			continue
+		}
+		testStringObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json.parse("")`, "Invalid JSON: unexpected end of input at offset 0"},
+		{`json.parse("[1, 2")`, "Invalid JSON: unexpected end of JSON input"},
+		{`json.parse("1 2")`, "Invalid JSON: unexpected data after the value at offset 3"},
+		{`json.parse("{'a': 1}")`, "Invalid JSON: invalid character '\\'' looking for beginning of value"},
+		{`json.parse(1)`, "'string' argument to 'json.parse' must be STRING, 'INTEGER' given"},
+		{`json.stringify(len)`, "Cannot convert BUILTIN to JSON"},
+		{`json.stringify(func() {})`, "Cannot convert FUNCTION to JSON"},
+		{`json.stringify({"a": 1, 3: "c"})`, "Cannot convert map key 3 to JSON: keys must be STRINGs, INTEGER given"},
+		{`json.stringify([{true: 1}])`, "Cannot convert map key true to JSON: keys must be STRINGs, BOOLEAN given"},
+		{`json.stringify(sqrt(-1))`, "Cannot convert NaN to JSON"},
+		{`let m = {}; m["self"] = [m]; json.stringify(m)`, "Cannot convert MAP to JSON: it contains itself"},
+		{`json.stringify(1, -1)`, "'indent' argument to 'json.stringify' must be a non-negative INTEGER or a STRING, '-1' given"},
+		{`json.stringify()`, "'json.stringify' requires at least 1 argument, 0 given"},
+	}
+
+	for _, tt := range tests {
+		err, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if msg := err.Conf.(errors.Error).Message; msg != tt.expected {
+			t.Errorf("%s: expected %q, got=%q", tt.input, tt.expected, msg)
+		}
+	}
+}
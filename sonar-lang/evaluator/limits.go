@@ -0,0 +1,124 @@
+package evaluator
+
+import (
+	"context"
+
+	"github.com/icheka/sonar-lang/sonar-lang/ast"
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+	"github.com/icheka/sonar-lang/sonar-lang/token"
+)
+
+// EvalContext evaluates node like Eval, but stops with a LimitError once ctx
+// is done or the program exceeds limits
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, limits object.Limits) object.Object {
+	if limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+		defer cancel()
+	}
+
+	execution := object.NewExecution(ctx, limits)
+	if err := execution.Err(); err != nil {
+		return err
+	}
+
+	previous := env.SetExecution(execution)
+	defer env.SetExecution(previous)
+
+	return Eval(node, env)
+}
+
+// step records a loop iteration in the program env belongs to
+func step(env *object.Environment, node ast.Node) *object.Error {
+	execution := env.Execution()
+	if execution == nil {
+		return nil
+	}
+
+	err := execution.Step()
+	if err != nil {
+		err.SetPosition(node.Span().Start)
+	}
+	return err
+}
+
+// allocate records the creation of obj in the program env belongs to
+func allocate(env *object.Environment, obj object.Object) *object.Error {
+	execution := env.Execution()
+	if execution == nil {
+		return nil
+	}
+
+	switch obj := obj.(type) {
+	case *object.Array:
+		return execution.Allocate(int64(len(obj.Elements)), 0)
+	case *object.Hash:
+		return execution.Allocate(int64(len(obj.Pairs)), 0)
+	case *object.String:
+		return execution.Allocate(0, int64(len(obj.Value)))
+	}
+	return nil
+}
+
+// reserveInfix records the allocations an infix expression is about to make,
+// before it makes them, so that `[0] * 1000000000` fails without first
+// allocating a billion elements
+func reserveInfix(env *object.Environment, operator string, left, right object.Object) *object.Error {
+	execution := env.Execution()
+	if execution == nil {
+		return nil
+	}
+
+	switch left := left.(type) {
+	case *object.String:
+		if right, ok := right.(*object.String); ok && operator == token.PLUS {
+			return execution.Allocate(0, int64(len(left.Value)+len(right.Value)))
+		}
+	case *object.Array:
+		switch right := right.(type) {
+		case *object.Array:
+			if operator == token.PLUS {
+				return execution.Allocate(int64(len(left.Elements)+len(right.Elements)), 0)
+			}
+		case *object.Integer:
+			if operator == token.ASTERISK && right.Value > 0 {
+				return execution.Allocate(right.Value, 0)
+			}
+		}
+	}
+	return nil
+}
+
+// isLimitError reports whether obj is an error raised for exceeding a limit,
+// which scripts cannot catch
+func isLimitError(obj object.Object) bool {
+	err, ok := obj.(*object.Error)
+	if !ok {
+		return false
+	}
+	conf, ok := err.Conf.(errors.Error)
+	return ok && conf.Type == errors.LIMIT_ERROR
+}
+
+// reserveKey records the element an index assignment adds to a map when
+// index is not already one of its keys
+func reserveKey(env *object.Environment, left, index object.Object) *object.Error {
+	execution := env.Execution()
+	if execution == nil {
+		return nil
+	}
+
+	hash, ok := left.(*object.Hash)
+	if !ok {
+		return nil
+	}
+	hashKey, ok := object.HashKeyOf(index)
+	if !ok {
+		return nil
+	}
+	if _, ok := hash.Pairs[hashKey]; ok {
+		return nil
+	}
+	return execution.Allocate(1, 0)
+}
@@ -7,7 +7,7 @@ import (
 
 var MapBuiltins = map[string]*object.Builtin{
 	"mapKeys": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return NewError(errors.RequiresXArgumentsError(1, len(args), "mapKeys"))
 			}
@@ -19,7 +19,7 @@ var MapBuiltins = map[string]*object.Builtin{
 			mapObj := args[0].(*object.Hash)
 			keys := []object.Object{}
 
-			for _, pair := range mapObj.Pairs {
+			for _, pair := range mapObj.Ordered() {
 				keys = append(keys, pair.Key)
 			}
 
@@ -27,7 +27,7 @@ var MapBuiltins = map[string]*object.Builtin{
 		},
 	},
 	"mapValues": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return NewError(errors.RequiresXArgumentsError(1, len(args), "mapValues"))
 			}
@@ -39,7 +39,7 @@ var MapBuiltins = map[string]*object.Builtin{
 			mapObj := args[0].(*object.Hash)
 			values := []object.Object{}
 
-			for _, pair := range mapObj.Pairs {
+			for _, pair := range mapObj.Ordered() {
 				values = append(values, pair.Value)
 			}
 
@@ -47,7 +47,7 @@ var MapBuiltins = map[string]*object.Builtin{
 		},
 	},
 	"mapEntries": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return NewError(errors.RequiresXArgumentsError(1, len(args), "mapEntries"))
 			}
@@ -59,7 +59,7 @@ var MapBuiltins = map[string]*object.Builtin{
 			mapObj := args[0].(*object.Hash)
 			entries := [][]object.Object{}
 
-			for _, pair := range mapObj.Pairs {
+			for _, pair := range mapObj.Ordered() {
 				entries = append(entries, []object.Object{pair.Key, pair.Value})
 			}
 
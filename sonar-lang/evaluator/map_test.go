@@ -20,3 +20,31 @@ func TestMapKeysBuiltin(t *testing.T) {
 	input := `mapKeys({"a": 1})`
 	testEvalType[*object.Hash](t, input, "['a']")
 }
+
+func TestMapsKeepInsertionOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"z": 1, "a": 2, 3: 3, true: 4}`, "{'z': 1, 'a': 2, 3: 3, true: 4}"},
+		{`{"b": 1, "a": 2, "b": 3}`, "{'b': 3, 'a': 2}"},
+		{`let m = {"z": 1}; m["a"] = 2; m["m"] = 3; m["z"] = 4; m`, "{'z': 4, 'a': 2, 'm': 3}"},
+		{`let m = {"z": 1, "a": 2}; m = m - "z"; m["z"] = 3; m`, "{'a': 2, 'z': 3}"},
+		{`mapKeys({"z": 1, "a": 2, "m": 3})`, "['z', 'a', 'm']"},
+		{`mapValues({"z": 1, "a": 2, "m": 3})`, "[1, 2, 3]"},
+		{`mapEntries({"z": 1, "a": 2})`, "[['z', 1], ['a', 2]]"},
+		{`let keys = []; for (k, v in {"z": 1, "a": 2, "m": 3}) { keys = push(keys, k) }; keys`, "['z', 'a', 'm']"},
+		{`let m = {"z": 1, "a": 2}; let c = copy(m); c["b"] = 3; [m, c]`, "[{'z': 1, 'a': 2}, {'z': 1, 'a': 2, 'b': 3}]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if isError(evaluated) {
+			t.Errorf("%s: unexpected error %+v", tt.input, evaluated.(*object.Error).Conf)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
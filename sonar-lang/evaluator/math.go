@@ -0,0 +1,324 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+)
+
+// MathConstants are looked up like builtins, after the program's own names
+var MathConstants = map[string]object.Object{
+	"PI":      &object.Float{Value: math.Pi},
+	"E":       &object.Float{Value: math.E},
+	"MAX_INT": &object.Integer{Value: math.MaxInt64},
+	"MIN_INT": &object.Integer{Value: math.MinInt64},
+}
+
+// MathBuiltins follow the arithmetic operators: functions of integers return
+// integers where the result is exact, and integers are converted to floats
+// wherever they are mixed with floats
+var MathBuiltins = map[string]*object.Builtin{
+	"abs": {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			n, err := numberArgument("abs", args)
+			if err != nil {
+				return err
+			}
+			if integer, ok := n.(*object.Integer); ok {
+				if integer.Value == math.MinInt64 {
+					// -MIN_INT is one more than MAX_INT
+					return NewError(errors.IntegerOverflowError("abs", integer.Inspect()))
+				}
+				if integer.Value < 0 {
+					return &object.Integer{Value: -integer.Value}
+				}
+				return integer
+			}
+			return &object.Float{Value: math.Abs(toFloat64(n))}
+		},
+	},
+	"floor": roundingBuiltin("floor", math.Floor),
+	"ceil":  roundingBuiltin("ceil", math.Ceil),
+	"round": roundingBuiltin("round", math.Round),
+	"sqrt":  floatBuiltin("sqrt", math.Sqrt),
+	"exp":   floatBuiltin("exp", math.Exp),
+	"log":   floatBuiltin("log", math.Log),
+	"log2":  floatBuiltin("log2", math.Log2),
+	"log10": floatBuiltin("log10", math.Log10),
+	"sin":   floatBuiltin("sin", math.Sin),
+	"cos":   floatBuiltin("cos", math.Cos),
+	"tan":   floatBuiltin("tan", math.Tan),
+	"asin":  floatBuiltin("asin", math.Asin),
+	"acos":  floatBuiltin("acos", math.Acos),
+	"atan":  floatBuiltin("atan", math.Atan),
+	"atan2": {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return NewError(errors.RequiresXArgumentsError(2, len(args), "atan2"))
+			}
+			if err := checkNumbers("atan2", args); err != nil {
+				return err
+			}
+			return &object.Float{Value: math.Atan2(toFloat64(args[0]), toFloat64(args[1]))}
+		},
+	},
+	"pow": {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			/*
+				pow(x, y)
+				-- returns x to the power of y, an integer if both are and y is not negative
+			*/
+			if len(args) != 2 {
+				return NewError(errors.RequiresXArgumentsError(2, len(args), "pow"))
+			}
+			if err := checkNumbers("pow", args); err != nil {
+				return err
+			}
+
+			base, ok := args[0].(*object.Integer)
+			exponent, ok2 := args[1].(*object.Integer)
+			if ok && ok2 && exponent.Value >= 0 {
+				result, ok := integerPow(base.Value, exponent.Value)
+				if !ok {
+					given := fmt.Sprintf("%d and %d", base.Value, exponent.Value)
+					return NewError(errors.IntegerOverflowError("pow", given))
+				}
+				return &object.Integer{Value: result}
+			}
+			return &object.Float{Value: math.Pow(toFloat64(args[0]), toFloat64(args[1]))}
+		},
+	},
+	"min": {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return extremum("min", args, func(a, b object.Object) bool { return compareNumbers(a, b) < 0 })
+		},
+	},
+	"max": {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return extremum("max", args, func(a, b object.Object) bool { return compareNumbers(a, b) > 0 })
+		},
+	},
+	"sum": {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			/*
+				sum(arr)
+				sum(x, y, ...)
+				-- returns the sum of the numbers, 0 if there are none
+			*/
+			values, err := numberList("sum", args)
+			if err != nil {
+				return err
+			}
+
+			var total object.Object = &object.Integer{Value: 0}
+			for _, v := range values {
+				total = EvalInfix("+", total, v)
+			}
+			return total
+		},
+	},
+	"clamp": {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			/*
+				clamp(x, lo, hi)
+				-- returns lo if x < lo, hi if x > hi, and x otherwise
+			*/
+			if len(args) != 3 {
+				return NewError(errors.RequiresXArgumentsError(3, len(args), "clamp"))
+			}
+			if err := checkNumbers("clamp", args); err != nil {
+				return err
+			}
+
+			x, lo, hi := args[0], args[1], args[2]
+			if compareNumbers(lo, hi) > 0 {
+				return NewError(errors.InvalidClampBoundsError(lo.Inspect(), hi.Inspect()))
+			}
+			if compareNumbers(x, lo) < 0 {
+				return lo
+			}
+			if compareNumbers(x, hi) > 0 {
+				return hi
+			}
+			return x
+		},
+	},
+	"isNaN": {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			n, err := numberArgument("isNaN", args)
+			if err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(math.IsNaN(toFloat64(n)))
+		},
+	},
+	"isInf": {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			n, err := numberArgument("isInf", args)
+			if err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(math.IsInf(toFloat64(n), 0))
+		},
+	},
+}
+
+// floatBuiltin returns a builtin applying fn to its one number argument
+func floatBuiltin(name string, fn func(float64) float64) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			n, err := numberArgument(name, args)
+			if err != nil {
+				return err
+			}
+			return &object.Float{Value: fn(toFloat64(n))}
+		},
+	}
+}
+
+// roundingBuiltin is like floatBuiltin, but returns integers as they are and
+// rounds floats to an integer wherever the result fits in one
+func roundingBuiltin(name string, fn func(float64) float64) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			n, err := numberArgument(name, args)
+			if err != nil {
+				return err
+			}
+			if integer, ok := n.(*object.Integer); ok {
+				return integer
+			}
+			result := fn(toFloat64(n))
+			// -2**63 is exactly representable as a float64, 2**63 - 1 is not;
+			// NaN fails both comparisons
+			if result >= math.MinInt64 && result < -math.MinInt64 {
+				return &object.Integer{Value: int64(result)}
+			}
+			return &object.Float{Value: result}
+		},
+	}
+}
+
+// extremum implements min and max, which take numbers or an array of them
+func extremum(name string, args []object.Object, better func(a, b object.Object) bool) object.Object {
+	values, err := numberList(name, args)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return NewError(errors.EmptyArgumentsError(name))
+	}
+
+	result := values[0]
+	for _, v := range values[1:] {
+		if better(v, result) {
+			result = v
+		}
+	}
+	return result
+}
+
+// numberList returns the numbers a builtin was given, either as its
+// arguments or as the elements of its one array argument
+func numberList(name string, args []object.Object) ([]object.Object, *object.Error) {
+	if len(args) == 1 {
+		if arr, ok := args[0].(*object.Array); ok {
+			args = arr.Elements
+		}
+	}
+	if err := checkNumbers(name, args); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// numberArgument checks that a builtin taking one number was given one
+func numberArgument(name string, args []object.Object) (object.Object, *object.Error) {
+	if len(args) != 1 {
+		return nil, NewError(errors.RequiresXArgumentsError(1, len(args), name))
+	}
+	if err := checkNumbers(name, args); err != nil {
+		return nil, err
+	}
+	return args[0], nil
+}
+
+func checkNumbers(name string, args []object.Object) *object.Error {
+	for i, arg := range args {
+		switch arg.(type) {
+		case *object.Integer, *object.Float:
+		default:
+			return NewError(errors.ArgumentToXAtYMustBeZError(i, name, "INTEGER or FLOAT", string(arg.Type())))
+		}
+	}
+	return nil
+}
+
+func toFloat64(n object.Object) float64 {
+	if integer, ok := n.(*object.Integer); ok {
+		return float64(integer.Value)
+	}
+	return n.(*object.Float).Value
+}
+
+// compareNumbers returns -1, 0 or 1 as a is less than, equal to or greater
+// than b. Integers are only converted to floats when compared with floats.
+func compareNumbers(a, b object.Object) int {
+	x, ok := a.(*object.Integer)
+	y, ok2 := b.(*object.Integer)
+	if ok && ok2 {
+		switch {
+		case x.Value < y.Value:
+			return -1
+		case x.Value > y.Value:
+			return 1
+		}
+		return 0
+	}
+
+	f, g := toFloat64(a), toFloat64(b)
+	switch {
+	case f < g:
+		return -1
+	case f > g:
+		return 1
+	}
+	return 0
+}
+
+// integerPow returns base**exponent, and false if that does not fit in an
+// int64
+func integerPow(base, exponent int64) (int64, bool) {
+	result := int64(1)
+	var ok bool
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			if result, ok = multiply(result, base); !ok {
+				return 0, false
+			}
+		}
+		exponent >>= 1
+		// the last square is never used, so it may overflow
+		if exponent > 0 {
+			if base, ok = multiply(base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+	return result, true
+}
+
+// multiply returns a*b, and false if that does not fit in an int64
+func multiply(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	product := a * b
+	// MIN_INT / -1 wraps around to MIN_INT, hiding that MIN_INT * -1 did too
+	if product/b != a || (a == math.MinInt64 && b == -1) {
+		return 0, false
+	}
+	return product, true
+}
@@ -0,0 +1,106 @@
+package evaluator
+
+import (
+	"math"
+	"testing"
+
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+)
+
+func TestMathBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`abs(-3)`, 3},
+		{`abs(-2.5)`, 2.5},
+		{`floor(2.7)`, 2},
+		{`floor(-2.5)`, -3},
+		{`floor(2)`, 2},
+		{`ceil(2.1)`, 3},
+		{`round(2.5)`, 3},
+		{`round(-2.5)`, -3},
+		{`floor(MAX_INT * 4.0)`, 36893488147419103232.0},
+		{`floor(-9223372036854775808.0)`, math.MinInt64},
+		{`ceil(9223372036854775807.0)`, 9223372036854775808.0},
+		{`isNaN(floor(sqrt(-1)))`, true},
+		{`sqrt(16)`, 4.0},
+		{`pow(2, 10)`, 1024},
+		{`pow(2, -1)`, 0.5},
+		{`pow(2.0, 3)`, 8.0},
+		{`pow(-2, 63)`, math.MinInt64},
+		{`pow(3, 39)`, 4052555153018976267},
+		{`pow(-1, MAX_INT)`, -1},
+		{`pow(0, 0)`, 1},
+		{`exp(0)`, 1.0},
+		{`log(E)`, 1.0},
+		{`log2(8)`, 3.0},
+		{`log10(1000)`, 3.0},
+		{`sin(0)`, 0.0},
+		{`cos(PI)`, -1.0},
+		{`atan2(1, 1) * 4`, math.Pi},
+		{`min(3, 1, 2)`, 1},
+		{`min(3, 1.5, 2)`, 1.5},
+		{`max([3, 1, 7])`, 7},
+		{`max(1, 1.0)`, 1},
+		{`sum([1, 2, 3])`, 6},
+		{`sum(1, 2.5)`, 3.5},
+		{`sum([])`, 0},
+		{`clamp(5, 0, 3)`, 3},
+		{`clamp(-1, 0.5, 3)`, 0.5},
+		{`clamp(2, 0, 3)`, 2},
+		{`isNaN(sqrt(-1))`, true},
+		{`isNaN(1)`, false},
+		{`isInf(log(0))`, true},
+		{`MAX_INT`, math.MaxInt64},
+		{`MIN_INT`, math.MinInt64},
+		{`let PI = 3; PI`, 3},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			f, ok := evaluated.(*object.Float)
+			if !ok {
+				t.Errorf("%s: expected a FLOAT, got=%T (%+v)", tt.input, evaluated, evaluated)
+			} else if math.Abs(f.Value-expected) > 1e-9 {
+				t.Errorf("%s: expected %f, got=%f", tt.input, expected, f.Value)
+			}
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestMathBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`abs("1")`, "Argument to 'abs' at index 0 must be INTEGER or FLOAT, STRING given"},
+		{`abs(MIN_INT)`, "Result of 'abs' for -9223372036854775808 does not fit in an INTEGER"},
+		{`sqrt()`, "Function 'sqrt' requires 1 argument, 0 given"},
+		{`pow(1)`, "Function 'pow' requires 2 arguments, 1 given"},
+		{`pow(2, 63)`, "Result of 'pow' for 2 and 63 does not fit in an INTEGER"},
+		{`pow(MAX_INT, 2)`, "Result of 'pow' for 9223372036854775807 and 2 does not fit in an INTEGER"},
+		{`pow(3, 40)`, "Result of 'pow' for 3 and 40 does not fit in an INTEGER"},
+		{`max([1, "a"])`, "Argument to 'max' at index 1 must be INTEGER or FLOAT, STRING given"},
+		{`min([])`, "'min' requires at least one number"},
+		{`clamp(1, 3, 2)`, "Lower bound given to 'clamp' (3) is greater than its upper bound (2)"},
+	}
+
+	for _, tt := range tests {
+		err, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if msg := err.Conf.(errors.Error).Message; msg != tt.expected {
+			t.Errorf("%s: expected %q, got=%q", tt.input, tt.expected, msg)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package evaluator
+
+import "github.com/icheka/sonar-lang/sonar-lang/object"
+
+// OS_MODULE names the os global: os.args, the arguments the program was run
+// with
+const OS_MODULE = "os"
+
+// NewOSModule returns the os global of a program run with args. Each use of
+// os gets its own, so that programs cannot change it for each other.
+func NewOSModule(args []string) *object.Module {
+	elements := make([]object.Object, len(args))
+	for i, arg := range args {
+		elements[i] = &object.String{Value: arg}
+	}
+	return &object.Module{
+		Name:    OS_MODULE,
+		Exports: map[string]object.Object{"args": &object.Array{Elements: elements}},
+	}
+}
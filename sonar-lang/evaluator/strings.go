@@ -0,0 +1,383 @@
+package evaluator
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+)
+
+// MAX_STRING_BYTES is the longest string repeat and padLeft/padRight make,
+// whatever the limits, so that they fail instead of overflowing
+const MAX_STRING_BYTES = 1 << 30
+
+var StringBuiltins = map[string]*object.Builtin{
+	"split": {
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			/*
+				split(str, sep)
+				-- returns the substrings of str between each sep, or its characters if sep is ""
+				split(str, sep, n)
+				-- returns at most n substrings, the last being the rest of str
+			*/
+			if err := argumentCount("split", args, 2, 3); err != nil {
+				return err
+			}
+			str, sep, err := twoStrings("split", args, "separator")
+			if err != nil {
+				return err
+			}
+			n := int64(-1)
+			if len(args) == 3 {
+				if n, err = integerArgument("split", "count", args[2]); err != nil {
+					return err
+				}
+			}
+
+			parts := int64(utf8.RuneCountInString(str))
+			if sep != "" {
+				parts = int64(strings.Count(str, sep)) + 1
+			}
+			if n >= 0 && parts > n {
+				parts = n
+			}
+			if err := caller.Allocate(parts, int64(len(str))); err != nil {
+				return err
+			}
+
+			return stringsToArray(strings.SplitN(str, sep, int(n)))
+		},
+	},
+	"join": {
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			/*
+				join(arr)
+				join(arr, sep)
+				-- returns the elements of arr, converted as by str(), joined by sep
+			*/
+			if err := argumentCount("join", args, 1, 2); err != nil {
+				return err
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return NewError(errors.ArgumentToXMustBeYError("array", "join", object.ARRAY_OBJ, string(args[0].Type())))
+			}
+			sep := ""
+			if len(args) == 2 {
+				s, err := stringArgumentAt("join", "separator", args[1])
+				if err != nil {
+					return err
+				}
+				sep = s
+			}
+
+			parts := make([]string, len(arr.Elements))
+			size := int64(len(sep)) * int64(len(parts)-1)
+			for i, element := range arr.Elements {
+				parts[i] = concat([]object.Object{element}).Value
+				size += int64(len(parts[i]))
+			}
+			if err := caller.Allocate(0, size); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.Join(parts, sep)}
+		},
+	},
+	"trim": {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return trim("trim", strings.TrimSpace, strings.Trim, args)
+		},
+	},
+	"trimLeft": {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			trimSpace := func(s string) string { return strings.TrimLeftFunc(s, unicode.IsSpace) }
+			return trim("trimLeft", trimSpace, strings.TrimLeft, args)
+		},
+	},
+	"trimRight": {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			trimSpace := func(s string) string { return strings.TrimRightFunc(s, unicode.IsSpace) }
+			return trim("trimRight", trimSpace, strings.TrimRight, args)
+		},
+	},
+	"upper": {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			str, err := stringArgument("upper", args)
+			if err != nil {
+				return err
+			}
+			return &object.String{Value: strings.ToUpper(str.Value)}
+		},
+	},
+	"lower": {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			str, err := stringArgument("lower", args)
+			if err != nil {
+				return err
+			}
+			return &object.String{Value: strings.ToLower(str.Value)}
+		},
+	},
+	"replace": {
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			/*
+				replace(str, old, new)
+				-- returns str with every old replaced by new
+				replace(str, old, new, n)
+				-- replaces only the first n
+			*/
+			if err := argumentCount("replace", args, 3, 4); err != nil {
+				return err
+			}
+			str, old, err := twoStrings("replace", args, "old")
+			if err != nil {
+				return err
+			}
+			replacement, err := stringArgumentAt("replace", "new", args[2])
+			if err != nil {
+				return err
+			}
+			n := int64(-1)
+			if len(args) == 4 {
+				if n, err = integerArgument("replace", "count", args[3]); err != nil {
+					return err
+				}
+			}
+
+			replaced := int64(strings.Count(str, old))
+			if old == "" {
+				replaced = int64(utf8.RuneCountInString(str)) + 1
+			}
+			if n >= 0 && replaced > n {
+				replaced = n
+			}
+			if err := caller.Allocate(0, int64(len(str))+replaced*int64(len(replacement))); err != nil {
+				return err
+			}
+
+			return &object.String{Value: strings.Replace(str, old, replacement, int(n))}
+		},
+	},
+	"startsWith": {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return NewError(errors.RequiresXArgumentsError(2, len(args), "startsWith"))
+			}
+			str, prefix, err := twoStrings("startsWith", args, "prefix")
+			if err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(strings.HasPrefix(str, prefix))
+		},
+	},
+	"endsWith": {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return NewError(errors.RequiresXArgumentsError(2, len(args), "endsWith"))
+			}
+			str, suffix, err := twoStrings("endsWith", args, "suffix")
+			if err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(strings.HasSuffix(str, suffix))
+		},
+	},
+	"repeat": {
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return NewError(errors.RequiresXArgumentsError(2, len(args), "repeat"))
+			}
+			str, err := stringArgumentAt("repeat", "string", args[0])
+			if err != nil {
+				return err
+			}
+			n, err := integerArgument("repeat", "count", args[1])
+			if err != nil {
+				return err
+			}
+			if n < 0 {
+				return NewError(errors.ArgumentToXMustBeYError("count", "repeat", "a non-negative INTEGER", args[1].Inspect()))
+			}
+
+			size, ok := repeatedSize(len(str), n)
+			if !ok {
+				return NewError(errors.StringTooLongError("repeat", MAX_STRING_BYTES))
+			}
+			if err := caller.Allocate(0, size); err != nil {
+				return err
+			}
+
+			return &object.String{Value: strings.Repeat(str, int(n))}
+		},
+	},
+	"padLeft": {
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			return pad(caller, "padLeft", args, func(str, padding string) string { return padding + str })
+		},
+	},
+	"padRight": {
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			return pad(caller, "padRight", args, func(str, padding string) string { return str + padding })
+		},
+	},
+	"lines": {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			/*
+				lines(str)
+				-- returns the lines of str, without their "\n" or "\r\n" endings
+			*/
+			str, err := stringArgument("lines", args)
+			if err != nil {
+				return err
+			}
+			if len(str.Value) == 0 {
+				return &object.Array{Elements: []object.Object{}}
+			}
+
+			lines := strings.Split(strings.TrimSuffix(str.Value, "\n"), "\n")
+			for i, line := range lines {
+				lines[i] = strings.TrimSuffix(line, "\r")
+			}
+			return stringsToArray(lines)
+		},
+	},
+	"fields": {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			/*
+				fields(str)
+				-- returns the words of str, split around runs of whitespace
+			*/
+			str, err := stringArgument("fields", args)
+			if err != nil {
+				return err
+			}
+			return stringsToArray(strings.Fields(str.Value))
+		},
+	},
+}
+
+// trim implements trim(str) and trim(str, chars), which remove whitespace or
+// any of chars from the ends of str
+func trim(name string, trimSpace func(string) string, trimChars func(string, string) string, args []object.Object) object.Object {
+	if err := argumentCount(name, args, 1, 2); err != nil {
+		return err
+	}
+	str, err := stringArgumentAt(name, "string", args[0])
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 1 {
+		return &object.String{Value: trimSpace(str)}
+	}
+	chars, err := stringArgumentAt(name, "chars", args[1])
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: trimChars(str, chars)}
+}
+
+// pad implements padLeft(str, width) and padRight(str, width), which add
+// spaces, or repetitions of a third argument, until str is width characters long
+func pad(caller object.Caller, name string, args []object.Object, join func(str, padding string) string) object.Object {
+	if err := argumentCount(name, args, 2, 3); err != nil {
+		return err
+	}
+	str, err := stringArgumentAt(name, "string", args[0])
+	if err != nil {
+		return err
+	}
+	width, err := integerArgument(name, "width", args[1])
+	if err != nil {
+		return err
+	}
+	padding := " "
+	if len(args) == 3 {
+		if padding, err = stringArgumentAt(name, "padding", args[2]); err != nil {
+			return err
+		}
+		if len(padding) == 0 {
+			return NewError(errors.ArgumentToXMustBeYError("padding", name, "a non-empty STRING", ""))
+		}
+	}
+
+	missing := int(width) - utf8.RuneCountInString(str)
+	if missing <= 0 {
+		return &object.String{Value: str}
+	}
+
+	count := missing/utf8.RuneCountInString(padding) + 1
+	size, ok := repeatedSize(len(padding), int64(count))
+	if !ok || size > MAX_STRING_BYTES-int64(len(str)) {
+		return NewError(errors.StringTooLongError(name, MAX_STRING_BYTES))
+	}
+	if err := caller.Allocate(0, int64(len(str))+size); err != nil {
+		return err
+	}
+
+	repeated := []rune(strings.Repeat(padding, count))
+	return &object.String{Value: join(str, string(repeated[:missing]))}
+}
+
+// repeatedSize returns the size of count copies of a string of size bytes,
+// and whether it is at most MAX_STRING_BYTES
+func repeatedSize(size int, count int64) (int64, bool) {
+	if size == 0 || count == 0 {
+		return 0, true
+	}
+	if count > MAX_STRING_BYTES/int64(size) {
+		return 0, false
+	}
+	return int64(size) * count, true
+}
+
+// argumentCount checks that name was given from min to max arguments
+func argumentCount(name string, args []object.Object, min, max int) *object.Error {
+	if len(args) < min {
+		return NewError(errors.RequiresAtLeastXArgumentsError(name, len(args), min))
+	}
+	if len(args) > max {
+		return NewError(errors.RequiresAtMostXArgumentsError(name, len(args), max))
+	}
+	return nil
+}
+
+// twoStrings checks that the first two arguments of name are strings, and
+// returns them
+func twoStrings(name string, args []object.Object, second string) (string, string, *object.Error) {
+	first, err := stringArgumentAt(name, "string", args[0])
+	if err != nil {
+		return "", "", err
+	}
+	other, err := stringArgumentAt(name, second, args[1])
+	if err != nil {
+		return "", "", err
+	}
+	return first, other, nil
+}
+
+func stringArgumentAt(name, arg string, obj object.Object) (string, *object.Error) {
+	str, ok := obj.(*object.String)
+	if !ok {
+		return "", NewError(errors.ArgumentToXMustBeYError(arg, name, object.STRING_OBJ, string(obj.Type())))
+	}
+	return str.Value, nil
+}
+
+func integerArgument(name, arg string, obj object.Object) (int64, *object.Error) {
+	integer, ok := obj.(*object.Integer)
+	if !ok {
+		return 0, NewError(errors.ArgumentToXMustBeYError(arg, name, object.INTEGER_OBJ, string(obj.Type())))
+	}
+	return integer.Value, nil
+}
+
+func stringsToArray(strs []string) *object.Array {
+	elements := make([]object.Object, len(strs))
+	for i, s := range strs {
+		elements[i] = &object.String{Value: s}
+	}
+	return &object.Array{Elements: elements}
+}
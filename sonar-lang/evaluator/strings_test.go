@@ -0,0 +1,86 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+)
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`split("a,b,,c", ",")`, "['a', 'b', '', 'c']"},
+		{`split("héllo", "")`, "['h', 'é', 'l', 'l', 'o']"},
+		{`split("a,b,c", ",", 2)`, "['a', 'b,c']"},
+		{`join(["a", 1, true], "-")`, "a-1-true"},
+		{`join(["a", "b"])`, "ab"},
+		{`join([], ", ")`, ""},
+		{`trim("  a b \n")`, "a b"},
+		{`trim("xxaxx", "x")`, "a"},
+		{`trimLeft("  a  ")`, "a  "},
+		{`trimRight("  a  ")`, "  a"},
+		{`trimLeft("-+a-", "+-")`, "a-"},
+		{`upper("héllo")`, "HÉLLO"},
+		{`lower("HÉLLO")`, "héllo"},
+		{`replace("aaa", "a", "b")`, "bbb"},
+		{`replace("aaa", "a", "b", 2)`, "bba"},
+		{`startsWith("sonar", "so")`, "true"},
+		{`startsWith("sonar", "on")`, "false"},
+		{`endsWith("sonar", "ar")`, "true"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", 0)`, ""},
+		{`padLeft("7", 3)`, "  7"},
+		{`padLeft("7", 3, "0")`, "007"},
+		{`padRight("é", 4, "ab")`, "éaba"},
+		{`padLeft("long", 2)`, "long"},
+		{`lines("a\nb\r\nc\n")`, "['a', 'b', 'c']"},
+		{`lines("")`, "[]"},
+		{`fields("  a \t b\nc ")`, "['a', 'b', 'c']"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if isError(evaluated) {
+			t.Errorf("%s: unexpected error %+v", tt.input, evaluated.(*object.Error).Conf)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStringBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`split("a")`, "'split' requires at least 2 arguments, 1 given"},
+		{`split(1, ",")`, "'string' argument to 'split' must be STRING, 'INTEGER' given"},
+		{`join("a")`, "'array' argument to 'join' must be ARRAY, 'STRING' given"},
+		{`trim("a", "b", "c")`, "'trim' requires at most 2 arguments, 3 given"},
+		{`upper()`, "Function 'upper' requires 1 argument, 0 given"},
+		{`replace("a", "a", "b", "all")`, "'count' argument to 'replace' must be INTEGER, 'STRING' given"},
+		{`startsWith("a")`, "Function 'startsWith' requires 2 arguments, 1 given"},
+		{`repeat("a", -1)`, "'count' argument to 'repeat' must be a non-negative INTEGER, '-1' given"},
+		{`padLeft("a", 3, "")`, "'padding' argument to 'padLeft' must be a non-empty STRING, '' given"},
+		{`repeat("ab", 4611686018427387904)`, "String made by 'repeat' would be longer than 1073741824 bytes"},
+		{`repeat("a", 1073741825)`, "String made by 'repeat' would be longer than 1073741824 bytes"},
+		{`padLeft("a", MAX_INT, "é")`, "String made by 'padLeft' would be longer than 1073741824 bytes"},
+		{`padRight("a", 4611686018427387904)`, "String made by 'padRight' would be longer than 1073741824 bytes"},
+	}
+
+	for _, tt := range tests {
+		err, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if msg := err.Conf.(errors.Error).Message; msg != tt.expected {
+			t.Errorf("%s: expected %q, got=%q", tt.input, tt.expected, msg)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package evaluator
 import (
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/icheka/sonar-lang/sonar-lang/errors"
 	"github.com/icheka/sonar-lang/sonar-lang/object"
@@ -10,7 +11,7 @@ import (
 
 var TypesBuiltins = map[string]*object.Builtin{
 	"convertable": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return NewError(errors.RequiresXArgumentsError(2, len(args), "convertable"))
 			}
@@ -42,7 +43,7 @@ var TypesBuiltins = map[string]*object.Builtin{
 		},
 	},
 	"str": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return NewError(errors.RequiresXArgumentsError(1, len(args), "str"))
 			}
@@ -57,8 +58,53 @@ var TypesBuiltins = map[string]*object.Builtin{
 			}
 		},
 	},
+	"bytes": {
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			/*
+				bytes(str)
+				-- returns the UTF-8 bytes of str, as integers from 0 to 255
+			*/
+			str, err := stringArgument("bytes", args)
+			if err != nil {
+				return err
+			}
+
+			if err := caller.Allocate(int64(len(str.Value)), 0); err != nil {
+				return err
+			}
+
+			elements := make([]object.Object, len(str.Value))
+			for i := 0; i < len(str.Value); i++ {
+				elements[i] = &object.Integer{Value: int64(str.Value[i])}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"runes": {
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			/*
+				runes(str)
+				-- returns the Unicode code points of the characters of str
+			*/
+			str, err := stringArgument("runes", args)
+			if err != nil {
+				return err
+			}
+
+			n := utf8.RuneCountInString(str.Value)
+			if err := caller.Allocate(int64(n), 0); err != nil {
+				return err
+			}
+
+			elements := make([]object.Object, 0, n)
+			for _, r := range str.Value {
+				elements = append(elements, &object.Integer{Value: int64(r)})
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
 	"int": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return NewError(errors.RequiresXArgumentsError(1, len(args), "int"))
 			}
@@ -67,7 +113,7 @@ var TypesBuiltins = map[string]*object.Builtin{
 		},
 	},
 	"float": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return NewError(errors.RequiresXArgumentsError(1, len(args), "float"))
 			}
@@ -76,7 +122,11 @@ var TypesBuiltins = map[string]*object.Builtin{
 		},
 	},
 	"map": {
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			// map(arr, fn) maps the elements of arr; map(arr) converts it to a map
+			if len(args) == 2 {
+				return mapArray(caller, args...)
+			}
 			if len(args) != 1 {
 				return NewError(errors.RequiresXArgumentsError(1, len(args), "map"))
 			}
@@ -84,16 +134,17 @@ var TypesBuiltins = map[string]*object.Builtin{
 			switch args[0].Type() {
 			case object.ARRAY_OBJ:
 				elements := args[0].(*object.Array).Elements
-				pairs := map[object.HashKey]object.HashPair{}
+				if err := caller.Allocate(int64(len(elements)), 0); err != nil {
+					return err
+				}
+				hash := object.NewHash()
 
 				for i, v := range elements {
-					pairs[object.HashKey{Type: object.INTEGER_OBJ, Value: uint64(i)}] = object.HashPair{
-						Key:   &object.Integer{Value: int64(i)},
-						Value: v,
-					}
+					key := &object.Integer{Value: int64(i)}
+					hash.Set(key.HashKey(), object.HashPair{Key: key, Value: v})
 				}
 
-				return &object.Hash{Pairs: pairs}
+				return hash
 			default:
 				return NewError(errors.ArgumentToXMustBeYError("array", "map", object.ARRAY_OBJ, string(args[0].Type())))
 			}
@@ -101,6 +152,19 @@ var TypesBuiltins = map[string]*object.Builtin{
 	},
 }
 
+// stringArgument checks that a builtin taking one string was given one
+func stringArgument(name string, args []object.Object) (*object.String, *object.Error) {
+	if len(args) != 1 {
+		return nil, NewError(errors.RequiresXArgumentsError(1, len(args), name))
+	}
+
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return nil, NewError(errors.ArgumentToXMustBeYError("string", name, object.STRING_OBJ, string(args[0].Type())))
+	}
+	return str, nil
+}
+
 func toFloat(from object.Object) object.Object {
 	switch from.Type() {
 	case object.STRING_OBJ:
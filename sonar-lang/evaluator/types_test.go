@@ -195,3 +195,28 @@ func getValueOfType(tp object.ObjectType) (object.Object, string) {
 	}
 	return val, val.Inspect()
 }
+
+func TestBytesAndRunesBuiltins(t *testing.T) {
+	testEvalType[*object.Array](t, `bytes("hé")`, `[104, 195, 169]`)
+	testEvalType[*object.Array](t, `runes("hé😀")`, `[104, 233, 128512]`)
+	testEvalType[*object.Array](t, `bytes("")`, `[]`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`bytes(1)`, "'string' argument to 'bytes' must be STRING, 'INTEGER' given"},
+		{`runes("a", "b")`, "Function 'runes' requires 1 argument, 2 given"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if errObj.Conf.(errors.Error).Message != tt.expected {
+			t.Errorf("%s: expected %q, got=%q", tt.input, tt.expected, errObj.Conf.(errors.Error).Message)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/icheka/sonar-lang/sonar-lang/diagnostics"
+	"github.com/icheka/sonar-lang/sonar-lang/evaluator"
+	"github.com/icheka/sonar-lang/sonar-lang/format"
+)
+
+// fmt prints the programs in the given files and directories laid out
+// canonically, or the program read from stdin if there are none. With
+// --write it rewrites the files instead, and with --check it only lists the
+// ones that are not formatted, failing if there are any.
+func (c *cli) fmt(args []string) int {
+	flags := c.flagSet("fmt")
+	check := flags.Bool("check", false, "list the files that are not formatted, instead of printing them")
+	write := flags.Bool("write", false, "rewrite the files that are not formatted, instead of printing them")
+	if status, ok := parseFlags(flags, args); !ok {
+		return status
+	}
+	if *check && *write {
+		fmt.Fprintln(c.stderr, "--check and --write cannot be used together")
+		return EXIT_USAGE
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 || (len(paths) == 1 && paths[0] == STDIN) {
+		if *write {
+			fmt.Fprintln(c.stderr, "--write needs files to rewrite")
+			return EXIT_USAGE
+		}
+		return c.fmtFile(STDIN, *check, false)
+	}
+
+	files, err := findFiles(paths, evaluator.MODULE_EXTENSION)
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return EXIT_USAGE
+	}
+	status := EXIT_OK
+	for _, file := range files {
+		if s := c.fmtFile(file, *check, *write); s > status {
+			status = s
+		}
+	}
+	return status
+}
+
+// fmtFile formats file as the flags of fmt say to, and returns the status
+// to exit with
+func (c *cli) fmtFile(file string, check, write bool) int {
+	src, options, err := c.readSource(file)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Cannot read %s: %s\n", file, err)
+		return EXIT_USAGE
+	}
+	formatted, errs := format.Source(src, options)
+	if len(errs) != 0 {
+		diagnostics.New(c.stderr).Errors(errs)
+		return EXIT_ERROR
+	}
+
+	switch {
+	case check:
+		if formatted == src {
+			return EXIT_OK
+		}
+		if file == STDIN {
+			file = diagnostics.INPUT
+		}
+		fmt.Fprintln(c.stdout, file)
+		return EXIT_ERROR
+	case write:
+		if formatted == src {
+			return EXIT_OK
+		}
+		info, err := os.Stat(file)
+		if err == nil {
+			err = os.WriteFile(file, []byte(formatted), info.Mode().Perm())
+		}
+		if err != nil {
+			fmt.Fprintf(c.stderr, "Cannot write %s: %s\n", file, err)
+			return EXIT_ERROR
+		}
+		return EXIT_OK
+	}
+	fmt.Fprint(c.stdout, formatted)
+	return EXIT_OK
+}
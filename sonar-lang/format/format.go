@@ -0,0 +1,534 @@
+// Package format prints Sonar programs in one canonical layout, so that
+// how a program is laid out is never a matter of taste:
+//
+//	let greet = func(name) {
+//		// strings are kept as written, escapes and all
+//		return "Hello, ${name}!"
+//	}
+//
+// Statements go on lines of their own, blocks are indented with tabs and
+// expressions get only the parentheses they need. Comments stay with the
+// statement they were written next to, or the expression if they are
+// /* */ comments written right next to it, and a single blank line is kept
+// wherever the source had one or more.
+package format
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/icheka/sonar-lang/sonar-lang/ast"
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/lexer"
+	"github.com/icheka/sonar-lang/sonar-lang/parser"
+	"github.com/icheka/sonar-lang/sonar-lang/token"
+)
+
+// Source returns the program src laid out canonically. A program with
+// syntax errors is not formatted; its errors are returned instead.
+func Source(src string, options *lexer.LexerOptions) (string, []errors.Error) {
+	l := lexer.New(src, options)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return "", p.Errors()
+	}
+
+	pr := &printer{src: src, comments: l.Comments()}
+	pr.printed = make([]bool, len(pr.comments))
+	return pr.statements(program.Statements, 0, 0, len(src)), nil
+}
+
+// printer renders nodes as source text. Every comment is printed exactly
+// once: blocks and multi-line literals take the comments written inside
+// them, and statements take the rest.
+type printer struct {
+	src      string
+	comments []token.Comment
+	printed  []bool
+}
+
+// atom is the precedence of expressions that never need parentheses
+const atom = parser.INDEX + 1
+
+func indent(depth int) string {
+	return strings.Repeat("\t", depth)
+}
+
+// take returns the comments not yet printed that start between the offsets
+// from and to and satisfy keep, marking them as printed
+func (p *printer) take(from, to int, keep func(token.Comment) bool) []token.Comment {
+	taken := []token.Comment{}
+	for i, c := range p.comments {
+		if p.printed[i] || c.Span.Start.Offset < from || c.Span.Start.Offset >= to {
+			continue
+		}
+		if keep == nil || keep(c) {
+			p.printed[i] = true
+			taken = append(taken, c)
+		}
+	}
+	return taken
+}
+
+// statements prints stmts, the contents of the source between the offsets
+// from and to, one per line at depth
+func (p *printer) statements(stmts []ast.Statement, depth, from, to int) string {
+	type line struct {
+		comments   []token.Comment // printed on lines of their own before stmt
+		stmt       ast.Statement
+		text       string
+		trailing   []token.Comment // printed after stmt, on its last line
+		start, end token.Position
+	}
+
+	lines := []line{}
+	for i := 0; i < len(stmts); i++ {
+		span := stmts[i].Span()
+		l := line{stmt: stmts[i], start: span.Start, end: span.End}
+		l.comments = p.take(from, l.start.Offset, nil)
+		l.text = p.statement(l.stmt, depth)
+
+		// x++ is parsed as x followed by a statement that increments it
+		if i+1 < len(stmts) {
+			if postfix, ok := postfixOf(stmts[i+1], l.end); ok {
+				l.text += postfix.Operator
+				l.end = postfix.Span().End
+				i++
+			}
+		}
+
+		// comments inside an expression that spans lines move above it
+		l.comments = append(l.comments, p.take(l.start.Offset, l.end.Offset, func(c token.Comment) bool {
+			return c.Span.Start.Line < l.end.Line
+		})...)
+
+		next := to
+		if i+1 < len(stmts) {
+			next = stmts[i+1].Span().Start.Offset
+		}
+		l.trailing = p.take(l.start.Offset, next, func(c token.Comment) bool {
+			return c.Span.Start.Line == l.end.Line
+		})
+		lines = append(lines, l)
+	}
+
+	var out strings.Builder
+	last := 0 // the source line the previous line ended on
+	write := func(text string, start, end int) {
+		if last != 0 && start-last > 1 {
+			out.WriteString("\n")
+		}
+		out.WriteString(indent(depth) + text + "\n")
+		last = end
+	}
+
+	for i, l := range lines {
+		for _, c := range l.comments {
+			write(c.Text, c.Span.Start.Line, c.Span.End.Line)
+		}
+
+		text, end := l.text, l.end.Line
+		if i+1 < len(lines) && needsSemicolon(l.stmt, lines[i+1].text) {
+			text += ";"
+		}
+		for _, c := range l.trailing {
+			text += " " + c.Text
+			end = c.Span.End.Line
+		}
+		write(text, l.start.Line, end)
+	}
+
+	for _, c := range p.take(from, to, nil) {
+		write(c.Text, c.Span.Start.Line, c.Span.End.Line)
+	}
+	return out.String()
+}
+
+// postfixOf returns the postfix expression stmt is if it applies to the
+// expression that ends at end
+func postfixOf(stmt ast.Statement, end token.Position) (*ast.PostfixExpression, bool) {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return nil, false
+	}
+	postfix, ok := es.Expression.(*ast.PostfixExpression)
+	if !ok || postfix.Token.Span.End.Offset != end.Offset {
+		return nil, false
+	}
+	return postfix, true
+}
+
+// needsSemicolon reports whether stmt must end with a semicolon so that the
+// parser does not read next as the rest of it
+func needsSemicolon(stmt ast.Statement, next string) bool {
+	switch stmt := stmt.(type) {
+	case *ast.ReturnStatement:
+		if stmt.ReturnValue == nil {
+			return true
+		}
+	case *ast.LetStatement, *ast.ExportStatement, *ast.ExpressionStatement, *ast.ThrowStatement:
+	default:
+		return false
+	}
+	return strings.HasPrefix(next, "(") || strings.HasPrefix(next, "[") || strings.HasPrefix(next, "-")
+}
+
+func (p *printer) statement(stmt ast.Statement, depth int) string {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token.Literal + " " + stmt.Name.Value + " = " + p.expression(stmt.Value, depth)
+	case *ast.ExportStatement:
+		return stmt.Token.Literal + " " + p.statement(stmt.Statement, depth)
+	case *ast.ReturnStatement:
+		if stmt.ReturnValue == nil {
+			return stmt.Token.Literal
+		}
+		return stmt.Token.Literal + " " + p.expression(stmt.ReturnValue, depth)
+	case *ast.ThrowStatement:
+		return stmt.Token.Literal + " " + p.expression(stmt.Value, depth)
+	case *ast.ExpressionStatement:
+		return p.expression(stmt.Expression, depth)
+	case *ast.WhileStatement:
+		return fmt.Sprintf("%s (%s) %s", stmt.Token.Literal, p.expression(stmt.Condition, depth), p.block(stmt.Consequence, depth))
+	case *ast.ForStatement:
+		return fmt.Sprintf("%s (%s, %s in %s) %s", stmt.Token.Literal, stmt.Counter, stmt.Value,
+			p.expression(stmt.Iterable, depth), p.block(stmt.Consequence, depth))
+	case *ast.TryStatement:
+		out := stmt.Token.Literal + " " + p.block(stmt.Block, depth)
+		if stmt.Catch != nil {
+			out += " catch "
+			if stmt.Parameter != nil {
+				out += "(" + stmt.Parameter.Value + ") "
+			}
+			out += p.block(stmt.Catch, depth)
+		}
+		if stmt.Finally != nil {
+			out += " finally " + p.block(stmt.Finally, depth)
+		}
+		return out
+	case *ast.ImportStatement:
+		out := stmt.Token.Literal + " "
+		if stmt.Names != nil {
+			out += "{" + identifiers(stmt.Names, " ") + "} from "
+		}
+		out += p.source(stmt.Path.Span())
+		if stmt.Alias != nil {
+			out += " as " + stmt.Alias.Value
+		}
+		return out
+	}
+	return stmt.String()
+}
+
+// block prints b with its statements at depth+1, or as {} if it is empty
+func (p *printer) block(b *ast.BlockStatement, depth int) string {
+	span := b.Span()
+	body := p.statements(b.Statements, depth+1, span.Start.Offset, span.End.Offset-1)
+	if body == "" {
+		return "{}"
+	}
+	return "{\n" + body + indent(depth) + "}"
+}
+
+// expression prints exp with the inline comments written right next to it
+func (p *printer) expression(exp ast.Expression, depth int) string {
+	span := exp.Span()
+	out := p.inlineComments(p.before(span.Start), "", " ")
+	out += p.node(exp, depth)
+	return out + p.inlineComments(p.after(span.End), " ", "")
+}
+
+func (p *printer) node(exp ast.Expression, depth int) string {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		return exp.Value
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.Boolean:
+		return exp.TokenLiteral()
+	case *ast.NullValueExpression:
+		return "null"
+	case *ast.StringLiteral:
+		return p.source(exp.Span())
+	case *ast.ConcatExpression:
+		var out strings.Builder
+		out.WriteString(`"`)
+		for _, part := range exp.Parts {
+			if s, ok := part.(*ast.StringLiteral); ok && isTemplateText(s.Token.Type) {
+				out.WriteString(templateText(p.source(s.Span()), s.Token.Type))
+			} else {
+				out.WriteString("${" + p.expression(part, depth) + "}")
+			}
+		}
+		out.WriteString(`"`)
+		return out.String()
+	case *ast.PrefixExpression:
+		right := p.operand(exp.Right, parser.PREFIX, depth)
+		// - -x, as --x would be a decrement
+		if exp.Operator == "-" && strings.HasPrefix(right, "-") {
+			right = "(" + right + ")"
+		}
+		return exp.Operator + right
+	case *ast.InfixExpression:
+		return p.binary(exp.Left, exp.Token.Type, exp.Operator, exp.Right, depth)
+	case *ast.LogicalExpression:
+		return p.binary(exp.Left, exp.Token.Type, exp.Operator, exp.Right, depth)
+	case *ast.PostfixExpression:
+		return exp.Token.Literal + exp.Operator
+	case *ast.AssignmentExpression:
+		return exp.Identifier.Value + " " + exp.Operator + " " + p.expression(exp.Value, depth)
+	case *ast.SquareBracketAssignment:
+		return p.index(exp.Left, exp.Token.Type, false, exp.Key, depth) + " = " + p.expression(exp.Value, depth)
+	case *ast.IndexExpression:
+		return p.index(exp.Left, exp.Token.Type, exp.Optional, exp.Index, depth)
+	case *ast.CallExpression:
+		out := p.chainOperand(exp.Function, depth)
+		if exp.Optional {
+			out += "?."
+		}
+		args := []string{}
+		for _, arg := range exp.Arguments {
+			args = append(args, p.expression(arg, depth))
+		}
+		return out + "(" + strings.Join(args, ", ") + ")"
+	case *ast.FunctionLiteral:
+		return exp.Token.Literal + "(" + identifiers(exp.Parameters, "") + ") " + p.block(exp.Body, depth)
+	case *ast.IfExpression:
+		out := fmt.Sprintf("%s (%s) %s", exp.Token.Literal, p.expression(exp.Condition, depth), p.block(exp.Consequence, depth))
+		if exp.Alternative != nil {
+			out += " else " + p.block(exp.Alternative, depth)
+		}
+		return out
+	case *ast.ArrayLiteral:
+		spans := []token.Span{}
+		for _, el := range exp.Elements {
+			spans = append(spans, el.Span())
+		}
+		return p.list("[", "]", exp.Span(), spans, func(i, depth int) string {
+			return p.expression(exp.Elements[i], depth)
+		}, depth)
+	case *ast.HashLiteral:
+		spans := []token.Span{}
+		for _, pair := range exp.Pairs {
+			spans = append(spans, pair.Key.Span().To(pair.Value.Span()))
+		}
+		return p.list("{", "}", exp.Span(), spans, func(i, depth int) string {
+			pair := exp.Pairs[i]
+			return p.expression(pair.Key, depth) + ": " + p.expression(pair.Value, depth)
+		}, depth)
+	}
+	return exp.String()
+}
+
+// binary prints an infix or logical expression. Both bind to the left, so
+// the right operand needs parentheses at the operator's own precedence.
+func (p *printer) binary(left ast.Expression, op token.TokenType, operator string, right ast.Expression, depth int) string {
+	precedence := parser.Precedence(op)
+	return p.operand(left, precedence, depth) + " " + operator + " " + p.operand(right, precedence+1, depth)
+}
+
+// index prints left[index], left?.[index], left.name or left?.name
+func (p *printer) index(left ast.Expression, op token.TokenType, optional bool, index ast.Expression, depth int) string {
+	out := p.chainOperand(left, depth)
+	switch op {
+	case token.FULLSTOP:
+		return out + "." + index.(*ast.StringLiteral).Value
+	case token.OPTIONAL_CHAIN:
+		return out + "?." + index.(*ast.StringLiteral).Value
+	}
+	if optional {
+		out += "?."
+	}
+	return out + "[" + p.expression(index, depth) + "]"
+}
+
+// chainOperand prints the left of an index expression or call, keeping the
+// parentheses that end an optional chain in it
+func (p *printer) chainOperand(exp ast.Expression, depth int) string {
+	if ast.Grouped(exp) && optionalChain(exp) {
+		return "(" + p.expression(exp, depth) + ")"
+	}
+	return p.operand(exp, parser.CALL, depth)
+}
+
+// optionalChain reports whether exp is a chain with an optional link, up to
+// any parentheses within it
+func optionalChain(exp ast.Expression) bool {
+	for {
+		switch link := exp.(type) {
+		case *ast.IndexExpression:
+			if link.Optional {
+				return true
+			}
+			exp = link.Left
+		case *ast.CallExpression:
+			if link.Optional {
+				return true
+			}
+			exp = link.Function
+		default:
+			return false
+		}
+		if ast.Grouped(exp) {
+			return false
+		}
+	}
+}
+
+// operand prints exp in parentheses if it binds less tightly than min
+func (p *printer) operand(exp ast.Expression, min, depth int) string {
+	out := p.expression(exp, depth)
+	if precedence(exp) < min {
+		return "(" + out + ")"
+	}
+	return out
+}
+
+// precedence returns how tightly exp holds together when it is the operand
+// of another expression
+func precedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(exp.Token.Type)
+	case *ast.LogicalExpression:
+		return parser.Precedence(exp.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression, *ast.IndexExpression:
+		return parser.CALL
+	case *ast.AssignmentExpression, *ast.SquareBracketAssignment:
+		// the value of an assignment extends as far right as it can
+		return parser.LOWEST
+	}
+	return atom
+}
+
+// list prints the elements of an array or hash literal, which span the
+// source, between open and close. If the first element was on a line of its
+// own in the source, each goes on a line of its own, with the comments
+// written between them.
+func (p *printer) list(open, close string, span token.Span, spans []token.Span, element func(i, depth int) string, depth int) string {
+	if len(spans) == 0 || spans[0].Start.Line == span.Start.Line {
+		elements := []string{}
+		for i := range spans {
+			elements = append(elements, element(i, depth))
+		}
+		return open + strings.Join(elements, ", ") + close
+	}
+
+	var out strings.Builder
+	out.WriteString(open + "\n")
+	from, to := span.Start.Offset, span.End.Offset-1
+	for i, s := range spans {
+		text := element(i, depth+1)
+		for _, c := range p.take(from, s.End.Offset, func(c token.Comment) bool {
+			return c.Span.Start.Line < s.End.Line
+		}) {
+			out.WriteString(indent(depth+1) + c.Text + "\n")
+		}
+
+		out.WriteString(indent(depth+1) + text)
+		next := to
+		if i+1 < len(spans) {
+			out.WriteString(",")
+			next = spans[i+1].Start.Offset
+		}
+		for _, c := range p.take(from, next, func(c token.Comment) bool {
+			return c.Span.Start.Line == s.End.Line
+		}) {
+			out.WriteString(" " + c.Text)
+		}
+		out.WriteString("\n")
+		from = s.End.Offset
+	}
+	for _, c := range p.take(from, to, nil) {
+		out.WriteString(indent(depth+1) + c.Text + "\n")
+	}
+	out.WriteString(indent(depth) + close)
+	return out.String()
+}
+
+// inlineComments prints comments, each between prefix and suffix
+func (p *printer) inlineComments(comments []token.Comment, prefix, suffix string) string {
+	out := ""
+	for _, c := range comments {
+		out += prefix + c.Text + suffix
+	}
+	return out
+}
+
+// before takes the inline comments that end on the line of pos with only
+// spaces between them and pos, in source order
+func (p *printer) before(pos token.Position) []token.Comment {
+	taken := []token.Comment{}
+	for i := len(p.comments) - 1; i >= 0; i-- {
+		c := p.comments[i]
+		if c.Span.End.Offset > pos.Offset {
+			continue
+		}
+		if p.printed[i] || !p.inline(c, pos.Line) || !blank(p.src[c.Span.End.Offset:pos.Offset]) {
+			break
+		}
+		p.printed[i] = true
+		taken = append([]token.Comment{c}, taken...)
+		pos = c.Span.Start
+	}
+	return taken
+}
+
+// after takes the inline comments that start on the line of pos with only
+// spaces between pos and them, in source order
+func (p *printer) after(pos token.Position) []token.Comment {
+	taken := []token.Comment{}
+	for i, c := range p.comments {
+		if c.Span.Start.Offset < pos.Offset {
+			continue
+		}
+		if p.printed[i] || !p.inline(c, pos.Line) || !blank(p.src[pos.Offset:c.Span.Start.Offset]) {
+			break
+		}
+		p.printed[i] = true
+		taken = append(taken, c)
+		pos = c.Span.End
+	}
+	return taken
+}
+
+// inline reports whether c is a /* */ comment written on line
+func (p *printer) inline(c token.Comment, line int) bool {
+	return strings.HasPrefix(c.Text, "/*") && c.Span.Start.Line == line && c.Span.End.Line == line
+}
+
+func blank(s string) bool {
+	return strings.Trim(s, " \t") == ""
+}
+
+// source returns the text of span as it was written
+func (p *printer) source(span token.Span) string {
+	return p.src[span.Start.Offset:span.End.Offset]
+}
+
+func identifiers(idents []*ast.Identifier, padding string) string {
+	if len(idents) == 0 {
+		return ""
+	}
+	names := []string{}
+	for _, ident := range idents {
+		names = append(names, ident.Value)
+	}
+	return padding + strings.Join(names, ", ") + padding
+}
+
+func isTemplateText(t token.TokenType) bool {
+	return t == token.TEMPLATE_HEAD || t == token.TEMPLATE_MIDDLE || t == token.TEMPLATE_TAIL
+}
+
+// templateText returns the text of a part of a template, the source of
+// which is given, as it was written: without the quote or brace before it
+// and the "${" or quote after it
+func templateText(src string, t token.TokenType) string {
+	src = src[1:]
+	if t == token.TEMPLATE_TAIL {
+		return src[:len(src)-1]
+	}
+	return src[:len(src)-2]
+}
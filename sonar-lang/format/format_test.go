@@ -0,0 +1,146 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/icheka/sonar-lang/sonar-lang/lexer"
+	"github.com/icheka/sonar-lang/sonar-lang/parser"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// layout
+		{"let a=1+2*3;let b = (1+2)*3", "let a = 1 + 2 * 3\nlet b = (1 + 2) * 3\n"},
+		{"let f = func(x,y){ if(x>y){return x}else{return y}; }",
+			"let f = func(x, y) {\n\tif (x > y) {\n\t\treturn x\n\t} else {\n\t\treturn y\n\t}\n}\n"},
+		{"while (a < 10) { a += 1 }", "while (a < 10) {\n\ta += 1\n}\n"},
+		{"for (i, v in [1, 2]) { print(v) }", "for (i, v in [1, 2]) {\n\tprint(v)\n}\n"},
+		{"try { throw \"x\" } catch (e) { print(e) } finally {}",
+			"try {\n\tthrow \"x\"\n} catch (e) {\n\tprint(e)\n} finally {}\n"},
+		{"try { 1 } catch { 2 }", "try {\n\t1\n} catch {\n\t2\n}\n"},
+		{`import {add,sub} from "./math"` + "\n" + `import "./x.sonar" as x`,
+			"import { add, sub } from \"./math\"\nimport \"./x.sonar\" as x\n"},
+		{"export const PI = 3.14", "export const PI = 3.14\n"},
+		{"let f = func() {}", "let f = func() {}\n"},
+		{"let a = 1\n\n\n\nlet b = 2\n", "let a = 1\n\nlet b = 2\n"},
+		{"\n\nlet a = 1\n\n", "let a = 1\n"},
+		{"let h = {\n\"a\": 1, \"b\": [1,\n2]}", "let h = {\n\t\"a\": 1,\n\t\"b\": [1, 2]\n}\n"},
+		{"let a = [1, func() { 2 }]", "let a = [1, func() {\n\t2\n}]\n"},
+		{"", ""},
+
+		// parentheses
+		{"a - (b - c) - d", "a - (b - c) - d\n"},
+		{"(a - b) - c", "a - b - c\n"},
+		{"(a or b) and c", "a or b and c\n"},
+		{"a and (b or c)", "a and (b or c)\n"},
+		{"-(a + b) * -c.d", "-(a + b) * -c.d\n"},
+		{"(-a).b", "(-a).b\n"},
+		{"- -a", "-(-a)\n"},
+		{"!(!a)", "!!a\n"},
+		{"(a = 1) + 2", "(a = 1) + 2\n"},
+		{"f((a + b), (c))", "f(a + b, c)\n"},
+
+		// members, calls and assignments
+		{"obj.x = 3; obj?.y?.[0]; f?.(1); a[0] = b += 2", "obj.x = 3\nobj?.y?.[0]\nf?.(1)\na[0] = b += 2\n"},
+		{"(a?.b).c; (a?.b)(1); (a.b).c; ((a?.b).c).d", "(a?.b).c;\n(a?.b)(1)\na.b.c;\n(a?.b).c.d\n"},
+		{"x++\nlet y = x --", "x++\nlet y = x--\n"},
+
+		// semicolons that keep statements apart
+		{"print(a);\n(a + b).c", "print(a);\n(a + b).c\n"},
+		{"let x = 1;\n[x][0]", "let x = 1;\n[x][0]\n"},
+		{"x;\n-1", "x;\n-1\n"},
+		{"let f = func() { return; print(1) }", "let f = func() {\n\treturn;\n\tprint(1)\n}\n"},
+		{"let f = func() { return; }", "let f = func() {\n\treturn\n}\n"},
+
+		// strings
+		{`"a\nb \u{e9} \$"`, "\"a\\nb \\u{e9} \\$\"\n"},
+		{`"hi ${name}, \${no} \$ {} ${"${a}"}"`, "\"hi ${name}, \\${no} \\$ {} ${\"${a}\"}\"\n"},
+		{`"${"\n" + "\""}"`, "\"${\"\\n\" + \"\\\"\"}\"\n"},
+
+		// comments
+		{"// header\nlet a = 1 // one\n/* two */ let b = 2",
+			"// header\nlet a = 1 // one\n/* two */\nlet b = 2\n"},
+		{"#!/usr/bin/env sonar-lang\nprint(1)", "#!/usr/bin/env sonar-lang\nprint(1)\n"},
+		{"let f = func() {\n  // inside\n  1 /* after */\n  // last\n}\n// end",
+			"let f = func() {\n\t// inside\n\t1 /* after */\n\t// last\n}\n// end\n"},
+		{"if (a) {\n// nothing yet\n}", "if (a) {\n\t// nothing yet\n}\n"},
+		{"let a = 1\n\n// b\n\nlet b = 2", "let a = 1\n\n// b\n\nlet b = 2\n"},
+		{"let h = {\n  // first\n  \"a\": 1, // one\n  \"b\": 2\n  // end\n}",
+			"let h = {\n\t// first\n\t\"a\": 1, // one\n\t\"b\": 2\n\t// end\n}\n"},
+		{"f(a,\n  // why\n  b)", "// why\nf(a, b)\n"},
+		{"f(a, /* b */ b)", "f(a, /* b */ b)\n"},
+		{"let x =   /* one */  1  /* two */+2", "let x = /* one */ 1 /* two */ + 2\n"},
+	}
+
+	for _, tt := range tests {
+		actual, errs := Source(tt.input, nil)
+		if len(errs) != 0 {
+			t.Errorf("%q: unexpected errors %+v", tt.input, errs)
+			continue
+		}
+		if actual != tt.expected {
+			t.Errorf("%q: wrong output.\nexpected=%q\ngot=     %q", tt.input, tt.expected, actual)
+			continue
+		}
+		testFormatted(t, tt.input, actual)
+	}
+}
+
+// testFormatted checks that formatted parses to the same program as input
+// and is left as it is by formatting it again
+func testFormatted(t *testing.T, input, formatted string) {
+	t.Helper()
+
+	before := parser.New(lexer.New(input, nil)).ParseProgram().String()
+	p := parser.New(lexer.New(formatted, nil))
+	after := p.ParseProgram().String()
+	if len(p.Errors()) != 0 || after != before {
+		t.Errorf("%q: formatted program differs. expected=%q, got=%q (errors %+v)", input, before, after, p.Errors())
+	}
+
+	again, _ := Source(formatted, nil)
+	if again != formatted {
+		t.Errorf("%q: formatting is not idempotent. first=%q, second=%q", input, formatted, again)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	// formatted source is left exactly as it is
+	tests := []string{
+		"f(a, /* b */ b)\n",
+		"f(/* first */ a, b /* last */)\n",
+		"let x = /* why */ [1, 2 /* two */, 3]\n",
+		"let h = {\"a\": /* one */ 1}\n",
+		"if (/* always */ true) {\n\ta /* and */ + b\n}\n",
+		"print(\"\\u{e9} \\t \\$ \\\\\")\n",
+		"print(\"${a} \\u{e9}\\u{1F600} \\t ${b}\\$ {} \\\" \\0\")\n",
+		"print(\"\\u{7f}${\"\\u{e9}\"}\")\n",
+	}
+
+	for _, input := range tests {
+		actual, errs := Source(input, nil)
+		if len(errs) != 0 {
+			t.Errorf("%q: unexpected errors %+v", input, errs)
+			continue
+		}
+		if actual != input {
+			t.Errorf("%q: changed by formatting. got=%q", input, actual)
+			continue
+		}
+		testFormatted(t, input, actual)
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	tests := []string{"let = 1", `"abc`, "let a = (1"}
+
+	for _, input := range tests {
+		actual, errs := Source(input, nil)
+		if len(errs) == 0 || actual != "" {
+			t.Errorf("%q: expected syntax errors and no output, got=%q, %+v", input, actual, errs)
+		}
+	}
+}
@@ -10,10 +10,19 @@ type FileInput struct {
 }
 
 func (f *FileInput) Read() string {
-	file, err := ioutil.ReadFile(f.Path)
+	file, err := f.Load()
 	if err == nil {
-		return string(file)
+		return file
 	}
 
 	panic(fmt.Sprintf("%s is not a valid file", f.Path))
 }
+
+// Load is like Read, but returns an error if the file cannot be read
+func (f *FileInput) Load() (string, error) {
+	file, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return "", err
+	}
+	return string(file), nil
+}
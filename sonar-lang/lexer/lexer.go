@@ -1,8 +1,12 @@
 package lexer
 
 import (
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
 	"github.com/icheka/sonar-lang/sonar-lang/token"
 )
 
@@ -12,10 +16,18 @@ type Lexer struct {
 	readPosition int      // current reading position in input (after current char)
 	ch           byte     // current char under examination
 	File         string   // path to current file, relative to $PWD
-	Line         int      // current line being scanned
-	Column       int      // number of characters scanned in Line
+	Line         int      // line of the current char
+	Column       int      // column of the current char, in bytes
 	LineSpan     []string // a slice of format ([index of start of line, index of end of line])
 	InputLength  int
+
+	// the number of braces opened and not yet closed in each interpolation
+	// the current token is inside, innermost last
+	templates []int
+	errors    []errors.Error
+	comments  []token.Comment
+	// whether the input ended inside a string
+	unterminated bool
 }
 type LexerOptions struct {
 	Path string
@@ -25,6 +37,11 @@ func New(input string, options *LexerOptions) *Lexer {
 	l := &Lexer{input: input, Line: 1, Column: 0, InputLength: len(input)}
 	l.readChar()
 
+	// a "#!" line lets scripts be run directly on Unix-like systems
+	if strings.HasPrefix(input, "#!") {
+		l.skipSingleLineComment()
+	}
+
 	if options != nil {
 		if len(options.Path) > 0 {
 			l.File = options.Path
@@ -38,6 +55,23 @@ func (l *Lexer) LineText(start, end int) string {
 	return l.input[start:end]
 }
 
+// Errors returns the errors found in string literals. The lexer still returns
+// a token for each string, so the parser can carry on.
+func (l *Lexer) Errors() []errors.Error {
+	return l.errors
+}
+
+// Unterminated reports whether the input ended inside a string, so that a
+// REPL can read another line instead of reporting the error
+func (l *Lexer) Unterminated() bool {
+	return l.unterminated
+}
+
+// Comments returns the comments skipped so far, in source order
+func (l *Lexer) Comments() []token.Comment {
+	return l.comments
+}
+
 func (l *Lexer) Input() string {
 	return l.input
 }
@@ -46,24 +80,27 @@ func (l *Lexer) Position() int {
 	return l.position - 2
 }
 
+// CurrentPosition returns the position of the char under examination
+func (l *Lexer) CurrentPosition() token.Position {
+	offset := l.position
+	if offset > len(l.input) {
+		offset = len(l.input)
+	}
+	return token.Position{File: l.File, Offset: offset, Line: l.Line, Column: l.Column}
+}
+
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+	l.skipWhitespaceAndComments()
 
-	l.Column++
-	if l.ch == '\n' {
-		l.Line++
-		l.Column = 0
-	}
-	l.skipWhitespace()
+	start := l.CurrentPosition()
+	tok := l.readToken()
+	tok.Span = token.Span{Start: start, End: l.CurrentPosition()}
 
-	if string(l.ch) == token.SLASH && string(l.peekChar()) == token.SLASH {
-		l.skipSingleLineComment()
-		return l.NextToken()
-	}
+	return tok
+}
 
-	if l.ch == '/' && l.peekChar() == '*' {
-		l.skipMultiLineComment()
-	}
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
 
 	switch l.ch {
 	case '=':
@@ -144,17 +181,43 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.COLON, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case '.':
+		tok = newToken(token.FULLSTOP, l.ch)
+	case '?':
+		switch l.peekChar() {
+		case '?':
+			l.readChar()
+			tok = newToken(token.NULLISH, token.NULLISH)
+		case '.':
+			l.readChar()
+			tok = newToken(token.OPTIONAL_CHAIN, token.OPTIONAL_CHAIN)
+		default:
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '{':
+		if n := len(l.templates); n > 0 {
+			l.templates[n-1]++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
-		tok = newToken(token.RBRACE, l.ch)
+		n := len(l.templates)
+		switch {
+		case n > 0 && l.templates[n-1] == 0:
+			// the end of an interpolation; the string continues
+			l.templates = l.templates[:n-1]
+			tok = l.readStringToken(token.TEMPLATE_TAIL, token.TEMPLATE_MIDDLE)
+		case n > 0:
+			l.templates[n-1]--
+			fallthrough
+		default:
+			tok = newToken(token.RBRACE, l.ch)
+		}
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok = l.readStringToken(token.STRING, token.TEMPLATE_HEAD)
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
@@ -163,12 +226,19 @@ func (l *Lexer) NextToken() token.Token {
 		tok.Literal = ""
 		tok.Type = token.EOF
 	default:
-		if isLetter(l.ch) {
+		if l.letterSize() > 0 {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
 			return l.readNumber()
+		} else if l.ch >= utf8.RuneSelf {
+			// report the whole character, not just its first byte
+			_, size := utf8.DecodeRuneInString(l.input[l.position:])
+			tok = newToken(token.ILLEGAL, l.input[l.position:l.position+size])
+			for i := 1; i < size; i++ {
+				l.readChar()
+			}
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
@@ -178,14 +248,31 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
+func (l *Lexer) skipWhitespaceAndComments() {
+	for {
+		l.skipWhitespace()
+
+		switch {
+		case l.ch == '/' && l.peekChar() == '/':
+			l.skipSingleLineComment()
+		case l.ch == '/' && l.peekChar() == '*':
+			l.skipMultiLineComment()
+		default:
+			return
+		}
+	}
+}
+
 func (l *Lexer) skipSingleLineComment() {
+	start := l.CurrentPosition()
 	for l.ch != '\n' && l.ch != byte(0) {
 		l.readChar()
 	}
-	l.skipWhitespace()
+	l.addComment(start)
 }
 
 func (l *Lexer) skipMultiLineComment() {
+	start := l.CurrentPosition()
 	scanning := true
 	for scanning {
 		switch l.ch {
@@ -199,7 +286,13 @@ func (l *Lexer) skipMultiLineComment() {
 		}
 		l.readChar()
 	}
-	l.skipWhitespace()
+	l.addComment(start)
+}
+
+func (l *Lexer) addComment(start token.Position) {
+	end := l.CurrentPosition()
+	text := strings.TrimRight(l.input[start.Offset:end.Offset], "\r")
+	l.comments = append(l.comments, token.Comment{Text: text, Span: token.Span{Start: start, End: end}})
 }
 
 func (l *Lexer) skipWhitespace() {
@@ -209,6 +302,13 @@ func (l *Lexer) skipWhitespace() {
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.Line++
+		l.Column = 1
+	} else if l.position < len(l.input) {
+		l.Column++
+	}
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -228,12 +328,39 @@ func (l *Lexer) peekChar() byte {
 
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) || (isDigit(l.ch) && isLetter(l.input[l.position-1])) {
-		l.readChar()
+	for {
+		if size := l.letterSize(); size > 0 {
+			for i := 0; i < size; i++ {
+				l.readChar()
+			}
+		} else if isDigit(l.ch) {
+			// identifiers start with a letter, so digits are only read after one
+			l.readChar()
+		} else {
+			break
+		}
 	}
 	return l.input[position:l.position]
 }
 
+// letterSize returns the length in bytes of the letter at the current
+// position, or 0 if there is no letter there. Letters are those of any
+// script, and '_'.
+func (l *Lexer) letterSize() int {
+	if isLetter(l.ch) {
+		return 1
+	}
+	if l.ch < utf8.RuneSelf {
+		return 0
+	}
+
+	r, size := utf8.DecodeRuneInString(l.input[l.position:])
+	if unicode.IsLetter(r) {
+		return size
+	}
+	return 0
+}
+
 func (l *Lexer) readNumber() token.Token {
 	position := l.position
 
@@ -258,15 +385,104 @@ func (l *Lexer) readNumber() token.Token {
 	return *tok
 }
 
-func (l *Lexer) readString() string {
-	position := l.position + 1
+// readStringToken reads a string, or the part of one that follows an
+// interpolation, from the current char. It returns a token of type end if the
+// string ends before the next interpolation, or of type interpolation if
+// another one starts.
+func (l *Lexer) readStringToken(end, interpolation token.TokenType) token.Token {
+	start := l.CurrentPosition()
+
+	value, interpolates := l.readString(start)
+	if interpolates {
+		l.templates = append(l.templates, 0)
+		return token.Token{Type: interpolation, Literal: value}
+	}
+	return token.Token{Type: end, Literal: value}
+}
+
+// readString reads up to the closing quote, or the "${" of an interpolation,
+// and returns the string with its escape sequences replaced
+func (l *Lexer) readString(start token.Position) (string, bool) {
+	var out strings.Builder
+
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+
+		switch l.ch {
+		case '"':
+			return out.String(), false
+		case 0:
+			l.addError(errors.UnterminatedStringError, start)
+			l.unterminated = true
+			return out.String(), false
+		case '$':
+			if l.peekChar() == '{' {
+				l.readChar()
+				return out.String(), true
+			}
+			out.WriteByte(l.ch)
+		case '\\':
+			l.readEscapeSequence(&out)
+		default:
+			out.WriteByte(l.ch)
 		}
 	}
-	return l.input[position:l.position]
+}
+
+var escapes = map[byte]byte{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'"':  '"',
+	'\\': '\\',
+	'$':  '$',
+}
+
+// readEscapeSequence reads the escape sequence starting at the current char,
+// a backslash, and writes the char it stands for to out
+func (l *Lexer) readEscapeSequence(out *strings.Builder) {
+	start := l.CurrentPosition()
+	l.readChar()
+
+	if ch, ok := escapes[l.ch]; ok {
+		out.WriteByte(ch)
+		return
+	}
+
+	if l.ch == 'u' && l.peekChar() == '{' {
+		l.readChar()
+		digits := l.position + 1
+		for isHexDigit(l.peekChar()) {
+			l.readChar()
+		}
+
+		if l.peekChar() == '}' {
+			l.readChar()
+			r, err := strconv.ParseUint(l.input[digits:l.position], 16, 32)
+			if err == nil && utf8.ValidRune(rune(r)) {
+				out.WriteRune(rune(r))
+				return
+			}
+		}
+	}
+
+	if l.ch == 0 {
+		// let readString report the unterminated string
+		return
+	}
+
+	sequence := l.input[start.Offset : l.position+1]
+	l.addError(func(conf errors.ErrorConfig) errors.Error {
+		return errors.InvalidEscapeSequenceError(sequence, conf)
+	}, start)
+	out.WriteString(sequence)
+}
+
+func (l *Lexer) addError(newError func(errors.ErrorConfig) errors.Error, pos token.Position) {
+	conf := errors.NewErrorConfig(pos)
+	conf.LineText = errors.LineText(l.input, pos.Line)
+	l.errors = append(l.errors, newError(conf))
 }
 
 func isLetter(ch byte) bool {
@@ -277,6 +493,10 @@ func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+func isHexDigit(ch byte) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
 func newToken[T byte | string](tokenType token.TokenType, ch T) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
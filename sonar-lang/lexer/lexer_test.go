@@ -48,6 +48,9 @@ in
 break
 continue
 |
+json.parse
+null ?? a?.[0]?
+const
 `
 
 	tests := []struct {
@@ -157,6 +160,18 @@ continue
 		{token.BREAK, "break"},
 		{token.CONTINUE, "continue"},
 		{token.ILLEGAL, "|"},
+		{token.IDENT, "json"},
+		{token.FULLSTOP, "."},
+		{token.IDENT, "parse"},
+		{token.NULL, "null"},
+		{token.NULLISH, "??"},
+		{token.IDENT, "a"},
+		{token.OPTIONAL_CHAIN, "?."},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.ILLEGAL, "?"},
+		{token.CONST, "const"},
 		{token.EOF, ""},
 	}
 
@@ -176,3 +191,192 @@ continue
 		}
 	}
 }
+
+func TestTokenSpans(t *testing.T) {
+	input := "let x = 10;\n// comment\n\"ab\" /* a\nb */ y"
+
+	tests := []struct {
+		expectedLiteral string
+		start           token.Position
+		end             token.Position
+	}{
+		{"let", token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{"x", token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 5, Line: 1, Column: 6}},
+		{"=", token.Position{Offset: 6, Line: 1, Column: 7}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{"10", token.Position{Offset: 8, Line: 1, Column: 9}, token.Position{Offset: 10, Line: 1, Column: 11}},
+		{";", token.Position{Offset: 10, Line: 1, Column: 11}, token.Position{Offset: 11, Line: 1, Column: 12}},
+		{"ab", token.Position{Offset: 23, Line: 3, Column: 1}, token.Position{Offset: 27, Line: 3, Column: 5}},
+		{"y", token.Position{Offset: 38, Line: 4, Column: 6}, token.Position{Offset: 39, Line: 4, Column: 7}},
+		{"", token.Position{Offset: 39, Line: 4, Column: 7}, token.Position{Offset: 39, Line: 4, Column: 7}},
+	}
+
+	l := New(input, nil)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Span.Start != tt.start {
+			t.Errorf("tests[%d] - start wrong. expected=%+v, got=%+v", i, tt.start, tok.Span.Start)
+		}
+		if tok.Span.End != tt.end {
+			t.Errorf("tests[%d] - end wrong. expected=%+v, got=%+v", i, tt.end, tok.Span.End)
+		}
+	}
+}
+
+func TestShebang(t *testing.T) {
+	tok := New("#!/usr/bin/env sonar-lang\nx", nil).NextToken()
+	if tok.Type != token.IDENT || tok.Span.Start != (token.Position{Offset: 26, Line: 2, Column: 1}) {
+		t.Errorf("expected the #! line to be skipped, got=%+v", tok)
+	}
+
+	// only the first line can be one
+	if tok := New("x\n#!", nil); tok.NextToken().Type != token.IDENT || tok.NextToken().Type != token.ILLEGAL {
+		t.Errorf("expected #! after the first line to be illegal")
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "#!/bin/sonar\nx // one\r\n/* two\nlines */ y /* unterminated"
+
+	l := New(input, nil)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+
+	expected := []token.Comment{
+		{Text: "#!/bin/sonar", Span: token.Span{Start: token.Position{Offset: 0, Line: 1, Column: 1}, End: token.Position{Offset: 12, Line: 1, Column: 13}}},
+		{Text: "// one", Span: token.Span{Start: token.Position{Offset: 15, Line: 2, Column: 3}, End: token.Position{Offset: 22, Line: 2, Column: 10}}},
+		{Text: "/* two\nlines */", Span: token.Span{Start: token.Position{Offset: 23, Line: 3, Column: 1}, End: token.Position{Offset: 38, Line: 4, Column: 9}}},
+		{Text: "/* unterminated", Span: token.Span{Start: token.Position{Offset: 41, Line: 4, Column: 12}, End: token.Position{Offset: 56, Line: 4, Column: 27}}},
+	}
+	comments := l.Comments()
+	if len(comments) != len(expected) {
+		t.Fatalf("wrong number of comments. expected=%d, got=%+v", len(expected), comments)
+	}
+	for i, c := range comments {
+		if c != expected[i] {
+			t.Errorf("comments[%d] wrong. expected=%+v, got=%+v", i, expected[i], c)
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	input := `"a\nb\t\"c\" \\ \$ \u{e9}\u{1F600}"
+"Hello ${name}, you have ${len({"a": 1})} items"
+"${"in ${x}"}"
+"$ {}"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING, "a\nb\t\"c\" \\ $ é😀"},
+		{token.TEMPLATE_HEAD, "Hello "},
+		{token.IDENT, "name"},
+		{token.TEMPLATE_MIDDLE, ", you have "},
+		{token.IDENT, "len"},
+		{token.LPAREN, "("},
+		{token.LBRACE, "{"},
+		{token.STRING, "a"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.RPAREN, ")"},
+		{token.TEMPLATE_TAIL, " items"},
+		{token.TEMPLATE_HEAD, ""},
+		{token.TEMPLATE_HEAD, "in "},
+		{token.IDENT, "x"},
+		{token.TEMPLATE_TAIL, ""},
+		{token.TEMPLATE_TAIL, ""},
+		{token.STRING, "$ {}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input, nil)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q, type=%q",
+				i, tt.expectedLiteral, tok.Literal, tt.expectedType)
+		}
+	}
+
+	if len(l.Errors()) != 0 {
+		t.Errorf("expected no errors, got=%+v", l.Errors())
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		column   int
+	}{
+		{`"a\qb"`, `Invalid escape sequence '\q' in string`, 3},
+		{`"\u{110000}"`, `Invalid escape sequence '\u{110000}' in string`, 2},
+		{`"\u{zz}"`, `Invalid escape sequence '\u{' in string`, 2},
+		{`let x = "abc`, "Unterminated string literal", 9},
+		{`"abc\`, "Unterminated string literal", 1},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input, nil)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		errs := l.Errors()
+		if len(errs) != 1 {
+			t.Errorf("%s: expected 1 error, got=%+v", tt.input, errs)
+			continue
+		}
+		if errs[0].Message != tt.expected || errs[0].Column != tt.column {
+			t.Errorf("%s: expected %q at column %d, got=%q at column %d",
+				tt.input, tt.expected, tt.column, errs[0].Message, errs[0].Column)
+		}
+		if l.Unterminated() != (tt.expected == "Unterminated string literal") {
+			t.Errorf("%s: wrong Unterminated. got=%t", tt.input, l.Unterminated())
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := `let café = 名前1 + ñ_2 + log10; §`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "café"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "名前1"},
+		{token.PLUS, "+"},
+		{token.IDENT, "ñ_2"},
+		{token.PLUS, "+"},
+		{token.IDENT, "log10"},
+		{token.SEMICOLON, ";"},
+		{token.ILLEGAL, "§"},
+		{token.EOF, ""},
+	}
+
+	l := New(input, nil)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token wrong. expected=%s(%q), got=%s(%q)",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
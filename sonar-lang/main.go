@@ -1,67 +1,215 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
-	"path"
+	"runtime/debug"
+	"strings"
 
-	"github.com/icheka/sonar-lang/sonar-lang/errors"
 	"github.com/icheka/sonar-lang/sonar-lang/evaluator"
-	"github.com/icheka/sonar-lang/sonar-lang/inputs"
-	"github.com/icheka/sonar-lang/sonar-lang/lexer"
-	"github.com/icheka/sonar-lang/sonar-lang/object"
-	"github.com/icheka/sonar-lang/sonar-lang/parser"
 	"github.com/icheka/sonar-lang/sonar-lang/repl"
 )
 
-func evaluate(source string, options *lexer.LexerOptions) {
-	p := parser.New(lexer.New(source, options))
-	program := p.ParseProgram()
-	if len(p.Errors()) != 0 {
-		repl.PrintParserErrors(os.Stderr, p.Errors())
-		return
-	}
+// the statuses sonar-lang exits with
+const (
+	EXIT_OK    = 0
+	EXIT_ERROR = 1 // the program has errors, or raised one
+	EXIT_USAGE = 2 // the command was used incorrectly, or its files could not be read
+)
 
-	evaluated := evaluator.Eval(program, object.NewEnvironment())
+// version is the version of sonar-lang, which builds can set with
+// -ldflags "-X main.version=..."; otherwise it is the version of the module
+var version = ""
 
-	if evaluated != nil && evaluated.Type() == object.ERROR_OBJ {
-		if obj, ok := evaluated.(*object.Error); ok {
-			if conf, ok := obj.Conf.(errors.Error); ok {
-				repl.PrintParserErrors(os.Stderr, []errors.Error{conf})
-			}
-			return
-		}
+type command struct {
+	name    string
+	usage   string // the arguments the command takes
+	summary string
+	run     func(c *cli, args []string) int
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"run", "[--vm] [-e source | file | -] [--] [args...]", "run a program", (*cli).run},
+		{"repl", "[--history file]", "start an interactive session", (*cli).repl},
+		{"check", "[--format=text|json|sarif] [--eval] file", "report the errors in a program", (*cli).check},
+		{"test", "[paths...] [-- args...]", "run the test functions in *_test.sonar files", (*cli).test},
+		{"fmt", "[--check | --write] [paths... | -]", "lay out programs in the canonical style", (*cli).fmt},
+		{"ast", "[file | -]", "print the syntax tree of a program", (*cli).ast},
+		{"tokens", "[file | -]", "print the tokens of a program", (*cli).tokens},
+		{"version", "", "print the version of sonar-lang", (*cli).version},
+		{"help", "[command]", "print this help, or the help of a command", (*cli).help},
 	}
 }
 
+// cli runs sonar-lang commands, reading and writing the given streams
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
 func main() {
 	evaluator.InitStdlib()
+	c := &cli{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+	os.Exit(c.main(os.Args[1:]))
+}
 
-	args := os.Args[1:]
+// main runs the command args name and returns the status to exit with.
+// Without a command, it runs the program at the file args name, or starts a
+// REPL if there is none and stdin is a terminal.
+func (c *cli) main(args []string) int {
+	args = legacyArgs(args)
 	if len(args) == 0 {
-		repl.Start(os.Stdin, os.Stdout)
-		return
-	}
-
-	if len(args) == 2 {
-		switch args[0] {
-		case "-f":
-			cwd, err := os.Getwd()
-			if err != nil {
-				panic("Something went wrong!")
-			}
-
-			filePath := path.Join(cwd, args[1])
-			input := &inputs.FileInput{Path: filePath}
-			source := input.Read()
-
-			evaluate(source, &lexer.LexerOptions{Path: filePath})
-			return
-		case "-text":
-			evaluate(args[1], nil)
-			return
+		if isTerminal(c.stdin) {
+			return c.repl(nil)
+		}
+		return c.run(nil)
+	}
+
+	switch args[0] {
+	case "-h", "--help":
+		return c.help(args[1:])
+	case "-v", "--version":
+		return c.version(args[1:])
+	}
+	if cmd, ok := lookupCommand(args[0]); ok {
+		return cmd.run(c, args[1:])
+	}
+	return c.run(args)
+}
+
+// legacyArgs translates the invocations sonar-lang took before it had
+// commands, -f path and -text source, into the arguments of run. What
+// follows the program is left to it.
+func legacyArgs(args []string) []string {
+	if len(args) < 2 {
+		return args
+	}
+	switch args[0] {
+	case "-f":
+		return append([]string{args[1]}, args[2:]...)
+	case "-text":
+		return append([]string{"-e", args[1]}, args[2:]...)
+	}
+	return args
+}
+
+func lookupCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func (c *cli) help(args []string) int {
+	if len(args) > 0 && args[0] != "-h" && args[0] != "--help" {
+		cmd, ok := lookupCommand(args[0])
+		if !ok {
+			fmt.Fprintf(c.stderr, "Unknown command %q. Run \"sonar-lang help\" for a list of commands.\n", args[0])
+			return EXIT_USAGE
+		}
+		return cmd.run(c, []string{"--help"})
+	}
+
+	fmt.Fprint(c.stdout, `sonar-lang runs and checks Sonar programs.
+
+Usage:
+  sonar-lang [--vm] [file | -] [args...]
+  sonar-lang <command> [arguments]
+
+Commands:
+`)
+	for _, cmd := range commands {
+		fmt.Fprintf(c.stdout, "  %-9s%s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprint(c.stdout, `
+Without a command, sonar-lang runs the program at file, or starts a REPL if
+there is none and standard input is a terminal. "-" reads the program from
+standard input. The arguments after the program, or after "--", are given to
+it as os.args.
+
+Run "sonar-lang help <command>" for the flags a command takes.
+`)
+	return EXIT_OK
+}
+
+func (c *cli) version(args []string) int {
+	flags := c.flagSet("version")
+	if status, ok := parseFlags(flags, args); !ok {
+		return status
+	}
+
+	v := version
+	if len(v) == 0 {
+		v = "(devel)"
+		if info, ok := debug.ReadBuildInfo(); ok && len(info.Main.Version) != 0 {
+			v = info.Main.Version
 		}
 	}
+	fmt.Fprintf(c.stdout, "sonar-lang %s\n", v)
+	return EXIT_OK
+}
 
-	fmt.Println("Usage: go run main.go [-f [path]] | [-text input]")
+func (c *cli) repl(args []string) int {
+	flags := c.flagSet("repl")
+	history := flags.String("history", repl.DefaultHistoryFile(), "save the lines entered to `file`; \"\" to keep no history")
+	if status, ok := parseFlags(flags, args); !ok {
+		return status
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return EXIT_USAGE
+	}
+
+	repl.Start(c.stdin, c.stdout, repl.Options{HistoryFile: *history})
+	return EXIT_OK
+}
+
+// flagSet returns the flags of the named command, which report errors and
+// print its usage to stderr
+func (c *cli) flagSet(name string) *flag.FlagSet {
+	cmd, _ := lookupCommand(name)
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: sonar-lang %s %s\n\n%s%s.\n", name, cmd.usage, strings.ToUpper(cmd.summary[:1]), cmd.summary[1:])
+		hasFlags := false
+		flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintln(c.stderr, "\nFlags:")
+			flags.PrintDefaults()
+		}
+	}
+	return flags
+}
+
+// parseFlags parses args into flags. If that does not leave a command to
+// carry out, because they ask for help or are invalid, it returns the status
+// to exit with and false.
+func parseFlags(flags *flag.FlagSet, args []string) (int, bool) {
+	err := flags.Parse(args)
+	if err == flag.ErrHelp {
+		return EXIT_OK, false
+	}
+	if err != nil {
+		return EXIT_USAGE, false
+	}
+	return EXIT_OK, true
+}
+
+// isTerminal reports whether r is a terminal rather than a file or pipe
+func isTerminal(r io.Reader) bool {
+	file, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/icheka/sonar-lang/sonar-lang/diagnostics"
+	"github.com/icheka/sonar-lang/sonar-lang/evaluator"
+)
+
+// runCLI runs sonar-lang with args, giving it stdin, and returns its status
+// and what it wrote to stdout and stderr
+func runCLI(stdin string, args ...string) (int, string, string) {
+	evaluator.InitStdlib()
+
+	var stdout, stderr strings.Builder
+	c := &cli{stdin: strings.NewReader(stdin), stdout: &stdout, stderr: &stderr}
+	status := c.main(args)
+	return status, stdout.String(), stderr.String()
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	script := writeFile(t, dir, "script.sonar", "#!/usr/bin/env sonar-lang\nprint(os.args)")
+	failing := writeFile(t, dir, "failing.sonar", "let a = 1;\nb")
+
+	tests := []struct {
+		stdin    string
+		args     []string
+		status   int
+		expected string
+	}{
+		{"", []string{script, "a", "b"}, EXIT_OK, "['a', 'b']"},
+		{"", []string{"run", script, "--", "-x"}, EXIT_OK, "['-x']"},
+		{"", []string{"-e", "print(os.args)", "--", "a"}, EXIT_OK, "['a']"},
+		{"print(1 + 2)", []string{}, EXIT_OK, "3"},
+		{"print(os.args)", []string{"run", "-", "a"}, EXIT_OK, "['a']"},
+		{"", []string{"-f", script}, EXIT_OK, "[]"},
+		{"", []string{"-text", "print(4)"}, EXIT_OK, "4"},
+		{"", []string{"run", script, "--", "-f", "x", "-text", "y"}, EXIT_OK, "['-f', 'x', '-text', 'y']"},
+		{"", []string{script, "-f", "x"}, EXIT_OK, "['-f', 'x']"},
+		{"", []string{"-f", script, "-text", "y"}, EXIT_OK, "['-text', 'y']"},
+		{"", []string{failing}, EXIT_ERROR, ""},
+		{"let = 1", []string{"run"}, EXIT_ERROR, ""},
+		{"", []string{"run", "--vm", failing}, EXIT_ERROR, ""},
+		{"", []string{filepath.Join(dir, "missing.sonar")}, EXIT_USAGE, ""},
+		{"", []string{"run", "--unknown"}, EXIT_USAGE, ""},
+	}
+
+	for _, tt := range tests {
+		status, stdout, _ := runCLI(tt.stdin, tt.args...)
+		if status != tt.status {
+			t.Errorf("%v: expected status %d, got=%d", tt.args, tt.status, status)
+		}
+		if !strings.Contains(stdout, tt.expected) {
+			t.Errorf("%v: expected output to contain %q, got=%q", tt.args, tt.expected, stdout)
+		}
+	}
+
+	if _, _, stderr := runCLI("", failing); !strings.Contains(stderr, "ReferenceError: Identifier 'b' has not been defined") {
+		t.Errorf("expected the error to be reported, got=%q", stderr)
+	}
+}
+
+func TestHelpAndVersion(t *testing.T) {
+	tests := []struct {
+		args   []string
+		status int
+		stdout string
+		stderr string
+	}{
+		{[]string{"--help"}, EXIT_OK, "Commands:", ""},
+		{[]string{"help"}, EXIT_OK, "tokens", ""},
+		{[]string{"help", "run"}, EXIT_OK, "", "Usage: sonar-lang run"},
+		{[]string{"check", "-h"}, EXIT_OK, "", "-format"},
+		{[]string{"help", "nope"}, EXIT_USAGE, "", "Unknown command"},
+		{[]string{"--version"}, EXIT_OK, "sonar-lang ", ""},
+		{[]string{"version"}, EXIT_OK, "sonar-lang ", ""},
+	}
+
+	for _, tt := range tests {
+		status, stdout, stderr := runCLI("", tt.args...)
+		if status != tt.status || !strings.Contains(stdout, tt.stdout) || !strings.Contains(stderr, tt.stderr) {
+			t.Errorf("%v: expected status %d, stdout containing %q and stderr containing %q, got=%d %q %q",
+				tt.args, tt.status, tt.stdout, tt.stderr, status, stdout, stderr)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	valid := writeFile(t, dir, "valid.sonar", "let a = 1\nb")
+	invalid := writeFile(t, dir, "invalid.sonar", "let = 1")
+
+	tests := []struct {
+		args     []string
+		status   int
+		expected string
+	}{
+		{[]string{valid}, EXIT_OK, ""},
+		{[]string{"--format=json", valid}, EXIT_OK, `"diagnostics": []`},
+		{[]string{"--format=json", "--eval", valid}, EXIT_ERROR, `"type": "ReferenceError"`},
+		{[]string{"--format=json", invalid}, EXIT_ERROR, `"type": "SyntaxError"`},
+		{[]string{"--format=sarif", invalid}, EXIT_ERROR, `"ruleId": "SyntaxError"`},
+		{[]string{invalid}, EXIT_ERROR, "--> "},
+		{[]string{"--format=xml", invalid}, EXIT_USAGE, ""},
+		{[]string{filepath.Join(dir, "missing.sonar")}, EXIT_USAGE, ""},
+		{[]string{}, EXIT_USAGE, ""},
+	}
+
+	for _, tt := range tests {
+		status, stdout, _ := runCLI("", append([]string{"check"}, tt.args...)...)
+		if status != tt.status {
+			t.Errorf("%v: expected status %d, got=%d", tt.args, tt.status, status)
+		}
+		if !strings.Contains(stdout, tt.expected) {
+			t.Errorf("%v: expected output to contain %q, got=%q", tt.args, tt.expected, stdout)
+		}
+	}
+}
+
+func TestTest(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "math_test.sonar", `
+let add = func(a, b) { a + b }
+let testAdd = func() { if (add(1, 2) != 3) { throw "wrong" } }
+let testBroken = func() { throw "boom" }
+let helper = func() { throw "not a test" }
+`)
+	writeFile(t, dir, "sub/ok_test.sonar", "let testOk = func() {}")
+	writeFile(t, dir, "sub/helpers.sonar", "throw 1")
+
+	status, stdout, stderr := runCLI("", "test", dir)
+	if status != EXIT_ERROR {
+		t.Errorf("expected a failing test to fail the run, got=%d", status)
+	}
+	for _, expected := range []string{"--- FAIL: testBroken\n", "math_test.sonar\t1 of 2 tests failed\n", "ok\t" + filepath.Join(dir, "sub/ok_test.sonar") + "\t1 test\n"} {
+		if !strings.Contains(stdout, expected) {
+			t.Errorf("expected output to contain %q, got=%q", expected, stdout)
+		}
+	}
+	if !strings.Contains(stderr, "boom") || strings.Contains(stdout+stderr, "not a test") {
+		t.Errorf("expected only the test functions to run, got=%q", stderr)
+	}
+
+	if !strings.Contains(stderr, "in testBroken\n    let testBroken = func() { throw \"boom\" }\n") || strings.Contains(stderr, diagnostics.INPUT) {
+		t.Errorf("expected tracebacks to start in the test function, got=%q", stderr)
+	}
+
+	if status, stdout, stderr := runCLI("", "test", filepath.Join(dir, "sub")); status != EXIT_OK {
+		t.Errorf("expected passing tests to pass the run, got=%d %q %q", status, stdout, stderr)
+	}
+
+	// the arguments after -- are given to the tests
+	writeFile(t, dir, "args/args_test.sonar", `let testArgs = func() { print(os.args) }`)
+	status, stdout, stderr = runCLI("", "test", filepath.Join(dir, "args"), "--", "a", "--")
+	if status != EXIT_OK || !strings.Contains(stdout, "['a', '--']\n") {
+		t.Errorf("expected the arguments after -- to be os.args, got=%d %q %q", status, stdout, stderr)
+	}
+}
+
+func TestSyntaxCommands(t *testing.T) {
+	status, stdout, _ := runCLI("let a = 1 + b", "ast")
+	if status != EXIT_OK || !strings.Contains(stdout, "  LetStatement 1:1 \"let\"\n    Identifier 1:5 \"a\"\n    InfixExpression 1:9 \"+\"\n") {
+		t.Errorf("wrong syntax tree. got=%d %q", status, stdout)
+	}
+	if status, _, stderr := runCLI("let = 1", "ast"); status != EXIT_ERROR || !strings.Contains(stderr, "SyntaxError") {
+		t.Errorf("expected a syntax error, got=%d %q", status, stderr)
+	}
+
+	status, stdout, _ = runCLI("a = \"b\"", "tokens")
+	if status != EXIT_OK || stdout != "1:1\tIDENTIFIER      \"a\"\n1:3\t=               \"=\"\n1:5\tSTRING          \"b\"\n1:8\tEOF             \"\"\n" {
+		t.Errorf("wrong tokens. got=%d %q", status, stdout)
+	}
+	if status, _, _ := runCLI("\"a", "tokens"); status != EXIT_ERROR {
+		t.Errorf("expected an unterminated string to be an error, got=%d", status)
+	}
+}
+
+func TestFmt(t *testing.T) {
+	if status, stdout, _ := runCLI("let a=1 // one\n", "fmt"); status != EXIT_OK || stdout != "let a = 1 // one\n" {
+		t.Errorf("wrong formatted program. got=%d %q", status, stdout)
+	}
+	if status, _, stderr := runCLI("let = 1", "fmt"); status != EXIT_ERROR || !strings.Contains(stderr, "SyntaxError") {
+		t.Errorf("expected a syntax error, got=%d %q", status, stderr)
+	}
+
+	dir := t.TempDir()
+	messy := writeFile(t, dir, "messy.sonar", "if(a){b}")
+	tidy := writeFile(t, dir, "sub/tidy.sonar", "print(1)\n")
+	writeFile(t, dir, "notes.txt", "if(a){b}")
+
+	status, stdout, _ := runCLI("", "fmt", "--check", dir)
+	if status != EXIT_ERROR || stdout != messy+"\n" {
+		t.Errorf("expected --check to list only the messy file, got=%d %q", status, stdout)
+	}
+	if status, _, _ := runCLI("", "fmt", "--check", tidy); status != EXIT_OK {
+		t.Errorf("expected --check to pass a formatted file, got=%d", status)
+	}
+
+	if status, stdout, stderr := runCLI("", "fmt", "--write", dir); status != EXIT_OK || stdout != "" {
+		t.Errorf("expected --write to succeed quietly, got=%d %q %q", status, stdout, stderr)
+	}
+	if content, _ := os.ReadFile(messy); string(content) != "if (a) {\n\tb\n}\n" {
+		t.Errorf("expected --write to rewrite the file, got=%q", content)
+	}
+
+	if status, _, _ := runCLI("", "fmt", "--check", "--write", dir); status != EXIT_USAGE {
+		t.Errorf("expected --check and --write together to be a usage error, got=%d", status)
+	}
+	if status, _, _ := runCLI("", "fmt", "--write"); status != EXIT_USAGE {
+		t.Errorf("expected --write without files to be a usage error, got=%d", status)
+	}
+}
+
+// TestEnginesAgree runs each program with the evaluator and on the virtual
+// machine, which must print the same output
+func TestEnginesAgree(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "math.sonar", "print(\"loading\")\nexport let add = func(a, b) { a + b }\nexport const TWO = 2\nlet secret = 1")
+	writeFile(t, dir, "counter.sonar", "let n = 0\nexport let next = func() { n += 1; n }\nexport let count = func() { n }")
+	writeFile(t, dir, "broken.sonar", "export let x = 1\nlet f = func() { x + \"a\" }\nf()")
+	writeFile(t, dir, "cycle.sonar", "import \"./cycle\"")
+	module := func(name string) string { return filepath.Join(dir, name) }
+
+	programs := []string{
+		// a function assigning to a name of an enclosing scope binds it itself
+		"let x = 1; let f = func() { x = 2; print(x) }; f(); print(x)",
+		"let x = 1; let f = func() { x += 2; x++; x }; print(f(), f(), x)",
+		"let x = 1; let f = func() { print(x); x = 2; print(x) }; f(); f()",
+		"let x = 1; let f = func(c) { if (c) { x = 2 }; x }; print(f(true), f(false))",
+		"let x = 1; let f = func() { let i = 0; while (i < 2) { print(x); x = 10; i++ } }; f()",
+		"let counter = func() { let c = 0; func() { c = c + 1; c } }; let next = counter(); print(next(), next())",
+		"let xs = []; each([1, 2], func(x) { xs = push(xs, x); print(xs) }); print(xs)",
+		"let f = func() { let g = func() { x }; x = 5; g() }; let x = 1; print(f(), x)",
+		"let f = func() { let y = 1; let g = func() { y = y + 1; let h = func() { y }; h() }; print(g(), y) }; f()",
+		"let f = func(xs) { for (i, v in xs) { print(i, v) }\n v }; let v = 0; print(f([7, 8]), f([]), v)",
+		"let f = func() { x = 2; let x = 3; x }; let x = 1; print(x)",
+		"for (i, v in [1, 2]) { let f = func() { v = v * 10; v }; print(f(), v) }",
+
+		// closures see later changes to the variables they capture
+		"let f = func() { let n = 1; let g = func() { n }; n = 2; g() }; print(f())",
+		"let fns = []; for (i, v in [1, 2, 3]) { fns = push(fns, func() { v }) }\nprint(map(fns, func(f) { f() }))",
+
+		"let f = func() { while (true) { break }\nfor (i, v in [1]) { break }\n1 }; print(f())",
+		"let r = func(n) { let a = n; let b = [a, a]; let c = {a: b}; if (n == 0) { return 0 }; 1 + r(n - 1) }; print(r(9999))",
+		"let fib = func(n) { if (n < 2) { return n }; fib(n - 1) + fib(n - 2) }; print(fib(15))",
+		"let i = 0; let s = \"\"; while (i < 5) { i++; if (i == 2) { continue }; if (i == 4) { break }; s = \"${s}${i}\" }\nprint(s)",
+		"let m = {\"a\": {\"b\": [1, 2]}}; print(m?.a?.b[1], m?.c?.d, (m.c)?.d ?? \"none\")",
+		"print(reduce(filter(range(1, 10), func(x) { x > 4 }), func(a, b) { a + b }, 0))",
+		"const k = 2; let f = func(x) { x * k }; print(map([1, 2], f), sort([3, 1, 2]))",
+
+		// errors are caught, and finally blocks run however they are left
+		"let f = func(x) { try { if (x > 1) { throw \"big\" }; return x } catch (e) { print(e) } finally { print(\"done\", x) } }\nprint(f(1), f(2))",
+		"let g = func() { [1][2] }\ntry { each([1], func(x) { g() }) } catch (e) { print(e.type, e.message, e.line, e.column) }",
+		"let i = 0; while (i < 5) { i++; try { if (i == 2) { continue }; if (i == 4) { break }; print(i) } finally { print(\"after\", i) } }",
+		"let f = func() { for (i, v in [1, 2]) { try { try { return v } finally { print(\"inner\") } } finally { print(\"outer\") } } }; print(f())",
+		"let r = func(n) { r(n + 1) }; try { r(0) } catch (e) { print(e.message) }",
+
+		// modules run once, and their functions keep using their globals
+		"import \"" + module("math") + "\"\nimport \"" + module("math") + "\" as m\nimport { add, TWO } from \"" + module("math") + "\"\nprint(math.add(1, TWO), m == math, add)",
+		"import { next, count } from \"" + module("counter") + "\"\nlet n = 10\nprint(next(), next(), count(), n)",
+		"import \"" + module("math") + "\"\ntry { math.secret } catch (e) { print(e.message) }",
+	}
+
+	// and report the same errors, with the same tracebacks
+	failing := []string{
+		"print(1)\nundefined",
+		"let f = func(n) {\n  n + \"a\"\n}\nlet g = func() { f(1) }\ng()",
+		"let f = func(x) { x + 1 }\nmap([1, \"a\"], f)",
+		"let a = 1\na(2)",
+		"let xs = [1]\nxs[5] = 2",
+		"let m = {}\nm.f(1)",
+		"for (i, v in true) { print(i) }",
+		"len(1, 2)",
+		"let f = func() { x = 1 }\nf()",
+		"let s = \"a\"\ns++",
+		"-\"a\"",
+		"func() { 1 + true }()",
+		"{func() {}: 2}",
+		"print(1 / 0)",
+		"print(1)\nbreak",
+		"let r = func(n) { if (n == 0) { return 0 }; 1 + r(n - 1) }\nprint(r(10000))",
+		"\"${1 + \"a\"}\"",
+		"let f = func() { throw {\"type\": \"ValueError\", \"message\": \"bad\"} }\nf()",
+		"let f = func() { [][1] }\nlet g = func() { try { f() } finally { print(1) } }\ng()",
+		"try { throw 1 } catch (e) { undefined }",
+		"let f = func() { try { throw \"a\" } catch (e) { throw e } }\nf()",
+		"print(1)\nimport \"" + module("broken") + "\"",
+		"import \"" + module("cycle") + "\"",
+		"import \"" + module("missing") + "\"",
+		"import { secret } from \"" + module("math") + "\"",
+	}
+
+	for _, tt := range []struct {
+		programs []string
+		status   int
+	}{{programs, EXIT_OK}, {failing, EXIT_ERROR}} {
+		for _, program := range tt.programs {
+			status, stdout, stderr := runCLI("", "run", "-e", program)
+			vmStatus, vmStdout, vmStderr := runCLI("", "run", "--vm", "-e", program)
+			if status != tt.status {
+				t.Errorf("%s: expected the evaluator to exit with %d, got=%d %q", program, tt.status, status, stderr)
+			}
+			if status != vmStatus || stdout != vmStdout || stderr != vmStderr {
+				t.Errorf("%s: the evaluator gave %d %q %q, the VM %d %q %q",
+					program, status, stdout, stderr, vmStatus, vmStdout, vmStderr)
+			}
+		}
+	}
+}
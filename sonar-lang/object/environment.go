@@ -1,6 +1,8 @@
 package object
 
 import (
+	"context"
+
 	"github.com/icheka/sonar-lang/sonar-lang/errors"
 )
 
@@ -23,11 +25,93 @@ func NewEphemeralScope(allow []string, readonly map[string]bool, parent *Environ
 	return env
 }
 
+// NewModuleEnvironment returns the environment an imported module is
+// evaluated in. It belongs to the program importing it, sharing its loaded
+// modules, builtins and limits, but none of its bindings.
+func NewModuleEnvironment(importer *Environment) *Environment {
+	env := NewEnvironment()
+	env.program = importer.root()
+	return env
+}
+
 type Environment struct {
 	Store    map[string]Object
 	outer    *Environment
 	allow    []string
 	Readonly map[string]bool
+	// the names declared with `export let`, in declaration order
+	Exports []string
+
+	// set on the environments of modules, to the one of the program that
+	// imported them; the fields below are only set on that environment
+	program   *Environment
+	modules   *Modules
+	builtins  map[string]*Builtin
+	execution *Execution
+	args      []string
+}
+
+// root returns the outermost environment of the program e belongs to
+func (e *Environment) root() *Environment {
+	root := e
+	for root.outer != nil {
+		root = root.outer
+	}
+	if root.program != nil {
+		return root.program
+	}
+	return root
+}
+
+// Modules returns the modules loaded by the program e belongs to
+func (e *Environment) Modules() *Modules {
+	root := e.root()
+	if root.modules == nil {
+		root.modules = NewModules()
+	}
+	return root.modules
+}
+
+// Builtins returns the builtin functions of the program e belongs to, or nil
+// if it uses the default ones
+func (e *Environment) Builtins() map[string]*Builtin {
+	return e.root().builtins
+}
+
+// Execution returns the resources used by the program e belongs to, which
+// are counted against the default limits until EvalContext sets others
+func (e *Environment) Execution() *Execution {
+	root := e.root()
+	if root.execution == nil {
+		// programs evaluated without limits still cannot recurse forever
+		root.execution = NewExecution(context.Background(), Limits{})
+	}
+	return root.execution
+}
+
+// SetExecution makes the program e belongs to count the resources it uses
+// against execution, and returns what it counted them against before
+func (e *Environment) SetExecution(execution *Execution) *Execution {
+	root := e.root()
+	previous := root.execution
+	root.execution = execution
+	return previous
+}
+
+// SetBuiltins gives the program e belongs to its own builtin functions,
+// replacing the default ones
+func (e *Environment) SetBuiltins(builtins map[string]*Builtin) {
+	e.root().builtins = builtins
+}
+
+// Args returns the arguments the program e belongs to was run with
+func (e *Environment) Args() []string {
+	return e.root().args
+}
+
+// SetArgs sets the arguments the program e belongs to was run with
+func (e *Environment) SetArgs(args []string) {
+	e.root().args = args
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -43,7 +127,7 @@ func (e *Environment) Set(name string, val Object) Object {
 	// - check whether it's been initialised as a constant,
 	// - if it has, return an error
 	if e.Store[name] != nil && e.Readonly[name] {
-		return &Error{Conf: errors.ConstantAssignmentError(name)}
+		return &Error{Conf: errors.ConstantAssignmentError(name, errors.ErrorConfig{})}
 	}
 
 	if len(e.allow) > 0 {
@@ -62,3 +146,86 @@ func (e *Environment) Set(name string, val Object) Object {
 	e.Store[name] = val
 	return val
 }
+
+// SetConstant declares name as a constant with the value val, which Set will
+// then refuse to change. Unlike Set, it may replace a constant, as a
+// declaration in a loop body does on each iteration.
+func (e *Environment) SetConstant(name string, val Object) Object {
+	scope := e.declaringScope(name)
+	scope.Store[name] = val
+	if scope.Readonly == nil {
+		scope.Readonly = make(map[string]bool)
+	}
+	scope.Readonly[name] = true
+	return val
+}
+
+// IsConstant reports whether name refers to a constant, in e or the
+// environments enclosing it
+func (e *Environment) IsConstant(name string) bool {
+	if _, ok := e.Store[name]; ok {
+		return e.Readonly[name]
+	}
+	if e.outer != nil {
+		return e.outer.IsConstant(name)
+	}
+	return false
+}
+
+// declaringScope returns the environment Set stores name in
+func (e *Environment) declaringScope(name string) *Environment {
+	if len(e.allow) == 0 || e.outer == nil {
+		return e
+	}
+	for _, identifier := range e.allow {
+		if identifier == name {
+			return e
+		}
+	}
+	return e.outer.declaringScope(name)
+}
+
+// Modules caches the modules imported by a program, by absolute path, so that
+// each is evaluated only once
+type Modules struct {
+	loaded map[string]*Module
+	// the modules being evaluated, outermost first
+	loading []string
+}
+
+func NewModules() *Modules {
+	return &Modules{loaded: make(map[string]*Module)}
+}
+
+func (m *Modules) Get(path string) (*Module, bool) {
+	module, ok := m.loaded[path]
+	return module, ok
+}
+
+func (m *Modules) Add(module *Module) {
+	m.loaded[module.Path] = module
+}
+
+// Begin records that the module at path is being evaluated. If it already
+// is, importing it again would never finish, so Begin returns the chain of
+// imports leading back to it instead.
+func (m *Modules) Begin(path string) []string {
+	for i, loading := range m.loading {
+		if loading == path {
+			cycle := append([]string{}, m.loading[i:]...)
+			return append(cycle, path)
+		}
+	}
+	m.loading = append(m.loading, path)
+	return nil
+}
+
+// End records that the module at path has been evaluated
+func (m *Modules) End(path string) {
+	for i := len(m.loading) - 1; i >= 0; i-- {
+		if m.loading[i] == path {
+			m.loading = append(m.loading[:i], m.loading[i+1:]...)
+			return
+		}
+	}
+}
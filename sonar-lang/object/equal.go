@@ -0,0 +1,138 @@
+package object
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// Equal reports whether a and b are the same value. Numbers are equal if their
+// values are, whether they are integers or floats; strings, booleans and nulls
+// if their contents are; and arrays and maps if their elements are. Maps are
+// equal whatever the order of their keys. Functions, builtins and modules are
+// only equal to themselves.
+func Equal(a, b Object) bool {
+	return equal(a, b, map[[2]Object]bool{})
+}
+
+func equal(a, b Object, comparing map[[2]Object]bool) bool {
+	if a == b {
+		return true
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == b.Value
+		case *Float:
+			return float64(a.Value) == b.Value
+		}
+	case *Float:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == float64(b.Value)
+		case *Float:
+			return a.Value == b.Value
+		}
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		// an array that contains itself is equal to another one like it, so
+		// stop where the comparison would repeat itself
+		pair := [2]Object{a, b}
+		if comparing[pair] {
+			return true
+		}
+		comparing[pair] = true
+		defer delete(comparing, pair)
+
+		for i, element := range a.Elements {
+			if !equal(element, b.Elements[i], comparing) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok || len(a.Pairs) != len(b.Pairs) {
+			return false
+		}
+		pair := [2]Object{a, b}
+		if comparing[pair] {
+			return true
+		}
+		comparing[pair] = true
+		defer delete(comparing, pair)
+
+		for key, p := range a.Pairs {
+			other, ok := b.Pairs[key]
+			if !ok || !equal(p.Value, other.Value, comparing) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// HashKeyOf returns the key obj is stored under in a Hash, and false if obj
+// cannot be a key. Arrays can be keys if all their elements can; they are
+// hashed by their contents, so equal arrays are the same key.
+func HashKeyOf(obj Object) (HashKey, bool) {
+	return hashKeyOf(obj, map[*Array]bool{})
+}
+
+func hashKeyOf(obj Object, visiting map[*Array]bool) (HashKey, bool) {
+	switch obj := obj.(type) {
+	case Hashable:
+		return obj.HashKey(), true
+	case *Array:
+		if visiting[obj] {
+			return HashKey{}, false
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		h := fnv.New64a()
+		var buf [8]byte
+		for _, element := range obj.Elements {
+			key, ok := hashKeyOf(element, visiting)
+			if !ok {
+				return HashKey{}, false
+			}
+			h.Write([]byte(key.Type))
+			binary.BigEndian.PutUint64(buf[:], key.Value)
+			h.Write(buf[:])
+		}
+		return HashKey{Type: ARRAY_OBJ, Value: h.Sum64()}, true
+	}
+
+	return HashKey{}, false
+}
+
+// copyKey returns a copy of the arrays in key, so that changing an array after
+// using it as a key does not change the Hash
+func copyKey(key Object) Object {
+	arr, ok := key.(*Array)
+	if !ok {
+		return key
+	}
+
+	elements := make([]Object, len(arr.Elements))
+	for i, element := range arr.Elements {
+		elements[i] = copyKey(element)
+	}
+	return &Array{Elements: elements}
+}
@@ -0,0 +1,101 @@
+package object
+
+import (
+	"context"
+	"time"
+
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+)
+
+// DEFAULT_MAX_CALL_DEPTH is how deeply programs may call functions when their
+// limits do not say. Deeper calls would exhaust the stack of the interpreter.
+const DEFAULT_MAX_CALL_DEPTH = 10_000
+
+// Limits bounds the resources a program may use. Zero fields are unlimited,
+// except MaxCallDepth, which is then DEFAULT_MAX_CALL_DEPTH.
+type Limits struct {
+	Timeout time.Duration
+	// the number of loop iterations and function calls the program may make
+	MaxSteps     int64
+	MaxCallDepth int
+	// the number of array elements and map pairs the program may create
+	MaxElements int64
+	// the number of bytes of strings the program may create
+	MaxStringBytes int64
+}
+
+// how many steps are taken between checks of the context, which are
+// comparatively expensive
+const contextCheckInterval = 256
+
+// Execution tracks the resources used by a running program against its
+// limits. Its methods return a LimitError once one is exceeded.
+type Execution struct {
+	ctx    context.Context
+	limits Limits
+
+	steps       int64
+	depth       int
+	elements    int64
+	stringBytes int64
+}
+
+func NewExecution(ctx context.Context, limits Limits) *Execution {
+	return &Execution{ctx: ctx, limits: limits}
+}
+
+// Step records a loop iteration or function call
+func (e *Execution) Step() *Error {
+	e.steps++
+	if e.limits.MaxSteps > 0 && e.steps > e.limits.MaxSteps {
+		return &Error{Conf: errors.StepLimitExceededError(e.limits.MaxSteps)}
+	}
+
+	if e.steps%contextCheckInterval == 0 {
+		return e.Err()
+	}
+	return nil
+}
+
+// Err returns an error if the context of the program is done
+func (e *Execution) Err() *Error {
+	if err := e.ctx.Err(); err != nil {
+		return &Error{Conf: errors.ExecutionCancelledError(err)}
+	}
+	return nil
+}
+
+// Enter records a call to a function, which must be followed by Leave once
+// it returns. Calls deeper than the limit are a LimitError, and calls deeper
+// than DEFAULT_MAX_CALL_DEPTH without one a stack overflow.
+func (e *Execution) Enter() *Error {
+	e.depth++
+	if e.limits.MaxCallDepth > 0 && e.depth > e.limits.MaxCallDepth {
+		e.depth--
+		return &Error{Conf: errors.CallDepthExceededError(e.limits.MaxCallDepth)}
+	}
+	if e.limits.MaxCallDepth <= 0 && e.depth > DEFAULT_MAX_CALL_DEPTH {
+		e.depth--
+		return &Error{Conf: errors.StackOverflowError()}
+	}
+	return e.Step()
+}
+
+func (e *Execution) Leave() {
+	e.depth--
+}
+
+// Allocate records the creation of elements array elements or map pairs, and
+// of stringBytes bytes of strings
+func (e *Execution) Allocate(elements, stringBytes int64) *Error {
+	e.elements += elements
+	if e.limits.MaxElements > 0 && e.elements > e.limits.MaxElements {
+		return &Error{Conf: errors.ElementLimitExceededError(e.limits.MaxElements)}
+	}
+
+	e.stringBytes += stringBytes
+	if e.limits.MaxStringBytes > 0 && e.stringBytes > e.limits.MaxStringBytes {
+		return &Error{Conf: errors.StringBytesLimitExceededError(e.limits.MaxStringBytes)}
+	}
+	return nil
+}
@@ -4,12 +4,30 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"path/filepath"
 	"strings"
 
 	"github.com/icheka/sonar-lang/sonar-lang/ast"
+	"github.com/icheka/sonar-lang/sonar-lang/code"
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/token"
 )
 
-type BuiltinFunction func(args ...Object) Object
+// Caller is the evaluation context builtins are called in. It lets them call
+// the functions they are given, such as the callback of map.
+type Caller interface {
+	// Call calls fn, a function or builtin, with args and returns its result
+	Call(fn Object, args ...Object) Object
+	// Allocate records that the builtin is about to create elements array
+	// elements or map pairs and stringBytes bytes of strings, and returns a
+	// LimitError if the program may not. Builtins that can create far more
+	// than they are given call it before they do; their results are then
+	// not counted again.
+	Allocate(elements, stringBytes int64) *Error
+}
+
+type BuiltinFunction func(caller Caller, args ...Object) Object
 
 type ObjectType string
 
@@ -24,11 +42,14 @@ const (
 
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
 
-	FUNCTION_OBJ = "FUNCTION"
-	BUILTIN_OBJ  = "BUILTIN"
+	FUNCTION_OBJ          = "FUNCTION"
+	BUILTIN_OBJ           = "BUILTIN"
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CELL_OBJ              = "CELL"
 
-	ARRAY_OBJ = "ARRAY"
-	HASH_OBJ  = "MAP"
+	ARRAY_OBJ  = "ARRAY"
+	HASH_OBJ   = "MAP"
+	MODULE_OBJ = "MODULE"
 
 	BREAK_OBJ    = "BREAK"
 	CONTINUE_OBJ = "CONTINUE"
@@ -46,6 +67,7 @@ var ObjectTypes map[ObjectType]bool = map[ObjectType]bool{
 	BUILTIN_OBJ:      true,
 	ARRAY_OBJ:        true,
 	HASH_OBJ:         true,
+	MODULE_OBJ:       true,
 	BREAK_OBJ:        true,
 }
 
@@ -84,7 +106,11 @@ type Float struct {
 func (f *Float) Type() ObjectType { return FLOAT_OBJ }
 func (f *Float) Inspect() string  { return fmt.Sprint(f.Value) }
 func (f *Float) HashKey() HashKey {
-	return HashKey{Type: f.Type(), Value: uint64(f.Value)}
+	// whole numbers are the same key as the integer they are equal to
+	if f.Value == math.Trunc(f.Value) && f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+		return HashKey{Type: INTEGER_OBJ, Value: uint64(int64(f.Value))}
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
 }
 
 type Boolean struct {
@@ -119,23 +145,92 @@ func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
 type Error struct {
 	Conf interface{}
+	// the value given to `throw`, unless it was a string or a map describing the error
+	Value Object
+	// the calls the error propagated out of, innermost first
+	Traceback []Frame
+	// errors raised along with Conf, such as the other syntax errors in a
+	// module that failed to parse
+	Related []errors.Error
+
+	// the position the next frame will be attributed to: where the error
+	// was raised, then the call site of each call it propagates out of
+	pending token.Position
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return ERROR_OBJ }
+func (e *Error) Inspect() string {
+	if conf, ok := e.Conf.(errors.Error); ok {
+		return conf.String()
+	}
+	return ERROR_OBJ
+}
+
+// MODULE_FRAME names the frame of code running outside of any function
+const MODULE_FRAME = "<module>"
+
+// ANONYMOUS_FUNCTION names functions that were never bound to a name
+const ANONYMOUS_FUNCTION = "<anonymous>"
+
+// Frame is one entry of a traceback: a function that was executing the
+// given line when an error propagated out of it
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// Unwind records that e propagated out of a call to the named function made
+// at callSite. Use MODULE_FRAME and the zero position for the top level.
+func (e *Error) Unwind(function string, callSite token.Position) {
+	pos := e.pending
+	if !pos.IsValid() && len(e.Traceback) == 0 {
+		pos = e.Position()
+	}
+
+	e.Traceback = append(e.Traceback, Frame{Function: function, File: pos.File, Line: pos.Line})
+	e.pending = callSite
+}
+
+// Position returns where the error was raised, if it is known
+func (e *Error) Position() token.Position {
+	conf, ok := e.Conf.(errors.Error)
+	if !ok {
+		return token.Position{}
+	}
+	return token.Position{File: conf.File, Line: conf.Line, Column: conf.Column}
+}
+
+// SetPosition locates an error that was raised without a position, such as
+// one returned by a builtin, at pos
+func (e *Error) SetPosition(pos token.Position) {
+	conf, ok := e.Conf.(errors.Error)
+	if !ok || conf.Line > 0 || !pos.IsValid() {
+		return
+	}
+
+	conf.File = pos.File
+	conf.Line = pos.Line
+	conf.Column = pos.Column
+	conf.LineTextTokenPosition = pos.Column - 1
+	e.Conf = conf
+}
 
 type Function struct {
+	Name       string // the name the function was first bound to, if any
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
-func (f *Function) Inspect() string {
+func (f *Function) Inspect() string  { return inspectFunction(f.Parameters, f.Body) }
+
+func inspectFunction(parameters []*ast.Identifier, body *ast.BlockStatement) string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range f.Parameters {
+	for _, p := range parameters {
 		params = append(params, p.String())
 	}
 
@@ -143,12 +238,62 @@ func (f *Function) Inspect() string {
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
-	out.WriteString(f.Body.String())
+	if body != nil {
+		out.WriteString(body.String())
+	}
 	out.WriteString("\n}")
 
 	return out.String()
 }
 
+// CompiledFunction is a function literal lowered to bytecode by package compiler.
+// Parameters and Body are kept so it inspects like its tree-walking counterpart.
+type CompiledFunction struct {
+	Name          string // the name the function was bound to by a let statement, if any
+	Instructions  code.Instructions
+	Positions     code.Positions
+	NumLocals     int
+	LocalNames    []string
+	NumParameters int
+	Parameters    []*ast.Identifier
+	Body          *ast.BlockStatement
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string  { return inspectFunction(cf.Parameters, cf.Body) }
+
+// Closure is the runtime value of a CompiledFunction together with the
+// cells of the variables it captured. It behaves as a FUNCTION to user code.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+	Unit *Unit // the program or module the function was defined in
+}
+
+// Unit is a program or module run by the VM. Its functions keep using its
+// constants and globals wherever they are called from.
+type Unit struct {
+	Constants   []Object
+	Globals     []Object
+	GlobalNames map[string]int // global slots by name, for late-bound lookups
+}
+
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+func (c *Closure) Inspect() string  { return c.Fn.Inspect() }
+
+// Cell holds a variable shared between a function and the closures that capture it.
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType { return CELL_OBJ }
+func (c *Cell) Inspect() string {
+	if c.Value == nil {
+		return "null"
+	}
+	return c.Value.Inspect()
+}
+
 type String struct {
 	Value string
 }
@@ -162,10 +307,12 @@ func (s *String) HashKey() HashKey {
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
 func (s *String) FormattedInspect() string { return fmt.Sprintf("'%s'", s.Value) }
+
+// Iters returns the characters (runes) of the string, each as a String
 func (s *String) Iters() []Object {
 	iters := []Object{}
-	for _, v := range strings.Split(s.Value, "") {
-		iters = append(iters, &String{Value: v})
+	for _, r := range s.Value {
+		iters = append(iters, &String{Value: string(r)})
 	}
 	return iters
 }
@@ -209,8 +356,58 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash is a map that remembers the order its keys were first set in. Pairs
+// may be read directly, but must only be changed through Set and Delete.
 type Hash struct {
 	Pairs map[HashKey]HashPair
+	Keys  []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+// Set adds or replaces the pair for key. A replaced pair keeps its place.
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	pair.Key = copyKey(pair.Key)
+	if h.Pairs == nil {
+		h.Pairs = make(map[HashKey]HashPair)
+	}
+	if _, ok := h.Pairs[key]; !ok {
+		h.Keys = append(h.Keys, key)
+	}
+	h.Pairs[key] = pair
+}
+
+func (h *Hash) Delete(key HashKey) {
+	if _, ok := h.Pairs[key]; !ok {
+		return
+	}
+	delete(h.Pairs, key)
+	for i, k := range h.Keys {
+		if k == key {
+			h.Keys = append(h.Keys[:i:i], h.Keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Ordered returns the pairs in the order their keys were first set
+func (h *Hash) Ordered() []HashPair {
+	pairs := make([]HashPair, len(h.Keys))
+	for i, key := range h.Keys {
+		pairs[i] = h.Pairs[key]
+	}
+	return pairs
+}
+
+// Copy returns a new Hash with the same pairs, in the same order
+func (h *Hash) Copy() *Hash {
+	hash := &Hash{Pairs: make(map[HashKey]HashPair, len(h.Pairs)), Keys: append([]HashKey{}, h.Keys...)}
+	for key, pair := range h.Pairs {
+		hash.Pairs[key] = pair
+	}
+	return hash
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
@@ -218,7 +415,7 @@ func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.Ordered() {
 		key := ""
 		value := ""
 
@@ -246,13 +443,30 @@ func (h *Hash) Inspect() string {
 func (h *Hash) Iters() []Object {
 	iters := []Object{}
 
-	for _, m := range h.Pairs {
+	for _, m := range h.Ordered() {
 		iters = append(iters, &Array{Elements: []Object{m.Key, m.Value}})
 	}
 
 	return iters
 }
 
+// Module is an imported file. Only the bindings it exported can be accessed,
+// by indexing the module with their names.
+type Module struct {
+	Name    string
+	Path    string // absolute path to the file
+	Exports map[string]Object
+}
+
+// ModuleName returns the name a module imported as importPath is bound to,
+// unless the import statement gives it another
+func ModuleName(importPath string) string {
+	return strings.TrimSuffix(filepath.Base(importPath), filepath.Ext(importPath))
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return fmt.Sprintf("<module %s>", m.Name) }
+
 type Break struct{}
 
 func (b *Break) Type() ObjectType { return BREAK_OBJ }
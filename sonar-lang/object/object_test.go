@@ -1,6 +1,10 @@
 package object
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -58,3 +62,76 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with twoerent content have same hash keys")
 	}
 }
+
+func TestHashKeepsInsertionOrder(t *testing.T) {
+	hash := NewHash()
+	for _, key := range []string{"c", "a", "b"} {
+		k := &String{Value: key}
+		hash.Set(k.HashKey(), HashPair{Key: k, Value: k})
+	}
+	a := &String{Value: "a"}
+	hash.Set(a.HashKey(), HashPair{Key: a, Value: &Integer{Value: 1}})
+	if hash.Inspect() != "{'c': 'c', 'a': 1, 'b': 'b'}" {
+		t.Errorf("wrong order after Set. got=%s", hash.Inspect())
+	}
+
+	hash.Delete(a.HashKey())
+	hash.Set(a.HashKey(), HashPair{Key: a, Value: a})
+	if hash.Inspect() != "{'c': 'c', 'b': 'b', 'a': 'a'}" {
+		t.Errorf("wrong order after Delete. got=%s", hash.Inspect())
+	}
+
+	copied := hash.Copy()
+	copied.Delete(a.HashKey())
+	if len(hash.Pairs) != 3 || len(hash.Keys) != 3 {
+		t.Errorf("deleting from a copy changed the original. got=%s", hash.Inspect())
+	}
+}
+
+func TestFloatHashKey(t *testing.T) {
+	if (&Float{Value: 1.1}).HashKey() == (&Float{Value: 1.9}).HashKey() {
+		t.Errorf("floats with different content have same hash keys")
+	}
+	if (&Float{Value: 2.5}).HashKey() != (&Float{Value: 2.5}).HashKey() {
+		t.Errorf("floats with same content have different hash keys")
+	}
+	if (&Float{Value: 2}).HashKey() != (&Integer{Value: 2}).HashKey() {
+		t.Errorf("a whole float and the equal integer have different hash keys")
+	}
+	if (&Float{Value: -0.0}).HashKey() != (&Float{Value: 0}).HashKey() {
+		t.Errorf("-0.0 and 0.0 have different hash keys")
+	}
+}
+
+func TestArrayHashKey(t *testing.T) {
+	a := &Array{Elements: []Object{&Integer{Value: 1}, &Array{Elements: []Object{&String{Value: "a"}}}}}
+	b := &Array{Elements: []Object{&Float{Value: 1}, &Array{Elements: []Object{&String{Value: "a"}}}}}
+	c := &Array{Elements: []Object{&Array{Elements: []Object{&String{Value: "a"}}}, &Integer{Value: 1}}}
+
+	keyA, ok := HashKeyOf(a)
+	if !ok {
+		t.Fatalf("array of hashable elements is not hashable")
+	}
+	if keyB, _ := HashKeyOf(b); keyA != keyB {
+		t.Errorf("equal arrays have different hash keys")
+	}
+	if keyC, _ := HashKeyOf(c); keyA == keyC {
+		t.Errorf("arrays with different content have same hash keys")
+	}
+
+	if _, ok := HashKeyOf(&Array{Elements: []Object{NewHash()}}); ok {
+		t.Errorf("array containing a map is hashable")
+	}
+	cyclic := &Array{}
+	cyclic.Elements = []Object{cyclic}
+	if _, ok := HashKeyOf(cyclic); ok {
+		t.Errorf("array containing itself is hashable")
+	}
+}
+
+func TestErrorInspect(t *testing.T) {
+	err := &Error{Conf: errors.NewRuntimeError("division by zero")}
+	if err.Inspect() != "RuntimeError: division by zero" {
+		t.Errorf("err.Inspect() wrong. got=%q", err.Inspect())
+	}
+}
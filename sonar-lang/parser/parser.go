@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"sort"
 	"strconv"
 
 	"github.com/icheka/sonar-lang/sonar-lang/ast"
@@ -25,15 +26,16 @@ const (
 )
 
 var precedences = map[token.TokenType]int{
-	token.ASSIGN: ASSIGN,
-	token.EQ:     EQUALS,
-	token.NOT_EQ: EQUALS,
-	token.LT:     LESSGREATER,
-	token.GT:     LESSGREATER,
-	token.LTE:    LESSGREATER,
-	token.GTE:    LESSGREATER,
-	token.AND:    CONNECTIVE,
-	token.OR:     CONNECTIVE,
+	token.ASSIGN:  ASSIGN,
+	token.EQ:      EQUALS,
+	token.NOT_EQ:  EQUALS,
+	token.LT:      LESSGREATER,
+	token.GT:      LESSGREATER,
+	token.LTE:     LESSGREATER,
+	token.GTE:     LESSGREATER,
+	token.AND:     CONNECTIVE,
+	token.OR:      CONNECTIVE,
+	token.NULLISH: CONNECTIVE,
 
 	token.PLUS:         SUM,
 	token.MINUS:        SUM,
@@ -49,6 +51,9 @@ var precedences = map[token.TokenType]int{
 
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.FULLSTOP: INDEX,
+
+	token.OPTIONAL_CHAIN: INDEX,
 }
 
 type (
@@ -60,6 +65,13 @@ type (
 type Parser struct {
 	l      *lexer.Lexer
 	errors []errors.Error
+	// set when an error is reported and cleared once the parser has skipped
+	// past the statement it occurred in
+	recovering bool
+	// how many blocks deep the current token is
+	depth int
+	// how many loops the current token is in, within the innermost function
+	loops int
 
 	curToken  token.Token
 	peekToken token.Token
@@ -81,10 +93,12 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
 	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.TEMPLATE_HEAD, p.parseTemplateString)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.NULL, p.parseNull)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
@@ -102,8 +116,9 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LTE, p.parseInfixExpression)
 	p.registerInfix(token.GTE, p.parseInfixExpression)
-	p.registerInfix(token.AND, p.parseInfixExpression)
-	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseLogicalExpression)
+	p.registerInfix(token.OR, p.parseLogicalExpression)
+	p.registerInfix(token.NULLISH, p.parseLogicalExpression)
 	p.registerInfix(token.IN, p.parseInfixExpression)
 
 	p.registerInfix(token.ASSIGN, p.parseAssignmentExpression)
@@ -114,6 +129,8 @@ func New(l *lexer.Lexer) *Parser {
 
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.FULLSTOP, p.parseMemberExpression)
+	p.registerInfix(token.OPTIONAL_CHAIN, p.parseOptionalChain)
 
 	p.postfixParseFns = make(map[token.TokenType]postfixParseFn)
 	p.registerPostfix(token.POST_INCR, p.parsePostfixExpression)
@@ -150,83 +167,107 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
-func (p *Parser) Errors() []errors.Error {
-	return p.errors
+// peekWordIs reports whether the next token is the contextual keyword word
+func (p *Parser) peekWordIs(word string) bool {
+	return p.peekTokenIs(token.IDENT) && p.peekToken.Literal == word
 }
 
-func (p *Parser) findPrevNewline() int {
-	input := (*p).l.Input()
-	pos := (*p).l.Position() - 2
-	found := false
-
-	for !found && pos > 0 && pos < p.l.InputLength-1 {
-		pos--
-		if input[pos] == '\n' {
-			found = true
-		}
+// expectPeekWord is expectPeek for contextual keywords
+func (p *Parser) expectPeekWord(word string) bool {
+	if p.peekWordIs(word) {
+		p.nextToken()
+		return true
 	}
+	p.peekError(token.TokenType(word))
+	return false
+}
 
-	return pos
+func (p *Parser) Errors() []errors.Error {
+	return p.errors
 }
 
-func (p *Parser) findNextNewline() int {
-	input := (*p).l.Input()
-	pos := (*p).l.Position() - 2
-	found := false
+// addError records err, unless it was reported at the same position as the
+// previous error; the second is then only a consequence of the first
+func (p *Parser) addError(err errors.Error) {
+	p.recovering = true
 
-	for !found && pos >= 0 && pos < p.l.InputLength-1 {
-		pos++
-		if input[pos] == '\n' {
-			found = true
+	if len(p.errors) > 0 {
+		last := p.errors[len(p.errors)-1]
+		if last.Line == err.Line && last.Column == err.Column && last.LineTextTokenPosition == err.LineTextTokenPosition {
+			return
 		}
 	}
-
-	return pos + 1
+	p.errors = append(p.errors, err)
 }
 
-func (p *Parser) getErrorConfig() errors.ErrorConfig {
-	end := (*p).findNextNewline()   // index to stop returning LineText at
-	start := (*p).findPrevNewline() // index to start returning LineText from
-	if end > (*p).l.InputLength {
-		end = (*p).l.InputLength
-	}
-	if end < 0 {
-		end = 0
-	}
-	if start > end {
-		start = end
-	}
-	if start < 0 {
-		start = 0
-	}
+// synchronize skips the remaining tokens of a statement that failed to parse,
+// stopping at the last token before the next statement: a semicolon, a token
+// followed by a statement keyword, or (inside a block) the token before the
+// block's closing brace. Braces opened while skipping are skipped as a whole.
+func (p *Parser) synchronize(inBlock bool) {
+	p.recovering = false
+	depth := 0
+
+	for !p.curTokenIs(token.EOF) && !p.peekTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth > 0 {
+				depth--
+			}
+		case token.SEMICOLON:
+			if depth == 0 {
+				return
+			}
+		}
 
-	textPos := p.l.Position() - start
-	lineText := p.l.LineText(start, end)
+		if depth == 0 {
+			if p.peekToken.Span.Start.Line > p.curToken.Span.End.Line {
+				return
+			}
 
-	if len(lineText) > 0 {
-		for lineText[len(lineText)-1] == '\n' || lineText[len(lineText)-1] == ' ' || lineText[len(lineText)-1] == '\t' || lineText[len(lineText)-1] == '\r' {
-			lineText = lineText[0 : len(lineText)-1]
-			if len(lineText) == 0 {
-				break
+			switch p.peekToken.Type {
+			case token.LET, token.CONST, token.RETURN, token.FOR, token.WHILE, token.BREAK, token.CONTINUE, token.THROW, token.TRY, token.IMPORT, token.EXPORT:
+				return
+			case token.RBRACE:
+				if inBlock {
+					return
+				}
 			}
 		}
+
+		p.nextToken()
 	}
+}
 
-	return errors.ErrorConfig{
-		File:                  p.l.File,
-		Line:                  p.l.Line,
-		Column:                p.l.Column,
-		LineText:              lineText,
-		LineTextTokenPosition: textPos,
+// getErrorConfig returns an ErrorConfig locating an error at tok
+func (p *Parser) getErrorConfig(tok token.Token) errors.ErrorConfig {
+	conf := errors.NewErrorConfig(tok.Span.Start)
+	conf.LineText = errors.LineText(p.l.Input(), tok.Span.Start.Line)
+	return conf
+}
+
+// spanFrom returns the span from the start of tok to the end of the current token
+func (p *Parser) spanFrom(tok token.Token) token.Span {
+	return tok.Span.To(p.curToken.Span)
+}
+
+// spanFromNode returns the span from the start of node to the end of the
+// current token, falling back to tok if node could not be parsed
+func (p *Parser) spanFromNode(node ast.Node, tok token.Token) token.Span {
+	if node == nil {
+		return p.spanFrom(tok)
 	}
+	return node.Span().To(p.curToken.Span)
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	p.errors = append(p.errors, errors.PeekError(t, p.peekToken.Literal, p.getErrorConfig()))
+	p.addError(errors.PeekError(t, p.peekToken.Literal, p.getErrorConfig(p.peekToken)))
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType, s string) {
-	p.errors = append(p.errors, errors.NoPrefixParseFnError(s, t, p.getErrorConfig()))
+	p.addError(errors.NoPrefixParseFnError(s, t, p.getErrorConfig(p.curToken)))
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -235,18 +276,29 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 	for !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if p.recovering {
+			p.synchronize(false)
+		} else if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
 	}
 
+	// errors in string literals, reported in order with the parser's
+	if lexErrors := p.l.Errors(); len(lexErrors) > 0 {
+		p.errors = append(p.errors, lexErrors...)
+		sort.SliceStable(p.errors, func(i, j int) bool {
+			a, b := p.errors[i], p.errors[j]
+			return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+		})
+	}
+
 	return program
 }
 
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
-	case token.LET:
+	case token.LET, token.CONST:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
@@ -258,27 +310,191 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseBreakStatement()
 	case token.CONTINUE:
 		return p.parseContinueStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.TRY:
+		return p.parseTryStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
 func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
-	return &ast.ContinueStatement{Token: p.curToken}
+	p.expectLoop()
+	return &ast.ContinueStatement{Token: p.curToken, SourceSpan: p.curToken.Span}
 }
 
 func (p *Parser) parseBreakStatement() *ast.BreakStatement {
-	return &ast.BreakStatement{Token: p.curToken}
+	p.expectLoop()
+	return &ast.BreakStatement{Token: p.curToken, SourceSpan: p.curToken.Span}
+}
+
+// expectLoop reports the current token, a break or continue, unless it is in
+// a loop
+func (p *Parser) expectLoop() {
+	if p.loops == 0 {
+		p.addError(errors.IllegalStatementOutsideLoopError(p.curToken.Literal, p.getErrorConfig(p.curToken)))
+	}
+}
+
+// parseLoopBody parses the block of a loop, in which break and continue may
+// be used
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loops++
+	defer func() { p.loops-- }()
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	stmt.SourceSpan = p.spanFrom(stmt.Token)
+
+	return stmt
+}
+
+func (p *Parser) parseTryStatement() *ast.TryStatement {
+	stmt := &ast.TryStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			stmt.Parameter = p.parseIdentifier().(*ast.Identifier)
+			if !p.expectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		stmt.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) || stmt.Catch == nil {
+		if !p.expectPeek(token.FINALLY) {
+			return nil
+		}
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		stmt.Finally = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	stmt.SourceSpan = p.spanFrom(stmt.Token)
+
+	return stmt
+}
+
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+
+	if p.depth > 0 {
+		p.addError(errors.StatementNotAtTopLevelError(stmt.TokenLiteral(), p.getErrorConfig(stmt.Token)))
+		return nil
+	}
+
+	if p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stmt.Names = p.parseImportNames()
+		if stmt.Names == nil || !p.expectPeekWord(token.FROM) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	stmt.Path = p.parseStringLiteral().(*ast.StringLiteral)
+
+	if stmt.Names == nil && p.peekWordIs(token.AS) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Alias = p.parseIdentifier().(*ast.Identifier)
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	stmt.SourceSpan = p.spanFrom(stmt.Token)
+
+	return stmt
+}
+
+// parseImportNames parses the names between the braces of a named import
+func (p *Parser) parseImportNames() []*ast.Identifier {
+	names := []*ast.Identifier{}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		names = append(names, p.parseIdentifier().(*ast.Identifier))
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+
+	return names
+}
+
+func (p *Parser) parseExportStatement() *ast.ExportStatement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+
+	if p.depth > 0 {
+		p.addError(errors.StatementNotAtTopLevelError(stmt.TokenLiteral(), p.getErrorConfig(stmt.Token)))
+		return nil
+	}
+
+	if p.peekTokenIs(token.CONST) {
+		p.nextToken()
+	} else if !p.expectPeek(token.LET) {
+		return nil
+	}
+	stmt.Statement = p.parseLetStatement()
+	if stmt.Statement == nil {
+		return nil
+	}
+	stmt.SourceSpan = p.spanFrom(stmt.Token)
+
+	return stmt
 }
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
-	stmt := &ast.LetStatement{Token: p.curToken}
+	stmt := &ast.LetStatement{Token: p.curToken, Constant: p.curTokenIs(token.CONST)}
 
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, SourceSpan: p.curToken.Span}
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -287,11 +503,11 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	p.nextToken()
 
 	stmt.Value = p.parseExpression(LOWEST)
-	stmt.TokenInfo = p.getErrorConfig()
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
+	stmt.SourceSpan = p.spanFrom(stmt.Token)
 
 	return stmt
 }
@@ -299,17 +515,16 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
-	p.nextToken()
-
-	if p.curToken.Type != token.SEMICOLON && p.curToken.Type != token.RBRACE {
+	// a bare return leaves the closing brace of its block to the block
+	if !p.peekTokenIs(token.SEMICOLON) && !p.peekTokenIs(token.RBRACE) && !p.peekTokenIs(token.EOF) {
+		p.nextToken()
 		stmt.ReturnValue = p.parseExpression(LOWEST)
-	} else {
-		stmt.ReturnValue = nil
 	}
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
+	stmt.SourceSpan = p.spanFrom(stmt.Token)
 
 	return stmt
 }
@@ -322,6 +537,7 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
+	stmt.SourceSpan = p.spanFrom(stmt.Token)
 
 	return stmt
 }
@@ -353,32 +569,33 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	return leftExp
 }
 
-func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
+// Precedence returns how tightly an infix, call or member operator binds
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
 		return p
 	}
 
 	return LOWEST
 }
 
-func (p *Parser) curPrecedence() int {
-	if p, ok := precedences[p.curToken.Type]; ok {
-		return p
-	}
+func (p *Parser) peekPrecedence() int {
+	return Precedence(p.peekToken.Type)
+}
 
-	return LOWEST
+func (p *Parser) curPrecedence() int {
+	return Precedence(p.curToken.Type)
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, SourceSpan: p.curToken.Span}
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
-	lit := &ast.IntegerLiteral{Token: p.curToken}
+	lit := &ast.IntegerLiteral{Token: p.curToken, SourceSpan: p.curToken.Span}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		p.errors = append(p.errors, errors.CouldNotParseAsIntegerError(p.curToken.Literal, p.getErrorConfig()))
+		p.addError(errors.CouldNotParseAsIntegerError(p.curToken.Literal, p.getErrorConfig(p.curToken)))
 		return nil
 	}
 
@@ -388,19 +605,46 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 }
 
 func (p *Parser) parseFloatLiteral() ast.Expression {
-	lit := &ast.FloatLiteral{Token: p.curToken}
+	lit := &ast.FloatLiteral{Token: p.curToken, SourceSpan: p.curToken.Span}
 
 	if value, err := strconv.ParseFloat(p.curToken.Literal, 64); err == nil {
 		lit.Value = value
 		return lit
 	}
 
-	p.errors = append(p.errors, errors.CouldNotParseAsFloatError(p.curToken.Literal, p.getErrorConfig()))
+	p.addError(errors.CouldNotParseAsFloatError(p.curToken.Literal, p.getErrorConfig(p.curToken)))
 	return nil
 }
 
 func (p *Parser) parseStringLiteral() ast.Expression {
-	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal, SourceSpan: p.curToken.Span}
+}
+
+// parseTemplateString parses a string with interpolations into a
+// ConcatExpression of its text and the interpolated expressions
+func (p *Parser) parseTemplateString() ast.Expression {
+	concat := &ast.ConcatExpression{Token: p.curToken}
+
+	for {
+		if len(p.curToken.Literal) > 0 {
+			concat.Parts = append(concat.Parts, p.parseStringLiteral())
+		}
+		if p.curTokenIs(token.TEMPLATE_TAIL) {
+			break
+		}
+
+		p.nextToken()
+		concat.Parts = append(concat.Parts, p.parseExpression(LOWEST))
+
+		if !p.peekTokenIs(token.TEMPLATE_MIDDLE) && !p.peekTokenIs(token.TEMPLATE_TAIL) {
+			p.peekError(token.RBRACE)
+			return nil
+		}
+		p.nextToken()
+	}
+
+	concat.SourceSpan = p.spanFrom(concat.Token)
+	return concat
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
@@ -412,6 +656,7 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	p.nextToken()
 
 	expression.Right = p.parseExpression(PREFIX)
+	expression.SourceSpan = p.spanFrom(expression.Token)
 
 	return expression
 }
@@ -426,19 +671,40 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	precedence := p.curPrecedence()
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
+	expression.SourceSpan = p.spanFromNode(left, expression.Token)
+
+	return expression
+}
+
+func (p *Parser) parseLogicalExpression(left ast.Expression) ast.Expression {
+	expression := &ast.LogicalExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Left:     left,
+	}
+
+	precedence := p.curPrecedence()
+	p.nextToken()
+	expression.Right = p.parseExpression(precedence)
+	expression.SourceSpan = p.spanFromNode(left, expression.Token)
 
 	return expression
 }
 
 func (p *Parser) parsePostfixExpression() ast.Expression {
 	return &ast.PostfixExpression{
-		Token:    p.prevToken,
-		Operator: p.curToken.Literal,
+		Token:      p.prevToken,
+		Operator:   p.curToken.Literal,
+		SourceSpan: p.spanFrom(p.prevToken),
 	}
 }
 
 func (p *Parser) parseBoolean() ast.Expression {
-	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
+	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE), SourceSpan: p.curToken.Span}
+}
+
+func (p *Parser) parseNull() ast.Expression {
+	return &ast.NullValueExpression{SourceSpan: p.curToken.Span}
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
@@ -450,6 +716,14 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 		return nil
 	}
 
+	// parentheses end optional chains, so mark the links that can be in one
+	switch exp := exp.(type) {
+	case *ast.IndexExpression:
+		exp.Grouped = true
+	case *ast.CallExpression:
+		exp.Grouped = true
+	}
+
 	return exp
 }
 
@@ -482,6 +756,7 @@ func (p *Parser) parseIfExpression() ast.Expression {
 
 		expression.Alternative = p.parseBlockStatement()
 	}
+	expression.SourceSpan = p.spanFrom(expression.Token)
 
 	return expression
 }
@@ -491,14 +766,19 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block.Statements = []ast.Statement{}
 
 	p.nextToken()
+	p.depth++
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
-		if stmt != nil {
+		if p.recovering {
+			p.synchronize(true)
+		} else if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	p.depth--
+	block.SourceSpan = p.spanFrom(block.Token)
 
 	return block
 }
@@ -516,7 +796,12 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
+	// the loops the function is in cannot be broken out of from its body
+	loops := p.loops
+	p.loops = 0
 	lit.Body = p.parseBlockStatement()
+	p.loops = loops
+	lit.SourceSpan = p.spanFrom(lit.Token)
 
 	return lit
 }
@@ -531,14 +816,12 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 
 	p.nextToken()
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	identifiers = append(identifiers, p.parseIdentifier().(*ast.Identifier))
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		identifiers = append(identifiers, p.parseIdentifier().(*ast.Identifier))
 	}
 
 	if !p.expectPeek(token.RPAREN) {
@@ -551,6 +834,7 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.SourceSpan = p.spanFromNode(function, exp.Token)
 	return exp
 }
 
@@ -582,6 +866,7 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.SourceSpan = p.spanFrom(array.Token)
 
 	return array
 }
@@ -601,18 +886,76 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 		return &ast.SquareBracketAssignment{
-			Token: exp.Token,
-			Value: value,
-			Key:   exp.Index,
-			Left:  left,
+			Token:      exp.Token,
+			Value:      value,
+			Key:        exp.Index,
+			Left:       left,
+			SourceSpan: p.spanFromNode(left, exp.Token),
 		}
 	}
+	exp.SourceSpan = p.spanFromNode(left, exp.Token)
 	return exp
 }
 
+// parseMemberExpression parses left.name, which is short for left["name"]
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	tok := p.curToken
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	name := &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal, SourceSpan: p.curToken.Span}
+
+	if p.peekToken.Type == token.ASSIGN {
+		p.nextToken()
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		return &ast.SquareBracketAssignment{
+			Token:      tok,
+			Value:      value,
+			Key:        name,
+			Left:       left,
+			SourceSpan: p.spanFromNode(left, tok),
+		}
+	}
+	return &ast.IndexExpression{Token: tok, Left: left, Index: name, SourceSpan: p.spanFromNode(left, tok)}
+}
+
+// parseOptionalChain parses left?.[index], left?.(arguments) and left?.name,
+// which are null if left is, as are the index expressions and calls chained
+// after them up to the end of any parentheses around them
+func (p *Parser) parseOptionalChain(left ast.Expression) ast.Expression {
+	tok := p.curToken
+
+	switch p.peekToken.Type {
+	case token.LBRACKET:
+		p.nextToken()
+		exp := &ast.IndexExpression{Token: p.curToken, Left: left, Optional: true}
+		p.nextToken()
+		exp.Index = p.parseExpression(LOWEST)
+		if !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		exp.SourceSpan = p.spanFromNode(left, exp.Token)
+		return exp
+
+	case token.LPAREN:
+		p.nextToken()
+		exp := &ast.CallExpression{Token: p.curToken, Function: left, Optional: true}
+		exp.Arguments = p.parseExpressionList(token.RPAREN)
+		exp.SourceSpan = p.spanFromNode(left, exp.Token)
+		return exp
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	name := &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal, SourceSpan: p.curToken.Span}
+	return &ast.IndexExpression{Token: tok, Left: left, Index: name, Optional: true, SourceSpan: p.spanFromNode(left, tok)}
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
-	hash.Pairs = make(map[ast.Expression]ast.Expression)
+	hash.Pairs = []ast.HashLiteralPair{}
 
 	for !p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
@@ -625,7 +968,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 
-		hash.Pairs[key] = value
+		hash.Pairs = append(hash.Pairs, ast.HashLiteralPair{Key: key, Value: value})
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -635,6 +978,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.SourceSpan = p.spanFrom(hash.Token)
 
 	return hash
 }
@@ -669,7 +1013,8 @@ func (p *Parser) parseWhileStatement() ast.Statement {
 		return nil
 	}
 
-	stmt.Consequence = p.parseBlockStatement()
+	stmt.Consequence = p.parseLoopBody()
+	stmt.SourceSpan = p.spanFrom(stmt.Token)
 
 	return stmt
 }
@@ -683,6 +1028,7 @@ func (p *Parser) parseForStatement() ast.Statement {
 
 	p.nextToken() // advance to token immediately after '('
 	if !p.curTokenIs(token.IDENT) {
+		p.addError(errors.PeekError(token.IDENT, p.curToken.Literal, p.getErrorConfig(p.curToken)))
 		return nil
 	}
 	stmt.Counter = p.parseIdentifier()
@@ -713,10 +1059,11 @@ func (p *Parser) parseForStatement() ast.Statement {
 		return nil
 	}
 
-	stmt.Consequence = p.parseBlockStatement()
+	stmt.Consequence = p.parseLoopBody()
 	if len(stmt.Consequence.Statements) == 0 {
 		return nil
 	}
+	stmt.SourceSpan = p.spanFrom(stmt.Token)
 
 	return stmt
 }
@@ -725,7 +1072,7 @@ func (p *Parser) parseAssignmentExpression(left ast.Expression) ast.Expression {
 	exp := &ast.AssignmentExpression{Token: p.curToken}
 	identifier, ok := left.(*ast.Identifier)
 	if !ok {
-		p.errors = append(p.errors, errors.ExpectedIdentifierInAssignmentError(left.TokenLiteral(), p.getErrorConfig()))
+		p.addError(errors.ExpectedIdentifierInAssignmentError(left.TokenLiteral(), p.getErrorConfig(p.curToken)))
 	}
 
 	exp.Identifier = identifier
@@ -733,5 +1080,6 @@ func (p *Parser) parseAssignmentExpression(left ast.Expression) ast.Expression {
 
 	p.nextToken() // advance to right side of assignment expression
 	exp.Value = p.parseExpression(LOWEST)
+	exp.SourceSpan = p.spanFromNode(left, exp.Token)
 	return exp
 }
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/icheka/sonar-lang/sonar-lang/ast"
@@ -10,7 +11,7 @@ import (
 )
 
 func TestContinueKeyword(t *testing.T) {
-	input := "continue"
+	input := "while (true) { continue }"
 
 	l := lexer.New(input, nil)
 	p := New(l)
@@ -22,14 +23,15 @@ func TestContinueKeyword(t *testing.T) {
 			1, len(program.Statements))
 	}
 
-	if _, ok := program.Statements[0].(*ast.ContinueStatement); !ok {
-		t.Fatalf("program.Statements[0] is not ast.ContinueStatement. got=%T",
-			program.Statements[0])
+	body := program.Statements[0].(*ast.WhileStatement).Consequence
+	if _, ok := body.Statements[0].(*ast.ContinueStatement); !ok {
+		t.Fatalf("body.Statements[0] is not ast.ContinueStatement. got=%T",
+			body.Statements[0])
 	}
 }
 
 func TestBreakKeyword(t *testing.T) {
-	input := "break"
+	input := "for (i, v in y) { if (v) { break } }"
 
 	l := lexer.New(input, nil)
 	p := New(l)
@@ -41,9 +43,27 @@ func TestBreakKeyword(t *testing.T) {
 			1, len(program.Statements))
 	}
 
-	if _, ok := program.Statements[0].(*ast.BreakStatement); !ok {
-		t.Fatalf("program.Statements[0] is not ast.BreakStatement. got=%T",
-			program.Statements[0])
+	body := program.Statements[0].(*ast.ForStatement).Consequence
+	consequence := body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression).Consequence
+	if _, ok := consequence.Statements[0].(*ast.BreakStatement); !ok {
+		t.Fatalf("consequence.Statements[0] is not ast.BreakStatement. got=%T",
+			consequence.Statements[0])
+	}
+}
+
+func TestBreakOutsideLoop(t *testing.T) {
+	tests := []string{
+		"break",
+		"if (true) { continue }",
+		"while (true) { let f = func() { break } }",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input, nil))
+		p.ParseProgram()
+		if len(p.Errors()) != 1 || !strings.Contains(p.Errors()[0].Message, "outside of a loop") {
+			t.Errorf("%s: expected a break outside of a loop to be an error, got=%v", input, p.Errors())
+		}
 	}
 }
 
@@ -102,9 +122,10 @@ func TestArraySquareBracketAssignmentExpression(t *testing.T) {
 	tests := []struct {
 		input    string
 		expected interface{}
-		key      int
+		key      interface{}
 	}{
 		{`[1,2,3][0] = 2`, 2, 0},
+		{`a.b = 2`, 2, "b"},
 	}
 
 	for _, tt := range tests {
@@ -124,7 +145,7 @@ func TestArraySquareBracketAssignmentExpression(t *testing.T) {
 			t.Fatalf("expected exp.Expression to be *ast.ArraySquareBracketAssignment, got=%T", exp.Expression)
 		}
 
-		if arr.Key.String() != "0" {
+		if arr.Key.String() != fmt.Sprint(tt.key) {
 			t.Fatalf("expected arr.Key to be %T, got=%T", tt.key, arr.Key)
 		}
 		if arr.Value.String() != fmt.Sprint(tt.expected) {
@@ -214,6 +235,8 @@ func TestLetStatements(t *testing.T) {
 		{"let x = 5;", "x", 5},
 		{"let y = true;", "y", true},
 		{"let foobar = y;", "foobar", "y"},
+		{"let from = 1;", "from", 1},
+		{"let as = from;", "as", "from"},
 	}
 
 	for _, tt := range tests {
@@ -239,6 +262,29 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestConstStatements(t *testing.T) {
+	for _, input := range []string{"const x = 5;", "export const x = 5;"} {
+		p := New(lexer.New(input, nil))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0]
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			stmt = export.Statement
+		}
+		letStmt, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("%s: stmt is not *ast.LetStatement. got=%T", input, stmt)
+		}
+		if !letStmt.Constant || letStmt.Name.Value != "x" {
+			t.Errorf("%s: expected a constant named x, got=%+v", input, letStmt)
+		}
+		if letStmt.String() != "const x = 5;" {
+			t.Errorf("%s: letStmt.String() wrong. got=%q", input, letStmt.String())
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -275,6 +321,31 @@ func TestReturnStatements(t *testing.T) {
 	}
 }
 
+func TestBareReturnStatements(t *testing.T) {
+	tests := []string{"return", "return;", "func() { return }; x", "func() { return; }; x"}
+
+	for _, input := range tests {
+		l := lexer.New(input, nil)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		var returnStmt *ast.ReturnStatement
+		ast.Inspect(program, func(n ast.Node) bool {
+			if stmt, ok := n.(*ast.ReturnStatement); ok {
+				returnStmt = stmt
+			}
+			return true
+		})
+		if returnStmt == nil || returnStmt.ReturnValue != nil {
+			t.Errorf("%s: expected a return without a value, got=%+v", input, returnStmt)
+		}
+		if strings.Contains(input, "x") && len(program.Statements) != 2 {
+			t.Errorf("%s: expected the block to end at its brace, got %d statements", input, len(program.Statements))
+		}
+	}
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	input := "foobar;"
 
@@ -572,6 +643,10 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"a.b.c(d)[0] + e",
+			"((((a.b).c)(d)[0]) + e)",
+		},
 		{
 			"a * [1, 2, 3, 4][b * c] * d",
 			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
@@ -759,7 +834,7 @@ func TestLogicalAndOperator(t *testing.T) {
 			program.Statements[0])
 	}
 
-	if !testInfixExpression(t, stmt.Expression, "x", "and", "y") {
+	if !testLogicalExpression(t, stmt.Expression, "x", "and", "y") {
 		return
 	}
 }
@@ -783,11 +858,68 @@ func TestLogicalOrOperator(t *testing.T) {
 			program.Statements[0])
 	}
 
-	if !testInfixExpression(t, stmt.Expression, "x", "or", "y") {
+	if !testLogicalExpression(t, stmt.Expression, "x", "or", "y") {
 		return
 	}
 }
 
+func TestLogicalNullishOperator(t *testing.T) {
+	input := `x ?? y`
+
+	l := lexer.New(input, nil)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	if !testLogicalExpression(t, stmt.Expression, "x", "??", "y") {
+		return
+	}
+}
+
+func TestNullAndOptionalChaining(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = null", "let x = null;"},
+		{"x == null", "(x == null)"},
+		{"a ?? b ?? c", "((a ?? b) ?? c)"},
+		{"a ?? b or c", "((a ?? b) or c)"},
+		{"a == b ?? c", "((a == b) ?? c)"},
+		{"a?.[0]", "(a?.[0])"},
+		{"a?.b.c", "((a?.b).c)"},
+		{"f?.(1, 2)", "f?.(1, 2)"},
+		{"a?.[0]?.(1)?.b ?? 2", "(((a?.[0])?.(1)?.b) ?? 2)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input, nil)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+
+	for _, input := range []string{"a?.", "a?.1", "a?.[0"} {
+		p := New(lexer.New(input, nil))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("%s: expected a parse error", input)
+		}
+	}
+
+	// parentheses are kept in the tree, as they end the chain
+	program := New(lexer.New("(a?.b).c", nil)).ParseProgram()
+	exp := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression)
+	if exp.Grouped || !ast.Grouped(exp.Left) {
+		t.Errorf("expected only a?.b to be grouped, got=%+v", exp)
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `func(x, y) { x + y; }`
 
@@ -976,6 +1108,72 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestTemplateStringParsing(t *testing.T) {
+	input := `"Hello ${name}, you have ${len(items) + 1} items"`
+
+	l := lexer.New(input, nil)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	concat, ok := stmt.Expression.(*ast.ConcatExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.ConcatExpression. got=%T", stmt.Expression)
+	}
+
+	if len(concat.Parts) != 5 {
+		t.Fatalf("concat.Parts does not contain 5 parts. got=%d", len(concat.Parts))
+	}
+	for i, expected := range map[int]string{0: "Hello ", 2: ", you have ", 4: " items"} {
+		str, ok := concat.Parts[i].(*ast.StringLiteral)
+		if !ok || str.Value != expected {
+			t.Errorf("concat.Parts[%d] is not %q. got=%T(%s)", i, expected, concat.Parts[i], concat.Parts[i])
+		}
+	}
+	testIdentifier(t, concat.Parts[1], "name")
+
+	if concat.Parts[3].String() != "(len(items) + 1)" {
+		t.Errorf("concat.Parts[3] wrong. got=%q", concat.Parts[3].String())
+	}
+	if concat.String() != `"Hello ${name}, you have ${(len(items) + 1)} items"` {
+		t.Errorf("concat.String() wrong. got=%q", concat.String())
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		// the quote starts another string
+		{`"a ${x"`, []string{
+			"Illegal or unexpected token. Expected token to be '}', got ''.",
+			"Unterminated string literal",
+		}},
+		{"let a = \"\\q\";\nlet b = ;", []string{
+			"Invalid escape sequence '\\q' in string",
+			"Illegal or unexpected token ';'.",
+		}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input, nil))
+		p.ParseProgram()
+
+		errs := p.Errors()
+		if len(errs) != len(tt.expected) {
+			t.Errorf("%s: expected %d errors, got=%+v", tt.input, len(tt.expected), errs)
+			continue
+		}
+		for i, msg := range tt.expected {
+			if errs[i].Message != msg {
+				t.Errorf("%s: expected %q, got=%q", tt.input, msg, errs[i].Message)
+			}
+		}
+	}
+}
+
 func TestParsingEmptyArrayLiterals(t *testing.T) {
 	input := "[]"
 
@@ -1088,7 +1286,8 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		literal, ok := key.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("key is not ast.StringLiteral. got=%T", key)
@@ -1123,7 +1322,8 @@ func TestParsingHashLiteralsBooleanKeys(t *testing.T) {
 		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		boolean, ok := key.(*ast.Boolean)
 		if !ok {
 			t.Errorf("key is not ast.BooleanLiteral. got=%T", key)
@@ -1159,7 +1359,8 @@ func TestParsingHashLiteralsIntegerKeys(t *testing.T) {
 		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		integer, ok := key.(*ast.IntegerLiteral)
 		if !ok {
 			t.Errorf("key is not ast.IntegerLiteral. got=%T", key)
@@ -1172,6 +1373,29 @@ func TestParsingHashLiteralsIntegerKeys(t *testing.T) {
 	}
 }
 
+func TestParsingHashLiteralsKeepSourceOrder(t *testing.T) {
+	input := `{"z": 1, 2: 2, "a": 3, true: 4}`
+
+	l := lexer.New(input, nil)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	hash := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.HashLiteral)
+	expected := []string{"z", "2", "a", "true"}
+	if len(hash.Pairs) != len(expected) {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+	for i, pair := range hash.Pairs {
+		if pair.Key.String() != expected[i] {
+			t.Errorf("hash.Pairs[%d] has wrong key. expected=%q, got=%q", i, expected[i], pair.Key.String())
+		}
+	}
+	if hash.String() != `{z:1, 2:2, a:3, true:4}` {
+		t.Errorf("hash.String() wrong. got=%q", hash.String())
+	}
+}
+
 func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 	input := `{"one": 0 + 1, "two": 10 - 8, "three": 15 / 5}`
 
@@ -1202,7 +1426,8 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		},
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		literal, ok := key.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("key is not ast.StringLiteral. got=%T", key)
@@ -1219,6 +1444,69 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 	}
 }
 
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     int
+		expectedStatements []string
+	}{
+		{"let x = ;\nlet = 5;\nlet y = 3 +;\nlet z = 1;", 3, []string{"z"}},
+		{"let a = 1;\nlet b = func() {\n\tlet = 2;\n\tlet c = 3;\n};\nlet d = 4;", 1, []string{"a", "b", "d"}},
+		{"for (1, v in [1]) { v };\nlet e = 5;", 1, []string{"e"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input, nil)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != tt.expectedErrors {
+			t.Errorf("expected %d errors for %q, got=%d (%+v)", tt.expectedErrors, tt.input, len(p.Errors()), p.Errors())
+		}
+
+		if len(program.Statements) != len(tt.expectedStatements) {
+			t.Fatalf("expected %d statements for %q, got=%d", len(tt.expectedStatements), tt.input, len(program.Statements))
+		}
+		for i, name := range tt.expectedStatements {
+			testLetStatement(t, program.Statements[i], name)
+		}
+	}
+}
+
+func TestNodeSpans(t *testing.T) {
+	input := "let add = func(a, b) {\n  a + b\n};\nadd(1, 2)[0]"
+
+	l := lexer.New(input, nil)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	tests := []struct {
+		node          ast.Node
+		expectedStart int
+		expectedEnd   int
+	}{
+		{program, 0, len(input)},
+		{program.Statements[0], 0, 33},
+		{program.Statements[0].(*ast.LetStatement).Value, 10, 32},
+		{program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral).Body, 21, 32},
+		{program.Statements[1], 34, len(input)},
+		{program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression).Left, 34, 43},
+	}
+
+	for i, tt := range tests {
+		span := tt.node.Span()
+		if span.Start.Offset != tt.expectedStart || span.End.Offset != tt.expectedEnd {
+			t.Errorf("tests[%d] - expected span of %q to be [%d, %d), got=[%d, %d)", i, tt.node.String(),
+				tt.expectedStart, tt.expectedEnd, span.Start.Offset, span.End.Offset)
+		}
+	}
+
+	if pos := program.Statements[1].Span().Start; pos.Line != 4 || pos.Column != 1 {
+		t.Errorf("expected second statement to start at 4:1, got=%d:%d", pos.Line, pos.Column)
+	}
+}
+
 func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	if s.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let'. got=%q", s.TokenLiteral())
@@ -1269,6 +1557,27 @@ func testInfixExpression(t *testing.T, exp ast.Expression, left interface{},
 	return true
 }
 
+func testLogicalExpression(t *testing.T, exp ast.Expression, left interface{},
+	operator string, right interface{}) bool {
+
+	logical, ok := exp.(*ast.LogicalExpression)
+	if !ok {
+		t.Errorf("exp is not ast.LogicalExpression. got=%T(%s)", exp, exp)
+		return false
+	}
+
+	if !testLiteralExpression(t, logical.Left, left) {
+		return false
+	}
+
+	if logical.Operator != operator {
+		t.Errorf("exp.Operator is not '%s'. got=%q", operator, logical.Operator)
+		return false
+	}
+
+	return testLiteralExpression(t, logical.Right, right)
+}
+
 func testLiteralExpression(
 	t *testing.T,
 	exp ast.Expression,
@@ -1383,3 +1692,140 @@ func testAssignStatement(t *testing.T, exp ast.Expression, name string, operator
 
 	return testLiteralExpression(t, assign.Value, value)
 }
+
+func TestTryStatement(t *testing.T) {
+	tests := []struct {
+		input             string
+		expectedParameter string
+		hasCatch          bool
+		hasFinally        bool
+	}{
+		{`try { x } catch (e) { e }`, "e", true, false},
+		{`try { x } catch { y }`, "", true, false},
+		{`try { x } finally { y }`, "", false, true},
+		{`try { x } catch (err) { y } finally { z }`, "err", true, true},
+		{`try { x } catch { y };`, "", true, false},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input, nil)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.TryStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.TryStatement. got=%T", program.Statements[0])
+		}
+
+		if len(stmt.Block.Statements) != 1 {
+			t.Errorf("try block is not 1 statement. got=%d", len(stmt.Block.Statements))
+		}
+		if tt.expectedParameter == "" && stmt.Parameter != nil {
+			t.Errorf("expected no catch parameter, got=%s", stmt.Parameter)
+		}
+		if tt.expectedParameter != "" && (stmt.Parameter == nil || stmt.Parameter.Value != tt.expectedParameter) {
+			t.Errorf("expected catch parameter %q, got=%v", tt.expectedParameter, stmt.Parameter)
+		}
+		if (stmt.Catch != nil) != tt.hasCatch {
+			t.Errorf("expected catch block: %v, got=%v", tt.hasCatch, stmt.Catch)
+		}
+		if (stmt.Finally != nil) != tt.hasFinally {
+			t.Errorf("expected finally block: %v, got=%v", tt.hasFinally, stmt.Finally)
+		}
+	}
+
+	// a try block needs a catch or a finally block
+	p := New(lexer.New(`try { x }`, nil))
+	p.ParseProgram()
+	if len(p.Errors()) != 1 {
+		t.Errorf("expected 1 error, got=%d (%+v)", len(p.Errors()), p.Errors())
+	}
+}
+
+func TestThrowStatement(t *testing.T) {
+	l := lexer.New(`throw "boom";`, nil)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ThrowStatement. got=%T", program.Statements[0])
+	}
+	if stmt.String() != `throw boom;` {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
+func TestImportStatement(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedPath  string
+		expectedAlias string
+		expectedNames []string
+	}{
+		{`import "./lib/math";`, "./lib/math", "", nil},
+		{`import "./lib/math" as m`, "./lib/math", "m", nil},
+		{`import { add, sub } from "./lib/math"`, "./lib/math", "", []string{"add", "sub"}},
+		{`import {} from "./lib/math"`, "./lib/math", "", []string{}},
+		{`import { from, as } from "./lib/math"`, "./lib/math", "", []string{"from", "as"}},
+		{`import "./lib/math" as as`, "./lib/math", "as", nil},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input, nil)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ImportStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ImportStatement. got=%T", program.Statements[0])
+		}
+		if stmt.Path.Value != tt.expectedPath {
+			t.Errorf("expected path %q, got=%q", tt.expectedPath, stmt.Path.Value)
+		}
+		if (stmt.Alias == nil && tt.expectedAlias != "") || (stmt.Alias != nil && stmt.Alias.Value != tt.expectedAlias) {
+			t.Errorf("expected alias %q, got=%v", tt.expectedAlias, stmt.Alias)
+		}
+		if len(stmt.Names) != len(tt.expectedNames) || (stmt.Names == nil) != (tt.expectedNames == nil) {
+			t.Fatalf("expected names %v, got=%v", tt.expectedNames, stmt.Names)
+		}
+		for i, name := range tt.expectedNames {
+			testIdentifier(t, stmt.Names[i], name)
+		}
+	}
+}
+
+func TestExportStatement(t *testing.T) {
+	l := lexer.New(`export let x = 5;`, nil)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExportStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExportStatement. got=%T", program.Statements[0])
+	}
+	testLetStatement(t, stmt.Statement, "x")
+
+	// modules can only be imported and bindings exported at the top level
+	for _, input := range []string{
+		`let f = func() { import "./a" }`,
+		`if (true) { export let y = 1 }`,
+		`export x`,
+		`import { a } "./a"`,
+		`import { a } as "./a"`,
+	} {
+		p := New(lexer.New(input, nil))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected errors for %q", input)
+		}
+	}
+}
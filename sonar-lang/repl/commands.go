@@ -0,0 +1,153 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/icheka/sonar-lang/sonar-lang/evaluator"
+	"github.com/icheka/sonar-lang/sonar-lang/inputs"
+	"github.com/icheka/sonar-lang/sonar-lang/lexer"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+)
+
+type command struct {
+	usage       string
+	description string
+	// run carries out the command with the text entered after its name, and
+	// reports whether the session should carry on
+	run func(s *session, arg string) bool
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		":env":     {":env", "list the bindings defined in this session", (*session).printEnv},
+		":type":    {":type <expr>", "print the type of the value of expr", (*session).printType},
+		":load":    {":load <file>", "evaluate the script at file in this session", (*session).load},
+		":reset":   {":reset", "remove every binding defined in this session", (*session).reset},
+		":history": {":history", "list the lines entered, oldest first", (*session).printHistory},
+		":run":     {":run <n>", "enter again the entry on line n of :history", (*session).rerun},
+		":help":    {":help", "list the commands", (*session).printHelp},
+		":quit":    {":quit", "end the session", func(*session, string) bool { return false }},
+	}
+}
+
+// command runs the meta-command entered as line, and reports whether the
+// session should carry on
+func (s *session) command(line string) bool {
+	name, arg := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		name, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		io.WriteString(s.out, fmt.Sprintf("Unknown command %s. Enter :help for a list of commands.\n", name))
+		return true
+	}
+	return cmd.run(s, arg)
+}
+
+func (s *session) printEnv(string) bool {
+	names := []string{}
+	for name := range s.env.Store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		keyword := "let"
+		if s.env.IsConstant(name) {
+			keyword = "const"
+		}
+		io.WriteString(s.out, fmt.Sprintf("%s %s = %s\n", keyword, name, s.env.Store[name].Inspect()))
+	}
+	return true
+}
+
+func (s *session) printType(expr string) bool {
+	if len(expr) == 0 {
+		io.WriteString(s.out, "Usage: :type <expr>\n")
+		return true
+	}
+	if evaluated, ok := s.eval(expr, nil); ok {
+		if evaluated == nil {
+			evaluated = evaluator.NULL
+		}
+		io.WriteString(s.out, fmt.Sprintf("%s\n", evaluated.Type()))
+	}
+	return true
+}
+
+func (s *session) load(file string) bool {
+	if len(file) == 0 {
+		io.WriteString(s.out, "Usage: :load <file>\n")
+		return true
+	}
+	path, err := filepath.Abs(file)
+	if err == nil {
+		var source string
+		if source, err = (&inputs.FileInput{Path: path}).Load(); err == nil {
+			s.eval(source, &lexer.LexerOptions{Path: path})
+			return true
+		}
+	}
+	io.WriteString(s.out, fmt.Sprintf("Cannot load %s: %s\n", file, err))
+	return true
+}
+
+func (s *session) reset(string) bool {
+	s.env = object.NewEnvironment()
+	return true
+}
+
+func (s *session) printHistory(string) bool {
+	for i, line := range s.history.Lines {
+		io.WriteString(s.out, fmt.Sprintf("%4d  %s\n", i+1, line))
+	}
+	return true
+}
+
+// rerun enters the entry on the given line of history again, as if it were
+// typed in: it is shown, added to history and evaluated
+func (s *session) rerun(arg string) bool {
+	n, err := strconv.Atoi(arg)
+	entry, ok := s.history.Entry(n)
+	if err != nil || !ok {
+		io.WriteString(s.out, "Usage: :run <n>, where n is a line number listed by :history\n")
+		return true
+	}
+	command := strings.TrimSpace(entry)
+	if command == ":run" || strings.HasPrefix(command, ":run ") {
+		io.WriteString(s.out, "Cannot :run a :run command\n")
+		return true
+	}
+
+	io.WriteString(s.out, entry+"\n")
+	for _, line := range strings.Split(entry, "\n") {
+		s.history.Add(line)
+	}
+	if strings.HasPrefix(command, ":") {
+		return s.command(command)
+	}
+	s.enter(entry)
+	return true
+}
+
+func (s *session) printHelp(string) bool {
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		io.WriteString(s.out, fmt.Sprintf("%-16s%s\n", commands[name].usage, commands[name].description))
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package repl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HISTORY_SIZE is the number of lines of history kept
+const HISTORY_SIZE = 1000
+
+// History is the lines entered in REPL sessions, oldest first
+type History struct {
+	Lines []string
+	// the file the lines are saved to, if any
+	path string
+}
+
+// DefaultHistoryFile returns the file history is saved to when no other is
+// given: .sonar_history in the user's home directory, or "" if there is none
+func DefaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".sonar_history")
+}
+
+// LoadHistory returns the history saved to path, which lines entered later
+// are added to. A file that does not exist yet is created when the first
+// line is added; if path is "", history is not saved at all.
+func LoadHistory(path string) *History {
+	h := &History{path: path}
+	if len(path) == 0 {
+		return h
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return h
+	}
+	h.Lines = strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(content) == 0 {
+		h.Lines = nil
+	}
+
+	// keep the file from growing without bound
+	if len(h.Lines) > HISTORY_SIZE {
+		h.Lines = h.Lines[len(h.Lines)-HISTORY_SIZE:]
+		os.WriteFile(path, []byte(strings.Join(h.Lines, "\n")+"\n"), 0600)
+	}
+	return h
+}
+
+// Entry returns the entry that starts on line n of h, counting from one:
+// the line and as many of the lines after it as it takes to finish it
+func (h *History) Entry(n int) (string, bool) {
+	if n < 1 || n > len(h.Lines) {
+		return "", false
+	}
+
+	lines := []string{}
+	for _, line := range h.Lines[n-1:] {
+		lines = append(lines, line)
+		if !incomplete(strings.Join(lines, "\n")) {
+			break
+		}
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+// Add records line, saving it if h has a file. History is a convenience, so
+// a file that cannot be written to does not stop the session.
+func (h *History) Add(line string) {
+	h.Lines = append(h.Lines, line)
+	if len(h.path) == 0 {
+		return
+	}
+
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	file.WriteString(line + "\n")
+}
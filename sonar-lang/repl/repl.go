@@ -2,94 +2,137 @@ package repl
 
 import (
 	"bufio"
-	"fmt"
 	"io"
-	"os"
 	"strings"
 
-	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/diagnostics"
 	"github.com/icheka/sonar-lang/sonar-lang/evaluator"
-	"github.com/icheka/sonar-lang/sonar-lang/keys"
 	"github.com/icheka/sonar-lang/sonar-lang/lexer"
 	"github.com/icheka/sonar-lang/sonar-lang/object"
 	"github.com/icheka/sonar-lang/sonar-lang/parser"
+	"github.com/icheka/sonar-lang/sonar-lang/token"
 )
 
-const PROMPT = ">> "
+const (
+	PROMPT = ">> "
+	// shown instead of PROMPT while an unfinished entry is being read
+	CONTINUATION_PROMPT = ".. "
+)
+
+// Options configures a REPL session
+type Options struct {
+	// HistoryFile is the file entered lines are saved to, and read from when
+	// the session starts. History is not saved if it is empty.
+	HistoryFile string
+}
 
-func Start(in io.Reader, out io.Writer) {
+// Start reads entries from in and writes what they evaluate to to out, until
+// in is exhausted or :quit is entered. An entry continues over as many lines
+// as it takes to close its brackets and strings, or until a blank line.
+// Errors are reported and the session carries on.
+func Start(in io.Reader, out io.Writer, options Options) {
+	s := &session{
+		out:     out,
+		report:  diagnostics.New(out),
+		env:     object.NewEnvironment(),
+		history: LoadHistory(options.HistoryFile),
+	}
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	lines := []string{}
 
 	for {
-		fmt.Print(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		if len(lines) == 0 {
+			io.WriteString(out, PROMPT)
+		} else {
+			io.WriteString(out, CONTINUATION_PROMPT)
+		}
+		if !scanner.Scan() {
+			io.WriteString(out, "\n")
 			return
 		}
 
 		line := scanner.Text()
-		l := lexer.New(line, nil)
-		p := parser.New(l)
+		if len(lines) == 0 {
+			if len(strings.TrimSpace(line)) == 0 {
+				continue
+			}
+			if strings.HasPrefix(strings.TrimSpace(line), ":") {
+				s.history.Add(line)
+				if !s.command(strings.TrimSpace(line)) {
+					return
+				}
+				continue
+			}
+		}
 
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			PrintParserErrors(out, p.Errors())
-			return
+		blank := len(strings.TrimSpace(line)) == 0
+		if !blank {
+			s.history.Add(line)
+			lines = append(lines, line)
 		}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		entry := strings.Join(lines, "\n")
+		if !blank && incomplete(entry) {
+			continue
 		}
+		lines = lines[:0]
+		s.enter(entry)
 	}
 }
 
-func PrintParserErrors(out io.Writer, errors []errors.Error) {
-	if len(errors) == 0 {
-		return
+type session struct {
+	out     io.Writer
+	report  *diagnostics.Renderer
+	env     *object.Environment
+	history *History
+}
 
+// enter evaluates an entry and prints its value
+func (s *session) enter(entry string) {
+	if evaluated, ok := s.eval(entry, nil); ok && evaluated != nil {
+		io.WriteString(s.out, evaluated.Inspect())
+		io.WriteString(s.out, "\n")
 	}
-	for i, e := range errors[0:1] {
-		if len(e.File) != 0 {
-			io.WriteString(out, fmt.Sprintf("File %s, ", normalisePath(e.File)))
-		}
-
-		// add [LINE:COLUMN]
-		io.WriteString(out, fmt.Sprintf("line %d:%d\n\n", e.Line, e.Column))
+}
 
-		// and error
-		io.WriteString(out, "\t")
-		if len(e.LineText) != 0 {
-			drawErrorTracer(out, &e)
-		}
-		io.WriteString(out, fmt.Sprintf("%s\n", e.String()))
+// eval evaluates source in the session's environment and prints its value,
+// or the errors it raises
+func (s *session) eval(source string, options *lexer.LexerOptions) (object.Object, bool) {
+	p := parser.New(lexer.New(source, options))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		s.report.Errors(p.Errors())
+		return nil, false
+	}
 
-		if len(e.Hint) != 0 && keys.Keys.MODE == "DEV" {
-			io.WriteString(out, fmt.Sprintf("[Hint] %s\n", e.Hint))
-		}
-		if i < len(errors)-1 {
-			io.WriteString(out, "\n")
+	evaluated := evaluator.Eval(program, s.env)
+	if err, ok := evaluated.(*object.Error); ok {
+		file := ""
+		if options != nil {
+			file = options.Path
 		}
+		s.report.RuntimeError(err, file, source)
+		return nil, false
 	}
+	return evaluated, true
 }
 
-func normalisePath(p string) string {
-	pwd, _ := os.Getwd()
-	return strings.Replace(p, pwd, ".", 1)
-}
-
-func drawErrorTracer(out io.Writer, err *errors.Error) {
-	if len(err.LineText) == 0 {
-		return
+// incomplete reports whether source ends inside brackets, or a string, that
+// it opened
+func incomplete(source string) bool {
+	l := lexer.New(source, nil)
+	depth := 0
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET, token.TEMPLATE_HEAD:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET, token.TEMPLATE_TAIL:
+			depth--
+		}
 	}
-
-	indent := []string{"\t"}
-	for i := 0; i < err.LineTextTokenPosition; i++ {
-		indent = append(indent, " ")
+	if depth > 0 {
+		return true
 	}
-	indent = append(indent, "^")
 
-	io.WriteString(out, fmt.Sprintf("%s\n%s\n\n", err.LineText, strings.Join(indent, "")))
+	return l.Unterminated()
 }
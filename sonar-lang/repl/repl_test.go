@@ -0,0 +1,115 @@
+package repl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func run(input string, options Options) string {
+	var out strings.Builder
+	Start(strings.NewReader(input), &out, options)
+	return out.String()
+}
+
+func TestMultiLineEntries(t *testing.T) {
+	out := run("let add = func(a, b) {\n  a + b\n}\nadd(1,\n2)\n\"${add(\n1, 1)}\"\n", Options{})
+	for _, expected := range []string{"\n>> .. 3\n", "\n>> .. 2\n"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected output to contain %q, got=%q", expected, out)
+		}
+	}
+
+	// as does a string
+	out = run("let s = \"a\nb\"\nlen(s)\n", Options{})
+	if !strings.Contains(out, "\n>> 3\n") {
+		t.Errorf("expected the string to continue on the next line, got=%q", out)
+	}
+
+	// a blank line ends an entry even if it is unfinished
+	out = run("[1,\n\n2\n", Options{})
+	if !strings.Contains(out, "SyntaxError") || !strings.HasSuffix(out, ">> 2\n>> \n") {
+		t.Errorf("expected the unfinished entry to be an error, got=%q", out)
+	}
+}
+
+func TestSessionSurvivesErrors(t *testing.T) {
+	out := run("let = 1\nundefined\nlet a = 1\na + 1\n", Options{})
+	for _, expected := range []string{"SyntaxError", "ReferenceError", ">> 2\n"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected output to contain %q, got=%q", expected, out)
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "script.sonar")
+	os.WriteFile(script, []byte("let fromScript = 42"), 0600)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = 1\nconst b = [2]\n:env\n", "let a = 1\nconst b = [2]\n"},
+		{"let a = 1\n:reset\n:env\na\n", ">> >> >> Traceback"},
+		{":type 1.5\n:type {}\n:type null\n", "FLOAT\n>> MAP\n>> NULL\n"},
+		{":load " + script + "\nfromScript\n", ">> 42\n"},
+		{":load missing.sonar\n", "Cannot load missing.sonar"},
+		{":nope\n", "Unknown command :nope"},
+		{":help\n", ":quit"},
+		{":quit\n1\n", ">> "},
+	}
+
+	for _, tt := range tests {
+		out := run(tt.input, Options{})
+		if !strings.Contains(out, tt.expected) {
+			t.Errorf("%q: expected output to contain %q, got=%q", tt.input, tt.expected, out)
+		}
+	}
+
+	if out := run(":quit\n1\n", Options{}); out != ">> " {
+		t.Errorf("expected :quit to end the session, got=%q", out)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history")
+
+	run("let a = [\n1]\n\n:env\n", Options{HistoryFile: file})
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("expected history to be saved: %s", err)
+	}
+	if string(content) != "let a = [\n1]\n:env\n" {
+		t.Errorf("wrong history saved. got=%q", content)
+	}
+
+	// a later session sees the lines entered before it
+	out := run("1\n:history\n", Options{HistoryFile: file})
+	if !strings.Contains(out, "   3  :env\n   4  1\n   5  :history\n") {
+		t.Errorf("expected the history of both sessions, got=%q", out)
+	}
+}
+
+func TestRerun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let n = 1\nn += 1\n:run 2\nn\n", ">> n += 1\n3\n>> 3\n"},
+		{"len([1,\n2])\n:run 1\n", ">> len([1,\n2])\n2\n"},
+		{"1\n:type 1.5\n:run 2\n", ">> :type 1.5\nFLOAT\n"},
+		{"1\n:run 1\n:history\n", "   3  1\n   4  :history\n"},
+		{":run 1\n", "Cannot :run a :run command"},
+		{"1\n:run 5\n", "Usage: :run <n>"},
+		{"1\n:run x\n", "Usage: :run <n>"},
+	}
+
+	for _, tt := range tests {
+		out := run(tt.input, Options{})
+		if !strings.Contains(out, tt.expected) {
+			t.Errorf("%q: expected output to contain %q, got=%q", tt.input, tt.expected, out)
+		}
+	}
+}
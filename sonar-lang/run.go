@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/icheka/sonar-lang/sonar-lang/compiler"
+	"github.com/icheka/sonar-lang/sonar-lang/diagnostics"
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/evaluator"
+	"github.com/icheka/sonar-lang/sonar-lang/inputs"
+	"github.com/icheka/sonar-lang/sonar-lang/lexer"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+	"github.com/icheka/sonar-lang/sonar-lang/parser"
+	"github.com/icheka/sonar-lang/sonar-lang/sonar"
+	"github.com/icheka/sonar-lang/sonar-lang/vm"
+)
+
+// STDIN names the program read from standard input
+const STDIN = "-"
+
+func (c *cli) run(args []string) int {
+	flags := c.flagSet("run")
+	useVM := flags.Bool("vm", false, "compile the program to bytecode and run it on the virtual machine")
+	source := flags.String("e", "", "run `source` instead of a file")
+	if status, ok := parseFlags(flags, args); !ok {
+		return status
+	}
+
+	var src string
+	var options *lexer.LexerOptions
+	rest := flags.Args()
+	if isFlagSet(flags, "e") {
+		src = *source
+	} else {
+		file := STDIN
+		if len(rest) > 0 {
+			file, rest = rest[0], rest[1:]
+		}
+
+		var err error
+		if src, options, err = c.readSource(file); err != nil {
+			fmt.Fprintf(c.stderr, "Cannot read %s: %s\n", file, err)
+			return EXIT_USAGE
+		}
+	}
+	if len(rest) > 0 && rest[0] == "--" {
+		rest = rest[1:]
+	}
+
+	if *useVM {
+		if !evaluate(src, options, rest, c.stdout, c.stderr) {
+			return EXIT_ERROR
+		}
+		return EXIT_OK
+	}
+
+	interpreter := sonar.New()
+	interpreter.Stdout = c.stdout
+	interpreter.Stderr = c.stderr
+	interpreter.Args = rest
+	var err error
+	if options != nil {
+		_, err = interpreter.RunFile(context.Background(), options.Path)
+	} else {
+		_, err = interpreter.Run(context.Background(), src)
+	}
+	if err != nil {
+		return EXIT_ERROR
+	}
+	return EXIT_OK
+}
+
+// readSource returns the program in file, or read from stdin if file is
+// STDIN, and the options to lex it with
+func (c *cli) readSource(file string) (string, *lexer.LexerOptions, error) {
+	if file == STDIN {
+		src, err := io.ReadAll(c.stdin)
+		return string(src), nil, err
+	}
+
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return "", nil, err
+	}
+	src, err := (&inputs.FileInput{Path: path}).Load()
+	return src, &lexer.LexerOptions{Path: path}, err
+}
+
+// isFlagSet reports whether the flag of the given name was set on the command line
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// evaluate compiles source and runs it on the virtual machine with args,
+// printing to stdout and reporting any error to stderr, and reports whether
+// it ran without one
+func evaluate(source string, options *lexer.LexerOptions, args []string, stdout, stderr io.Writer) bool {
+	report := diagnostics.New(stderr)
+
+	p := parser.New(lexer.New(source, options))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		report.Errors(p.Errors())
+		return false
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		if conf, ok := err.(errors.Error); ok {
+			report.Error(conf)
+		} else {
+			fmt.Fprintln(stderr, err)
+		}
+		return false
+	}
+
+	machine := vm.New(comp.Bytecode())
+	machine.SetArgs(args)
+	builtins := evaluator.NewBuiltins()
+	builtins["print"] = evaluator.NewPrintBuiltin(stdout)
+	machine.SetBuiltins(builtins)
+	evaluated := machine.Run()
+	if obj, ok := evaluated.(*object.Error); ok {
+		file := ""
+		if options != nil {
+			file = options.Path
+		}
+		report.RuntimeError(obj, file, source)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package sonar
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/icheka/sonar-lang/sonar-lang/evaluator"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+)
+
+// ToObject converts a Go value to the object scripts see it as:
+//
+//   - nil to null
+//   - bools, integers, floats and strings to BOOLEAN, INTEGER, FLOAT and STRING
+//   - slices and arrays to ARRAY
+//   - maps with string, integer or bool keys to MAP, with the keys sorted
+//   - HostFunctions and object.BuiltinFunctions to BUILTIN
+//   - objects to themselves
+//
+// Values of any other type are an error.
+func ToObject(value interface{}) (object.Object, error) {
+	switch value := value.(type) {
+	case nil:
+		return evaluator.NULL, nil
+	case object.Object:
+		return value, nil
+	case HostFunction:
+		return hostBuiltin(object.ANONYMOUS_FUNCTION, value), nil
+	case func(args ...interface{}) (interface{}, error):
+		return ToObject(HostFunction(value))
+	case object.BuiltinFunction:
+		return &object.Builtin{Fn: value}, nil
+	case func(caller object.Caller, args ...object.Object) object.Object:
+		return &object.Builtin{Fn: value}, nil
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &object.Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			element, err := ToObject(v.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		// Go maps have no order, so sort the keys to give the same MAP each time
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+
+		hash := object.NewHash()
+		for _, k := range keys {
+			key, err := ToObject(k.Interface())
+			if err != nil {
+				return nil, err
+			}
+			hashKey, ok := object.HashKeyOf(key)
+			if !ok {
+				return nil, fmt.Errorf("cannot use %s as a map key", k.Type())
+			}
+
+			val, err := ToObject(v.MapIndex(k).Interface())
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(hashKey, object.HashPair{Key: key, Value: val})
+		}
+		return hash, nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return ToObject(v.Elem().Interface())
+	}
+
+	return nil, fmt.Errorf("cannot convert %T to a Sonar value", value)
+}
+
+// FromObject converts an object to the Go value it represents: nil, bool,
+// int64, float64, string, []interface{} or map[string]interface{}. Map keys
+// that are not strings are converted to their Inspect form. Other objects,
+// such as functions, are returned as they are.
+func FromObject(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil
+	case *object.Boolean:
+		return obj.Value
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Array:
+		values := make([]interface{}, len(obj.Elements))
+		for i, element := range obj.Elements {
+			values[i] = FromObject(element)
+		}
+		return values
+	case *object.Hash:
+		values := make(map[string]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key, ok := pair.Key.(*object.String)
+			if ok {
+				values[key.Value] = FromObject(pair.Value)
+			} else {
+				values[pair.Key.Inspect()] = FromObject(pair.Value)
+			}
+		}
+		return values
+	}
+
+	return obj
+}
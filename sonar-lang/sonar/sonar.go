@@ -0,0 +1,207 @@
+// Package sonar embeds the Sonar interpreter in Go programs.
+//
+// Each Interpreter has its own globals and builtins, so any number of them
+// can run in one process:
+//
+//	interp := sonar.New()
+//	interp.Register("double", func(args ...interface{}) (interface{}, error) {
+//		return args[0].(int64) * 2, nil
+//	})
+//	result, err := interp.Run(ctx, `double(21)`)
+package sonar
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/icheka/sonar-lang/sonar-lang/diagnostics"
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/evaluator"
+	"github.com/icheka/sonar-lang/sonar-lang/inputs"
+	"github.com/icheka/sonar-lang/sonar-lang/lexer"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+	"github.com/icheka/sonar-lang/sonar-lang/parser"
+)
+
+// HostFunction is a Go function that scripts can call. Its arguments are
+// converted with FromObject and its result with ToObject; a returned error is
+// raised in the script as a RuntimeError.
+type HostFunction func(args ...interface{}) (interface{}, error)
+
+type Interpreter struct {
+	// Stdout receives the output of print. It defaults to os.Stdout.
+	Stdout io.Writer
+	// Stderr receives a report of every error returned by Run, as the
+	// sonar-lang command prints them. It defaults to os.Stderr; set it to
+	// io.Discard to only receive the errors returned.
+	Stderr io.Writer
+	// Limits bounds the resources each call to Run may use. Scripts cannot
+	// catch the errors raised when they are exceeded.
+	Limits object.Limits
+	// Args are the arguments scripts are given as os.args
+	Args []string
+
+	env      *object.Environment
+	builtins map[string]*object.Builtin
+}
+
+// New returns an Interpreter with the default builtins and standard library
+func New() *Interpreter {
+	i := &Interpreter{
+		Stdout:   os.Stdout,
+		Stderr:   os.Stderr,
+		env:      object.NewEnvironment(),
+		builtins: evaluator.NewBuiltins(),
+	}
+
+	// print writes to whatever Stdout is when it is called
+	i.builtins["print"] = &object.Builtin{
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			return evaluator.NewPrintBuiltin(i.Stdout).Fn(caller, args...)
+		},
+	}
+	i.env.SetBuiltins(i.builtins)
+
+	return i
+}
+
+// Register makes fn callable from scripts as name, replacing any builtin of
+// that name
+func (i *Interpreter) Register(name string, fn HostFunction) {
+	i.builtins[name] = hostBuiltin(name, fn)
+}
+
+func hostBuiltin(name string, fn HostFunction) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			values := make([]interface{}, len(args))
+			for j, arg := range args {
+				values[j] = FromObject(arg)
+			}
+
+			result, err := fn(values...)
+			if err != nil {
+				return evaluator.NewError(errors.NewRuntimeError(err.Error()))
+			}
+
+			obj, err := ToObject(result)
+			if err != nil {
+				return evaluator.NewError(errors.NewRuntimeError(fmt.Sprintf("%s: %s", name, err)))
+			}
+			return obj
+		},
+	}
+}
+
+// RegisterBuiltin is like Register for functions that work with objects
+// directly
+func (i *Interpreter) RegisterBuiltin(name string, fn object.BuiltinFunction) {
+	i.builtins[name] = &object.Builtin{Fn: fn}
+}
+
+// Set binds name to value, converted with ToObject, in the interpreter's
+// globals
+func (i *Interpreter) Set(name string, value interface{}) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+	i.env.Set(name, obj)
+	return nil
+}
+
+// Get returns the global bound to name
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.env.Get(name)
+}
+
+// Run evaluates src and returns the value of its last statement. Globals
+// defined by src remain defined for later calls. Imports are resolved
+// against the working directory.
+func (i *Interpreter) Run(ctx context.Context, src string) (object.Object, error) {
+	return i.run(ctx, src, nil)
+}
+
+// RunFile is like Run for the script at path. Its imports are resolved
+// against the directory it is in.
+func (i *Interpreter) RunFile(ctx context.Context, path string) (object.Object, error) {
+	src, err := (&inputs.FileInput{Path: path}).Load()
+	if err != nil {
+		return nil, err
+	}
+	return i.run(ctx, src, &lexer.LexerOptions{Path: path})
+}
+
+func (i *Interpreter) run(ctx context.Context, src string, options *lexer.LexerOptions) (result object.Object, err error) {
+	defer func() {
+		// a bug in the interpreter must not take the host program down with it
+		if r := recover(); r != nil {
+			conf := errors.InternalError(r)
+			if i.Stderr != nil {
+				diagnostics.New(i.Stderr).Error(conf)
+			}
+			result, err = nil, &Error{Errors: []errors.Error{conf}}
+		}
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	p := parser.New(lexer.New(src, options))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		err := &Error{Errors: p.Errors()}
+		if i.Stderr != nil {
+			diagnostics.New(i.Stderr).Errors(err.Errors)
+		}
+		return nil, err
+	}
+
+	i.env.SetArgs(i.Args)
+	result = evaluator.EvalContext(ctx, program, i.env, i.Limits)
+	if obj, ok := result.(*object.Error); ok {
+		err := &Error{Traceback: obj.Traceback}
+		if conf, ok := obj.Conf.(errors.Error); ok {
+			// runtime errors only know their position, so look up the line they occurred on
+			if len(conf.LineText) == 0 && conf.File == fileOf(options) {
+				conf.LineText = errors.LineText(src, conf.Line)
+			}
+			err.Errors = append([]errors.Error{conf}, obj.Related...)
+		}
+		if i.Stderr != nil {
+			diagnostics.New(i.Stderr).RuntimeError(obj, fileOf(options), src)
+		}
+		return nil, err
+	}
+
+	if result == nil {
+		result = evaluator.NULL
+	}
+	return result, nil
+}
+
+func fileOf(options *lexer.LexerOptions) string {
+	if options == nil {
+		return ""
+	}
+	return options.Path
+}
+
+// Error is returned by Run when a script cannot be parsed, with every syntax
+// error in it, or raises an error, with the error and its traceback
+type Error struct {
+	Errors    []errors.Error
+	Traceback []object.Frame
+}
+
+func (e *Error) Error() string {
+	messages := []string{}
+	for _, err := range e.Errors {
+		messages = append(messages, err.String())
+	}
+	return strings.Join(messages, "\n")
+}
@@ -0,0 +1,207 @@
+package sonar
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+)
+
+func newTestInterpreter() (*Interpreter, *bytes.Buffer) {
+	var out bytes.Buffer
+	i := New()
+	i.Stdout = &out
+	i.Stderr = io.Discard
+	return i, &out
+}
+
+func TestRun(t *testing.T) {
+	i, out := newTestInterpreter()
+
+	result, err := i.Run(context.Background(), `let x = 2; print("x is", x); x * 21`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if FromObject(result) != int64(42) {
+		t.Errorf("expected 42, got=%s", result.Inspect())
+	}
+	if out.String() != "'x is', 2\n" {
+		t.Errorf("expected print to write to Stdout, got=%q", out.String())
+	}
+
+	// globals persist between runs
+	result, err = i.Run(context.Background(), `x + 1`)
+	if err != nil || FromObject(result) != int64(3) {
+		t.Errorf("expected 3, got=%v (%v)", result, err)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	i, _ := newTestInterpreter()
+	var stderr bytes.Buffer
+	i.Stderr = &stderr
+
+	_, err := i.Run(context.Background(), "let = 1;\nlet y = ;")
+	sonarErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected *Error, got=%T (%v)", err, err)
+	}
+	if len(sonarErr.Errors) != 2 || sonarErr.Errors[0].Type != errors.SYNTAX_ERROR {
+		t.Errorf("expected 2 syntax errors, got=%+v", sonarErr.Errors)
+	}
+
+	stderr.Reset()
+	_, err = i.Run(context.Background(), "let f = func() { 1 + \"a\" }\nf()")
+	sonarErr, ok = err.(*Error)
+	if !ok {
+		t.Fatalf("expected *Error, got=%T (%v)", err, err)
+	}
+	if len(sonarErr.Traceback) != 2 {
+		t.Errorf("expected 2 frames, got=%+v", sonarErr.Traceback)
+	}
+	if stderr.Len() == 0 {
+		t.Errorf("expected the error to be reported to Stderr")
+	}
+
+	i.Limits = object.Limits{MaxSteps: 10}
+	_, err = i.Run(context.Background(), `while (true) {}`)
+	if sonarErr, ok := err.(*Error); !ok || sonarErr.Errors[0].Type != errors.LIMIT_ERROR {
+		t.Errorf("expected a LimitError, got=%v", err)
+	}
+
+	i.Limits = object.Limits{}
+	i.RegisterBuiltin("crash", func(caller object.Caller, args ...object.Object) object.Object {
+		panic("crashed")
+	})
+	stderr.Reset()
+	_, err = i.Run(context.Background(), `crash()`)
+	if sonarErr, ok := err.(*Error); !ok || sonarErr.Errors[0].Message != "Internal error: crashed" {
+		t.Errorf("expected the panic to be returned as an error, got=%v", err)
+	}
+	if stderr.Len() == 0 {
+		t.Errorf("expected the panic to be reported to Stderr")
+	}
+
+	_, err = i.Run(context.Background(), `let recurse = func(n) { recurse(n + 1) }; recurse(0)`)
+	if sonarErr, ok := err.(*Error); !ok || sonarErr.Errors[0].Message != "Maximum call stack size exceeded" {
+		t.Errorf("expected unbounded recursion to overflow the stack, got=%v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := i.Run(ctx, `1`); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got=%v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	i, _ := newTestInterpreter()
+	i.Register("double", func(args ...interface{}) (interface{}, error) {
+		return args[0].(int64) * 2, nil
+	})
+	i.Register("fail", func(args ...interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("failed with %v", args[0])
+	})
+	i.Register("pair", func(args ...interface{}) (interface{}, error) {
+		return map[string]interface{}{"left": args[0], "right": args[1]}, nil
+	})
+
+	result, err := i.Run(context.Background(), `double(21)`)
+	if err != nil || FromObject(result) != int64(42) {
+		t.Errorf("expected 42, got=%v (%v)", result, err)
+	}
+
+	result, err = i.Run(context.Background(), `pair("a", [1, 2.5])["right"]`)
+	if err != nil || !reflect.DeepEqual(FromObject(result), []interface{}{int64(1), 2.5}) {
+		t.Errorf("expected [1, 2.5], got=%v (%v)", result, err)
+	}
+
+	result, err = i.Run(context.Background(), `try { fail("x") } catch (e) { e["message"] }`)
+	if err != nil || FromObject(result) != "failed with x" {
+		t.Errorf("expected the error to be catchable, got=%v (%v)", result, err)
+	}
+}
+
+func TestInterpretersAreIsolated(t *testing.T) {
+	a, outA := newTestInterpreter()
+	b, outB := newTestInterpreter()
+
+	a.Register("host", func(args ...interface{}) (interface{}, error) { return "a", nil })
+	if err := a.Set("shared", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.Run(context.Background(), `print(host())`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Run(context.Background(), `host()`); err == nil {
+		t.Errorf("expected host to be undefined in b")
+	}
+	if _, ok := b.Get("shared"); ok {
+		t.Errorf("expected shared to be undefined in b")
+	}
+	a.Args = []string{"x"}
+	if _, err := a.Run(context.Background(), `os.args[0] = "changed"`); err != nil {
+		t.Fatal(err)
+	}
+	result, err := a.Run(context.Background(), `os.args`)
+	if err != nil || !reflect.DeepEqual(FromObject(result), []interface{}{"x"}) {
+		t.Errorf("expected a's args to be ['x'], got=%v (%v)", result, err)
+	}
+	if result, err := b.Run(context.Background(), `len(os.args)`); err != nil || FromObject(result) != int64(0) {
+		t.Errorf("expected b to have no args, got=%v (%v)", result, err)
+	}
+
+	if _, err := b.Run(context.Background(), `print("b")`); err != nil {
+		t.Fatal(err)
+	}
+
+	if outA.String() != "'a'\n" || outB.String() != "'b'\n" {
+		t.Errorf("expected separate output, got=%q and %q", outA.String(), outB.String())
+	}
+}
+
+func TestConversion(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected interface{}
+	}{
+		{nil, nil},
+		{true, true},
+		{3, int64(3)},
+		{uint8(3), int64(3)},
+		{1.5, 1.5},
+		{"str", "str"},
+		{[]int{1, 2}, []interface{}{int64(1), int64(2)}},
+		{map[string]interface{}{"a": []string{"b"}}, map[string]interface{}{"a": []interface{}{"b"}}},
+		{map[int]bool{1: true}, map[string]interface{}{"1": true}},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.input)
+		if err != nil {
+			t.Errorf("unexpected error converting %#v: %s", tt.input, err)
+			continue
+		}
+		if got := FromObject(obj); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("expected %#v, got=%#v", tt.expected, got)
+		}
+	}
+
+	if _, err := ToObject(struct{}{}); err == nil {
+		t.Errorf("expected an error converting a struct")
+	}
+
+	obj, err := ToObject(func(args ...interface{}) (interface{}, error) { return len(args), nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := obj.(*object.Builtin).Fn(nil, &object.Integer{Value: 1}); FromObject(result) != int64(1) {
+		t.Errorf("expected 1, got=%s", result.Inspect())
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/icheka/sonar-lang/sonar-lang/ast"
+	"github.com/icheka/sonar-lang/sonar-lang/diagnostics"
+	"github.com/icheka/sonar-lang/sonar-lang/lexer"
+	"github.com/icheka/sonar-lang/sonar-lang/parser"
+	"github.com/icheka/sonar-lang/sonar-lang/token"
+)
+
+func (c *cli) ast(args []string) int {
+	src, options, status, ok := c.sourceArgument("ast", args)
+	if !ok {
+		return status
+	}
+
+	p := parser.New(lexer.New(src, options))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		diagnostics.New(c.stderr).Errors(p.Errors())
+		return EXIT_ERROR
+	}
+	ast.Fprint(c.stdout, program)
+	return EXIT_OK
+}
+
+func (c *cli) tokens(args []string) int {
+	src, options, status, ok := c.sourceArgument("tokens", args)
+	if !ok {
+		return status
+	}
+
+	l := lexer.New(src, options)
+	for {
+		tok := l.NextToken()
+		start := tok.Span.Start
+		fmt.Fprintf(c.stdout, "%d:%d\t%-16s%q\n", start.Line, start.Column, tok.Type, tok.Literal)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+
+	if len(l.Errors()) != 0 {
+		diagnostics.New(c.stderr).Errors(l.Errors())
+		return EXIT_ERROR
+	}
+	return EXIT_OK
+}
+
+// sourceArgument reads the program named by the arguments of a command that
+// takes nothing else. If it cannot, it returns the status to exit with and
+// false.
+func (c *cli) sourceArgument(name string, args []string) (string, *lexer.LexerOptions, int, bool) {
+	flags := c.flagSet(name)
+	if status, ok := parseFlags(flags, args); !ok {
+		return "", nil, status, false
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return "", nil, EXIT_USAGE, false
+	}
+
+	file := STDIN
+	if flags.NArg() == 1 {
+		file = flags.Arg(0)
+	}
+	src, options, err := c.readSource(file)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "Cannot read %s: %s\n", file, err)
+		return "", nil, EXIT_USAGE, false
+	}
+	return src, options, EXIT_OK, true
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/icheka/sonar-lang/sonar-lang/ast"
+	"github.com/icheka/sonar-lang/sonar-lang/diagnostics"
+	"github.com/icheka/sonar-lang/sonar-lang/lexer"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+	"github.com/icheka/sonar-lang/sonar-lang/parser"
+	"github.com/icheka/sonar-lang/sonar-lang/sonar"
+)
+
+// TEST_FILE_SUFFIX ends the names of the files test runs
+const TEST_FILE_SUFFIX = "_test.sonar"
+
+// test runs every test file in the given files and directories, or in the
+// working directory if there are none. A test file is run, then each
+// function it binds at the top level to a name starting with "test" is
+// called in order, in a fresh interpreter for each file. A test fails if it
+// raises an error. The arguments after "--" are the os.args of each file.
+func (c *cli) test(args []string) int {
+	flags := c.flagSet("test")
+	if status, ok := parseFlags(flags, args); !ok {
+		return status
+	}
+	paths, programArgs := flags.Args(), []string{}
+	for i, path := range paths {
+		if path == "--" {
+			paths, programArgs = paths[:i], paths[i+1:]
+			break
+		}
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := findFiles(paths, TEST_FILE_SUFFIX)
+	if err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return EXIT_USAGE
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(c.stderr, "No test files found")
+		return EXIT_OK
+	}
+
+	status := EXIT_OK
+	for _, file := range files {
+		if !c.testFile(file, programArgs) {
+			status = EXIT_ERROR
+		}
+	}
+	return status
+}
+
+// testFile runs the tests in file, reporting the result to stdout and the
+// errors of the tests that fail to stderr, and reports whether all passed
+func (c *cli) testFile(file string, args []string) bool {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		fmt.Fprintf(c.stdout, "FAIL\t%s\n", file)
+		fmt.Fprintln(c.stderr, err)
+		return false
+	}
+
+	interpreter := sonar.New()
+	interpreter.Stdout = c.stdout
+	interpreter.Stderr = c.stderr
+	interpreter.Args = args
+	if _, err := interpreter.RunFile(context.Background(), path); err != nil {
+		fmt.Fprintf(c.stdout, "FAIL\t%s\n", file)
+		if _, ok := err.(*sonar.Error); !ok {
+			fmt.Fprintln(c.stderr, err)
+		}
+		return false
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(c.stdout, "FAIL\t%s\n", file)
+		fmt.Fprintln(c.stderr, err)
+		return false
+	}
+
+	// failures are reported by testFailed, without the call made here
+	interpreter.Stderr = nil
+	failed := 0
+	tests := testFunctions(string(src), path)
+	for _, name := range tests {
+		if _, err := interpreter.Run(context.Background(), name+"()"); err != nil {
+			c.testFailed(err, path, string(src))
+			fmt.Fprintf(c.stdout, "--- FAIL: %s\n", name)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(c.stdout, "FAIL\t%s\t%d of %s failed\n", file, failed, plural(len(tests), "test"))
+		return false
+	}
+	fmt.Fprintf(c.stdout, "ok\t%s\t%s\n", file, plural(len(tests), "test"))
+	return true
+}
+
+// testFailed reports err, raised by a test function in the file at path,
+// leaving out the outermost frame of its traceback: the call to the test
+func (c *cli) testFailed(err error, path string, src string) {
+	sonarErr, ok := err.(*sonar.Error)
+	if !ok || len(sonarErr.Errors) == 0 {
+		fmt.Fprintln(c.stderr, err)
+		return
+	}
+
+	traceback := sonarErr.Traceback
+	if len(traceback) > 0 {
+		traceback = traceback[:len(traceback)-1]
+	}
+	report := diagnostics.New(c.stderr)
+	report.RuntimeError(&object.Error{Conf: sonarErr.Errors[0], Traceback: traceback}, path, src)
+	for _, related := range sonarErr.Errors[1:] {
+		fmt.Fprintln(c.stderr)
+		report.Error(related)
+	}
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// testFunctions returns the names the program src, read from path, binds
+// functions to at the top level that start with "test", in source order
+func testFunctions(src string, path string) []string {
+	program := parser.New(lexer.New(src, &lexer.LexerOptions{Path: path})).ParseProgram()
+
+	names := []string{}
+	for _, stmt := range program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			stmt = export.Statement
+		}
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || !strings.HasPrefix(let.Name.Value, "test") {
+			continue
+		}
+		if _, ok := let.Value.(*ast.FunctionLiteral); ok {
+			names = append(names, let.Name.Value)
+		}
+	}
+	return names
+}
+
+// findFiles returns the files in paths, and the files whose names end with
+// suffix in the directories in paths and the directories below them, sorted
+// by path
+func findFiles(paths []string, suffix string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() && strings.HasSuffix(file, suffix) {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
@@ -12,6 +12,13 @@ const (
 	FLOAT  = "FLOAT"      // 3.142
 	STRING = "STRING"     // "foobar"
 
+	// Strings with interpolations are split where the interpolated expressions
+	// are: "a ${b} c ${d} e" is TEMPLATE_HEAD("a "), b, TEMPLATE_MIDDLE(" c "),
+	// d and TEMPLATE_TAIL(" e")
+	TEMPLATE_HEAD   = "TEMPLATE_HEAD"
+	TEMPLATE_MIDDLE = "TEMPLATE_MIDDLE"
+	TEMPLATE_TAIL   = "TEMPLATE_TAIL"
+
 	// Operators
 	PLUS     = "+"
 	MINUS    = "-"
@@ -38,6 +45,9 @@ const (
 	AND = "and"
 	OR  = "or"
 
+	NULLISH        = "??" // a ?? b is b if a is null, and a otherwise
+	OPTIONAL_CHAIN = "?." // a?.[i], f?.() and a?.name are null if a or f is
+
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
@@ -54,8 +64,10 @@ const (
 	// Keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
+	NULL     = "NULL"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
@@ -64,18 +76,64 @@ const (
 	IN       = "IN"
 	BREAK    = "BREAK"
 	CONTINUE = "CONTINUE"
+	THROW    = "THROW"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
+)
+
+// Contextual keywords are lexed as identifiers and only have a meaning in
+// import statements, so that they can still be used as names elsewhere
+const (
+	FROM = "from"
+	AS   = "as"
 )
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Span    Span
+}
+
+// Position is a location in source code. Offset is a zero-based byte offset
+// into the input; Line and Column are one-based, with Column counted in bytes.
+type Position struct {
+	File   string
+	Offset int
+	Line   int
+	Column int
+}
+
+// IsValid reports whether the position was set by the lexer
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// Span is the range of source code a token or AST node covers, from the
+// first byte (Start) up to but not including End.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// To returns a span from the start of s to the end of other
+func (s Span) To(other Span) Span {
+	return Span{Start: s.Start, End: other.End}
+}
+
+// Comment is a comment the lexer skipped, including its delimiters
+type Comment struct {
+	Text string
+	Span Span
 }
 
 var keywords = map[string]TokenType{
 	"func":     FUNCTION,
 	"let":      LET,
+	"const":    CONST,
 	"true":     TRUE,
 	"false":    FALSE,
+	"null":     NULL,
 	"if":       IF,
 	"else":     ELSE,
 	"return":   RETURN,
@@ -86,6 +144,12 @@ var keywords = map[string]TokenType{
 	"in":       IN,
 	"break":    BREAK,
 	"continue": CONTINUE,
+	"throw":    THROW,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"import":   IMPORT,
+	"export":   EXPORT,
 }
 
 func LookupIdent(ident string) TokenType {
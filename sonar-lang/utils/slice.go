@@ -51,49 +51,6 @@ func SortObjectArray(arr *object.Array) *object.Array {
 	return &object.Array{Elements: elements}
 }
 
-func SortObjectArrayWithFunction(arr *object.Array) [][]object.Object {
-	args := [][]object.Object{}
-
-	for i, v := range arr.Elements {
-		ownArr := interface{}(arr.Elements).(*object.Object)
-		arg := []object.Object{}
-
-		if i == len(arr.Elements)-1 {
-			arg = []object.Object{
-				v,
-				v,
-				*ownArr,
-			}
-		} else {
-			arg = []object.Object{
-				&object.Integer{Value: int64(i)},
-				v,
-				arr.Elements[i+1],
-				*ownArr,
-			}
-		}
-		args = append(args, arg)
-	}
-
-	return args
-}
-
-func ObjectArrayEqual(arr1, arr2 *object.Array) bool {
-	len1 := len(arr1.Elements)
-	len2 := len(arr2.Elements)
-	if len1 != len2 {
-		return false
-	}
-
-	for i, v := range arr1.Elements {
-		elm := arr2.Elements[i]
-		if elm.Type() != v.Type() || elm.Inspect() != v.Inspect() {
-			return false
-		}
-	}
-	return true
-}
-
 func ReverseSlice[T ~[]E, E any](arr T) T {
 	S := make(T, len(arr))
 	copy(S, arr)
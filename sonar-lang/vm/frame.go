@@ -0,0 +1,36 @@
+package vm
+
+import (
+	"github.com/icheka/sonar-lang/sonar-lang/code"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+	"github.com/icheka/sonar-lang/sonar-lang/token"
+)
+
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
+
+// Position returns the position in the source code of the instruction being
+// executed
+func (f *Frame) Position() token.Position {
+	return f.cl.Fn.Positions.At(f.ip)
+}
+
+// Function returns the name of the function the frame is a call to, as
+// tracebacks show it
+func (f *Frame) Function() string {
+	if len(f.cl.Fn.Name) == 0 {
+		return object.ANONYMOUS_FUNCTION
+	}
+	return f.cl.Fn.Name
+}
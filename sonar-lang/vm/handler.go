@@ -0,0 +1,25 @@
+package vm
+
+import (
+	"strconv"
+
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+)
+
+const RETURN_ADDRESS_OBJ = "RETURN_ADDRESS"
+
+// handler is where an error raised inside a try statement jumps to: the
+// frame and stack pointer it was set up with, and the position of its catch
+// or finally block in that frame
+type handler struct {
+	frame int
+	sp    int
+	ip    int
+}
+
+// returnAddress is the position a finally block returns to once it ends. It
+// lives on the stack while the block runs and is never seen by user code.
+type returnAddress int
+
+func (r returnAddress) Type() object.ObjectType { return RETURN_ADDRESS_OBJ }
+func (r returnAddress) Inspect() string         { return strconv.Itoa(int(r)) }
@@ -0,0 +1,33 @@
+package vm
+
+import "github.com/icheka/sonar-lang/sonar-lang/object"
+
+const ITERATOR_OBJ = "ITERATOR"
+
+// iterator walks the elements of an object.Iterable during a for loop. It
+// lives on the stack for the duration of the loop and is never seen by user code.
+type iterator struct {
+	items []object.Object
+	index int
+	hash  bool
+}
+
+func (it *iterator) Type() object.ObjectType { return ITERATOR_OBJ }
+func (it *iterator) Inspect() string         { return "iterator" }
+
+func (it *iterator) done() bool { return it.index >= len(it.items) }
+
+// next returns the loop's counter and value for the current element. Like the
+// evaluator, maps yield their keys and values as strings.
+func (it *iterator) next() (object.Object, object.Object) {
+	item := it.items[it.index]
+	counter := object.Object(&object.Integer{Value: int64(it.index)})
+	it.index++
+
+	if it.hash {
+		pair := item.(*object.Array).Elements
+		return &object.String{Value: pair[0].Inspect()}, &object.String{Value: pair[1].Inspect()}
+	}
+
+	return counter, item
+}
@@ -0,0 +1,877 @@
+package vm
+
+import (
+	"path/filepath"
+
+	"github.com/icheka/sonar-lang/sonar-lang/ast"
+	"github.com/icheka/sonar-lang/sonar-lang/code"
+	"github.com/icheka/sonar-lang/sonar-lang/compiler"
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/evaluator"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+	"github.com/icheka/sonar-lang/sonar-lang/token"
+)
+
+const (
+	StackSize    = 1 << 11 // the initial size of the stack, which grows as needed
+	MaxStackSize = 1 << 22
+	GlobalsSize  = 1 << 16
+	// the main frame and as many calls as the evaluator allows
+	MaxFrames = object.DEFAULT_MAX_CALL_DEPTH + 1
+)
+
+// the operator evaluator.EvalInfix applies for each binary opcode
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:          token.PLUS,
+	code.OpSub:          token.MINUS,
+	code.OpMul:          token.ASTERISK,
+	code.OpDiv:          token.SLASH,
+	code.OpEqual:        token.EQ,
+	code.OpNotEqual:     token.NOT_EQ,
+	code.OpGreaterThan:  token.GT,
+	code.OpLessThan:     token.LT,
+	code.OpGreaterEqual: token.GTE,
+	code.OpLessEqual:    token.LTE,
+	code.OpIn:           token.IN,
+}
+
+type VM struct {
+	unit *object.Unit
+
+	stack []object.Object
+	sp    int // Always points to the next value. Top of stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	handlers []handler
+
+	result   object.Object
+	args     []string
+	builtins map[string]*object.Builtin
+
+	modules *object.Modules
+	// where the module the VM runs was imported, or the zero position for
+	// the program
+	callSite token.Position
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobalsStore(bytecode, make([]object.Object, GlobalsSize))
+}
+
+// NewWithGlobalsStore returns a VM that keeps its globals in s, so that they
+// outlive it, as a REPL needs.
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, Positions: bytecode.Positions}
+	unit := &object.Unit{Constants: bytecode.Constants, Globals: s, GlobalNames: bytecode.Globals}
+	mainClosure := &object.Closure{Fn: mainFn, Unit: unit}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	return &VM{
+		unit: unit,
+
+		stack: make([]object.Object, StackSize),
+		sp:    0,
+
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// Run executes the program and returns what evaluator.Eval would: the value
+// of the last statement, the value of a top-level return, or an *object.Error.
+func (vm *VM) Run() object.Object {
+	if err := vm.run(1); err != nil {
+		return err
+	}
+	return vm.result
+}
+
+// run executes instructions until the frame at depth returns, or the program
+// ends if depth is that of the main frame
+func (vm *VM) run(depth int) *object.Error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.framesIndex >= depth && vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		var err *object.Error
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err = vm.push(vm.currentFrame().cl.Unit.Constants[constIndex])
+
+		case code.OpPop:
+			vm.result = vm.pop()
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpGreaterEqual, code.OpLessEqual, code.OpIn:
+			err = vm.executeBinaryOperation(op)
+
+		case code.OpBang:
+			err = vm.pushResult(evaluator.EvalPrefix(token.BANG, vm.pop()))
+
+		case code.OpMinus:
+			err = vm.pushResult(evaluator.EvalPrefix(token.MINUS, vm.pop()))
+
+		case code.OpIncrement, code.OpDecrement:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err = vm.executePostfixOperation(op, vm.currentFrame().cl.Unit.Constants[constIndex].Inspect())
+
+		case code.OpTrue:
+			err = vm.push(evaluator.TRUE)
+
+		case code.OpFalse:
+			err = vm.push(evaluator.FALSE)
+
+		case code.OpNull:
+			err = vm.push(evaluator.NULL)
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if !evaluator.IsTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpAnd, code.OpOr:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if evaluator.IsTruthy(vm.stack[vm.sp-1]) == (op == code.OpOr) {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
+
+		case code.OpNullish:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if vm.stack[vm.sp-1].Type() != object.NULL_OBJ {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
+
+		case code.OpJumpNull:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if vm.stack[vm.sp-1].Type() == object.NULL_OBJ {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.currentFrame().cl.Unit.Globals[globalIndex] = vm.pop()
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			value := vm.currentFrame().cl.Unit.Globals[globalIndex]
+			if value == nil {
+				err = vm.notDefined(vm.globalName(int(globalIndex)))
+				break
+			}
+			err = vm.push(value)
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			vm.stack[vm.currentFrame().basePointer+int(localIndex)] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			value := vm.stack[vm.currentFrame().basePointer+int(localIndex)]
+			if value == nil {
+				err = vm.notDefined(vm.localName(int(localIndex)))
+				break
+			}
+			err = vm.push(value)
+
+		case code.OpSetCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			slot := &vm.stack[vm.currentFrame().basePointer+int(localIndex)]
+			if cell, ok := (*slot).(*object.Cell); ok {
+				cell.Value = vm.pop()
+			} else {
+				*slot = &object.Cell{Value: vm.pop()}
+			}
+
+		case code.OpGetCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			cell, ok := vm.stack[vm.currentFrame().basePointer+int(localIndex)].(*object.Cell)
+			if !ok || cell.Value == nil {
+				err = vm.notDefined(vm.localName(int(localIndex)))
+				break
+			}
+			err = vm.push(cell.Value)
+
+		case code.OpLoadCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			slot := &vm.stack[vm.currentFrame().basePointer+int(localIndex)]
+			cell, ok := (*slot).(*object.Cell)
+			if !ok {
+				// the variable is captured before it is bound, as by a recursive function
+				cell = &object.Cell{Value: *slot}
+				*slot = cell
+			}
+			err = vm.push(cell)
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			cell := vm.currentFrame().cl.Free[freeIndex].(*object.Cell)
+			if cell.Value == nil {
+				err = vm.notDefined("")
+				break
+			}
+			err = vm.push(cell.Value)
+
+		case code.OpLoadFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err = vm.push(vm.currentFrame().cl.Free[freeIndex])
+
+		case code.OpGetShadow:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			pos := int(code.ReadUint16(ins[ip+2:]))
+			vm.currentFrame().ip += 3
+
+			value := vm.stack[vm.currentFrame().basePointer+int(localIndex)]
+			if cell, ok := value.(*object.Cell); ok {
+				value = cell.Value
+			}
+			if value != nil {
+				vm.currentFrame().ip = pos - 1
+				err = vm.push(value)
+			}
+
+		case code.OpGetFreeShadow:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			pos := int(code.ReadUint16(ins[ip+2:]))
+			vm.currentFrame().ip += 3
+
+			if value := vm.currentFrame().cl.Free[freeIndex].(*object.Cell).Value; value != nil {
+				vm.currentFrame().ip = pos - 1
+				err = vm.push(value)
+			}
+
+		case code.OpGetName:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err = vm.executeGetName(vm.currentFrame().cl.Unit.Constants[constIndex].Inspect())
+
+		case code.OpSetName:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			unit := vm.currentFrame().cl.Unit
+			name := unit.Constants[constIndex].Inspect()
+			globalIndex, ok := unit.GlobalNames[name]
+			if !ok || unit.Globals[globalIndex] == nil {
+				err = vm.notDefined(name)
+				break
+			}
+			unit.Globals[globalIndex] = vm.pop()
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp = vm.sp - numElements
+
+			err = vm.push(&object.Array{Elements: elements})
+
+		case code.OpConcat:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			result := evaluator.EvalConcat(vm.stack[vm.sp-numParts : vm.sp])
+			vm.sp = vm.sp - numParts
+
+			err = vm.push(result)
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			var hash object.Object
+			hash, err = vm.buildHash(vm.sp-numElements, vm.sp)
+			if err != nil {
+				break
+			}
+			vm.sp = vm.sp - numElements
+
+			err = vm.push(hash)
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			err = vm.pushResult(evaluator.EvalIndex(left, index))
+
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			err = vm.pushResult(evaluator.EvalIndexAssignment(left, index, value))
+
+		case code.OpIter:
+			err = vm.executeIter()
+
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			it := vm.stack[vm.sp-1].(*iterator)
+			if it.done() {
+				vm.currentFrame().ip = pos - 1
+				break
+			}
+			counter, value := it.next()
+			if err = vm.push(counter); err != nil {
+				break
+			}
+			err = vm.push(value)
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			err = vm.pushClosure(int(constIndex), int(numFree))
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err = vm.executeCall(int(numArgs))
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			if vm.framesIndex == 1 {
+				// a top-level return ends the program
+				vm.result = returnValue
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			err = vm.push(returnValue)
+
+		case code.OpThrow:
+			err = evaluator.EvalThrow(vm.pop())
+
+		case code.OpRethrow:
+			err = vm.pop().(*object.Error)
+
+		case code.OpTry:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			// keep room for the error the handler is given
+			if err = vm.reserve(1); err != nil {
+				break
+			}
+			vm.handlers = append(vm.handlers, handler{frame: vm.framesIndex, sp: vm.sp, ip: pos})
+
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		case code.OpCatch:
+			vm.stack[vm.sp-1] = evaluator.CaughtError(vm.stack[vm.sp-1].(*object.Error))
+
+		case code.OpFinally:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			err = vm.push(returnAddress(vm.currentFrame().ip))
+			vm.currentFrame().ip = pos - 1
+
+		case code.OpEndFinally:
+			vm.currentFrame().ip = int(vm.pop().(returnAddress))
+
+		case code.OpImport:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			err = vm.executeImport(vm.currentFrame().cl.Unit.Constants[constIndex].Inspect())
+
+		case code.OpImportName:
+			pathIndex := code.ReadUint16(ins[ip+1:])
+			nameIndex := code.ReadUint16(ins[ip+3:])
+			vm.currentFrame().ip += 4
+
+			constants := vm.currentFrame().cl.Unit.Constants
+			path, name := constants[pathIndex].Inspect(), constants[nameIndex].Inspect()
+			value, ok := vm.stack[vm.sp-1].(*object.Module).Exports[name]
+			if !ok {
+				err = evaluator.NewError(errors.NotExportedError(path, name, errors.ErrorConfig{}))
+				break
+			}
+			err = vm.push(value)
+		}
+
+		if err != nil && !vm.handle(err, depth) {
+			vm.unwind(err, depth)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unwind locates err, if it was raised without a position, at the instruction
+// that raised it, and records the calls it propagates out of in its
+// traceback, down to the frame at depth, as the evaluator does
+func (vm *VM) unwind(err *object.Error, depth int) {
+	err.SetPosition(vm.currentFrame().Position())
+
+	for vm.framesIndex >= depth {
+		frame := vm.popFrame()
+		if vm.framesIndex == 0 {
+			err.Unwind(object.MODULE_FRAME, vm.callSite)
+		} else {
+			err.Unwind(frame.Function(), vm.currentFrame().Position())
+		}
+	}
+}
+
+// handle jumps to the handler of the innermost try statement err is raised
+// in, if it was set up by one of the frames from depth, with err on top of the
+// stack. The calls err propagates out of are recorded in its traceback, should
+// it be raised again.
+func (vm *VM) handle(err *object.Error, depth int) bool {
+	if len(vm.handlers) == 0 {
+		return false
+	}
+	h := vm.handlers[len(vm.handlers)-1]
+	if h.frame < depth {
+		return false
+	}
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	err.SetPosition(vm.currentFrame().Position())
+	for vm.framesIndex > h.frame {
+		frame := vm.popFrame()
+		err.Unwind(frame.Function(), vm.currentFrame().Position())
+	}
+
+	vm.sp = h.sp
+	vm.currentFrame().ip = h.ip - 1
+	vm.stack[vm.sp] = err
+	vm.sp++
+	return true
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) *object.Error {
+	right := vm.pop()
+	left := vm.pop()
+
+	// integer arithmetic is by far the most common case, so skip the evaluator for it
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			switch op {
+			case code.OpAdd:
+				return vm.push(&object.Integer{Value: l.Value + r.Value})
+			case code.OpSub:
+				return vm.push(&object.Integer{Value: l.Value - r.Value})
+			case code.OpMul:
+				return vm.push(&object.Integer{Value: l.Value * r.Value})
+			case code.OpEqual:
+				return vm.push(nativeBoolToBooleanObject(l.Value == r.Value))
+			case code.OpNotEqual:
+				return vm.push(nativeBoolToBooleanObject(l.Value != r.Value))
+			case code.OpGreaterThan:
+				return vm.push(nativeBoolToBooleanObject(l.Value > r.Value))
+			case code.OpLessThan:
+				return vm.push(nativeBoolToBooleanObject(l.Value < r.Value))
+			case code.OpGreaterEqual:
+				return vm.push(nativeBoolToBooleanObject(l.Value >= r.Value))
+			case code.OpLessEqual:
+				return vm.push(nativeBoolToBooleanObject(l.Value <= r.Value))
+			}
+		}
+	}
+
+	return vm.pushResult(evaluator.EvalInfix(infixOperators[op], left, right))
+}
+
+func (vm *VM) executePostfixOperation(op code.Opcode, literal string) *object.Error {
+	operator := token.POST_INCR
+	step := int64(1)
+	if op == code.OpDecrement {
+		operator = token.POST_DECR
+		step = -1
+	}
+
+	integer, ok := vm.pop().(*object.Integer)
+	if !ok {
+		return evaluator.NewError(errors.UnacceptableLHSInPostfixExpression(operator, literal, errors.ErrorConfig{}))
+	}
+
+	return vm.push(&object.Integer{Value: integer.Value + step})
+}
+
+func (vm *VM) executeGetName(name string) *object.Error {
+	unit := vm.currentFrame().cl.Unit
+	if globalIndex, ok := unit.GlobalNames[name]; ok && unit.Globals[globalIndex] != nil {
+		return vm.push(unit.Globals[globalIndex])
+	}
+
+	if builtin, ok := vm.lookupBuiltin(name); ok {
+		return vm.push(builtin)
+	}
+
+	if name == evaluator.OS_MODULE {
+		return vm.push(evaluator.NewOSModule(vm.args))
+	}
+	if constant, ok := evaluator.LookupConstant(name); ok {
+		return vm.push(constant)
+	}
+
+	return vm.notDefined(name)
+}
+
+func (vm *VM) executeImport(path string) *object.Error {
+	callSite := vm.currentFrame().Position()
+
+	// only the program's VM has yet to load a module. Like the evaluator, the
+	// file being run counts as a module being evaluated, so that modules
+	// importing it are reported as a cycle.
+	if vm.modules == nil {
+		vm.modules = object.NewModules()
+		if abs, err := filepath.Abs(callSite.File); len(callSite.File) > 0 && err == nil {
+			vm.modules.Begin(abs)
+		}
+	}
+	return vm.pushResult(evaluator.LoadModule(path, callSite, vm.modules, func(program *ast.Program) (map[string]object.Object, *object.Error) {
+		return vm.runModule(program, callSite)
+	}))
+}
+
+// runModule runs the program of an imported module on a VM of its own, which
+// shares the modules, builtins and arguments of this one, and returns the
+// values of the names it exports
+func (vm *VM) runModule(program *ast.Program, callSite token.Position) (map[string]object.Object, *object.Error) {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		// like the evaluator, which finds the error running the module
+		moduleErr := &object.Error{Conf: err}
+		moduleErr.Unwind(object.MODULE_FRAME, callSite)
+		return nil, moduleErr
+	}
+	bytecode := comp.Bytecode()
+
+	module := New(bytecode)
+	module.args, module.builtins, module.modules = vm.args, vm.builtins, vm.modules
+	module.callSite = callSite
+	if err, ok := module.Run().(*object.Error); ok {
+		return nil, err
+	}
+
+	exports := make(map[string]object.Object)
+	for _, name := range bytecode.Exports {
+		if value := module.unit.Globals[bytecode.Globals[name]]; value != nil {
+			exports[name] = value
+		}
+	}
+	return exports, nil
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, *object.Error) {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := object.HashKeyOf(key)
+		if !ok {
+			return nil, evaluator.NewError(errors.UnusableAsHashKeyError(key.Inspect(), errors.ErrorConfig{}))
+		}
+
+		hash.Set(hashKey, object.HashPair{Key: key, Value: value})
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) executeIter() *object.Error {
+	obj := vm.pop()
+
+	iterable, ok := obj.(object.Iterable)
+	if !ok {
+		return evaluator.NewError(errors.NonIterableInForLoopError(errors.ErrorConfig{}))
+	}
+
+	return vm.push(&iterator{
+		items: iterable.Iters(),
+		hash:  obj.Type() == object.HASH_OBJ,
+	})
+}
+
+func (vm *VM) executeCall(numArgs int) *object.Error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return evaluator.NewError(errors.TypeError(callee.Inspect(), object.FUNCTION_OBJ))
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) *object.Error {
+	// like the evaluator, missing arguments are null and extra ones are ignored
+	for numArgs < cl.Fn.NumParameters {
+		if err := vm.push(evaluator.NULL); err != nil {
+			return err
+		}
+		numArgs++
+	}
+	vm.sp -= numArgs - cl.Fn.NumParameters
+
+	if vm.framesIndex >= MaxFrames {
+		// like the evaluator, trace the error to the function called, though
+		// it never started
+		err := evaluator.NewError(errors.StackOverflowError())
+		err.SetPosition(vm.currentFrame().Position())
+		err.Unwind(NewFrame(cl, 0).Function(), vm.currentFrame().Position())
+		return err
+	}
+
+	basePointer := vm.sp - cl.Fn.NumParameters
+	if err := vm.reserve(cl.Fn.NumLocals - cl.Fn.NumParameters); err != nil {
+		return err
+	}
+
+	frame := NewFrame(cl, basePointer)
+	vm.pushFrame(frame)
+
+	// clear the slots of locals, which may hold values or cells of an earlier call
+	for i := vm.sp; i < basePointer+cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = basePointer + cl.Fn.NumLocals
+
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) *object.Error {
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+
+	result := builtin.Fn(vm, args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	if result == nil {
+		return vm.push(evaluator.NULL)
+	}
+	return vm.pushResult(result)
+}
+
+// SetArgs sets the arguments os.args gives the program
+func (vm *VM) SetArgs(args []string) {
+	vm.args = args
+}
+
+// SetBuiltins gives the program its own builtin functions, replacing the
+// default ones
+func (vm *VM) SetBuiltins(builtins map[string]*object.Builtin) {
+	vm.builtins = builtins
+}
+
+func (vm *VM) lookupBuiltin(name string) (*object.Builtin, bool) {
+	if vm.builtins != nil {
+		builtin, ok := vm.builtins[name]
+		return builtin, ok
+	}
+	return evaluator.LookupBuiltin(name)
+}
+
+// Allocate does nothing, as programs run on the VM have no limits
+func (vm *VM) Allocate(elements, stringBytes int64) *object.Error {
+	return nil
+}
+
+// Call runs fn with args to completion and returns its result, so that
+// builtins can call the functions they are given
+func (vm *VM) Call(fn object.Object, args ...object.Object) object.Object {
+	sp := vm.sp
+
+	if err := vm.push(fn); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if err := vm.push(arg); err != nil {
+			return err
+		}
+	}
+
+	if err := vm.executeCall(len(args)); err != nil {
+		vm.sp = sp
+		return err
+	}
+
+	// a closure has pushed a frame, which returns its result to the stack
+	if _, ok := fn.(*object.Closure); ok {
+		if err := vm.run(vm.framesIndex); err != nil {
+			vm.sp = sp
+			return err
+		}
+	}
+
+	result := vm.pop()
+	vm.sp = sp
+	return result
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) *object.Error {
+	unit := vm.currentFrame().cl.Unit
+	function := unit.Constants[constIndex].(*object.CompiledFunction)
+
+	free := make([]object.Object, numFree)
+	copy(free, vm.stack[vm.sp-numFree:vm.sp])
+	vm.sp = vm.sp - numFree
+
+	return vm.push(&object.Closure{Fn: function, Free: free, Unit: unit})
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) push(o object.Object) *object.Error {
+	if vm.sp >= len(vm.stack) {
+		if err := vm.reserve(1); err != nil {
+			return err
+		}
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+// reserve grows the stack, if needed, to hold n more values
+func (vm *VM) reserve(n int) *object.Error {
+	needed := vm.sp + n
+	if needed <= len(vm.stack) {
+		return nil
+	}
+	if needed > MaxStackSize {
+		return evaluator.NewError(errors.StackOverflowError())
+	}
+
+	size := len(vm.stack) * 2
+	for size < needed {
+		size *= 2
+	}
+	if size > MaxStackSize {
+		size = MaxStackSize
+	}
+
+	stack := make([]object.Object, size)
+	copy(stack, vm.stack[:vm.sp])
+	vm.stack = stack
+	return nil
+}
+
+// pushResult pushes the result of an operation, unless it is an error
+func (vm *VM) pushResult(o object.Object) *object.Error {
+	if err, ok := o.(*object.Error); ok {
+		return err
+	}
+	return vm.push(o)
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func (vm *VM) notDefined(name string) *object.Error {
+	return evaluator.NewError(errors.IdentifierNotDefinedError(name, errors.ErrorConfig{}))
+}
+
+func (vm *VM) globalName(index int) string {
+	for name, i := range vm.currentFrame().cl.Unit.GlobalNames {
+		if i == index {
+			return name
+		}
+	}
+	return ""
+}
+
+func (vm *VM) localName(index int) string {
+	names := vm.currentFrame().cl.Fn.LocalNames
+	if index < len(names) {
+		return names[index]
+	}
+	return ""
+}
+
+func nativeBoolToBooleanObject(native bool) *object.Boolean {
+	if native {
+		return evaluator.TRUE
+	}
+	return evaluator.FALSE
+}
@@ -0,0 +1,1301 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/icheka/sonar-lang/sonar-lang/compiler"
+	"github.com/icheka/sonar-lang/sonar-lang/errors"
+	"github.com/icheka/sonar-lang/sonar-lang/evaluator"
+	"github.com/icheka/sonar-lang/sonar-lang/lexer"
+	"github.com/icheka/sonar-lang/sonar-lang/object"
+	"github.com/icheka/sonar-lang/sonar-lang/parser"
+	"github.com/icheka/sonar-lang/sonar-lang/utils"
+)
+
+func TestContinueStatement(t *testing.T) {
+	input := `
+let j = []
+let i = 0
+for (i, v in range(1, 4)) {
+	if (v == 2) {
+		continue
+	}
+	j = push(j, i)
+}
+len(j)
+`
+	testEvalInteger(t, input, 2)
+}
+
+func TestBreakStatement(t *testing.T) {
+	input := `
+let i = 0
+let j = 0
+while (i < 5) {
+	i++
+	j = i
+	if (i == 2) {
+		break
+	}
+}
+j
+`
+	testEvalInteger(t, input, 2)
+
+	input = `
+let j = 0
+for (i,_ in range(1, 6)) {
+	j = i
+	if (i == 2) {
+		break
+	}
+}
+j
+`
+	testEvalInteger(t, input, 2)
+}
+
+func TestDeleteMapKeyExpression(t *testing.T) {
+	input := `
+let m  = {1: 2}
+m - 1
+`
+	testEvalType[*object.Hash](t, testEval(input).Inspect(), "{}")
+}
+
+func TestSquareBracketAssignmentExpression(t *testing.T) {
+	input := "[1,2,3][0] = 4"
+	testEvalType[*object.Array](t, testEval(input).Inspect(), "[4, 2, 3]")
+
+	// an out-of-bounds index operation	returns error
+	for _, input := range []string{"[1,2,3][9] = 4", "[1,2,3][3] = 4", "let a = []; a[0] = 1", "[1][-2] = 1"} {
+		evaluated := testEval(input)
+		if _, ok := evaluated.(*object.Error); !ok {
+			t.Fatalf("%s: expected evaluated to be ERROR, got=%T", input, evaluated)
+		}
+	}
+
+	input = `
+let m = {1: 1}
+m[1] = 10
+`
+	testEvalType[*object.Hash](t, testEval(input).Inspect(), `{1: 10}`)
+}
+
+func TestAssignmentExpression(t *testing.T) {
+	input := `
+let a = 1
+a = 2
+`
+
+	testIntegerObject(t, testEval(input), 2)
+
+	// test that attempting to assign to a variable before it is declared throws error
+	errorTest := "a = 2"
+	evaluated := testEval(errorTest)
+	if _, isErr := evaluated.(*object.Error); !isErr {
+		t.Fatalf("expected evaluated to be error, got=%T", evaluated)
+	}
+
+	// test double-operator assignments
+	tests := []struct {
+		input         string
+		expectedValue interface{}
+	}{
+		{"a += 1", 1},
+		{"a -= 1", -1},
+		{"a *= 2", 0},
+		{"a /= 1", 0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(fmt.Sprintf("let a = 0; %s", tt.input))
+		switch v := tt.expectedValue.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(v))
+		case int64:
+			testIntegerObject(t, evaluated, v)
+		case float64:
+			testFloatObject(t, evaluated, v)
+		case string:
+			testStringObject(t, evaluated, v)
+		}
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `
+let i = 0
+while (i < 3) {
+	i++
+}
+i
+`
+	// test that i is a variable with value = 2
+	testIntegerObject(t, testEval(input), 3)
+}
+
+func TestForStatement(t *testing.T) {
+	input := `
+let j = 0
+for (i, v in [0, 2]) {
+	j = 1
+}
+j
+`
+	testIntegerObject(t, testEval(input), 1)
+
+	input = `
+let j = 0
+for (i, v in "Icheka") {
+	j = v
+}
+j
+`
+	testStringObject(t, testEval(input), "a")
+
+	input = `
+let j = 0
+for (k, v in {"name": "Icheka"}) {
+	j = k
+}
+j
+`
+	testStringObject(t, testEval(input), "name")
+
+	input = `
+let j = 0
+for (k, v in {"name": "Icheka"}) {
+	j = v
+}
+j
+`
+	testStringObject(t, testEval(input), "Icheka")
+}
+
+func TestEvalInfixExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"1 + 1", 2},
+		{"1 - 1", 0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if !(testIntegerObject(t, evaluated, tt.expected) || testStringObject(t, evaluated, tt.expected)) {
+			t.Fatalf("Unknown type, got %q, expected %q", evaluated.Type(), tt.expected)
+		}
+	}
+}
+
+func TestEvalPostfixExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"1++", 2},
+		{"0++", 1},
+		{"1--", 0},
+		{"0--", -1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"3 * (3 * 3) + 10", 37},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.0", 1},
+		{"-1.1", -1.1},
+		{"2.1 + 1.0", 3.1},
+		{"2.1 + 1", 3.1},
+		{"2.1 + 1.2", 3.3},
+		{"1 + 1.2", 2.2},
+		{"50 / 2 * 2 + 10.1", 60.1},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10.1", 49.9},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 > 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"false != true", true},
+		{"(1 < 2) == true", true},
+		{"(1 < 2) == false", false},
+		{"(1 > 2) == true", false},
+		{"(1 > 2) == false", true},
+		{"1 > 1.0", false},
+		{"1 > 1.2", false},
+		{"1 < 1.2", true},
+		{"1.3 < 1.2", false},
+		{"1.3 > 1.2", true},
+		{"true and false", false},
+		{"false and 2", false},
+		{"1 and true", true},
+		{"false or true", true},
+		{"false or false", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestLogicalExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		// the operand that decides the result is returned as it is
+		{"1 and 2", 2},
+		{"false and 2", false},
+		{"1 or 2", 1},
+		{"false or 0", 0},
+		{"let x = []; len(x) > 0 and x[0] == 1", false},
+		{"let a = [0]; let f = func() { a[0] = 1; true }; false and f(); a[0]", 0},
+		{"let a = [0]; let f = func() { a[0] = 1; true }; true or f(); a[0]", 0},
+		{"let a = [0]; let f = func() { a[0] = 1; true }; false or f(); a[0]", 1},
+		// right is not evaluated, so it cannot fail
+		{"false and 1 + true", false},
+		{"if (false or 0) { 10 } else { 20 }", 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestBangOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{"if (10 > 1) { return 10; }", 10},
+		{
+			`
+if (10 > 1) {
+  if (10 > 1) {
+    return 10;
+  }
+
+  return 1;
+}
+`,
+			10,
+		},
+		{
+			`
+let f = func(x) {
+  return x;
+  x + 10;
+};
+f(10);`,
+			10,
+		},
+		{
+			`
+let f = func(x) {
+   let result = x + 10;
+   return result;
+   return 10;
+};
+f(10);`,
+			20,
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	// test that calling let again on a variable in a scope will throw error
+	errorTest := "let a = 1; let a = 2;"
+	evaluated := testEval(errorTest)
+	if _, isErr := evaluated.(*object.Error); !isErr {
+		t.Fatalf("expected evaluated to be error, got=%T", evaluated)
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	input := "func(x) { x + 2; };"
+
+	evaluated := testEval(input)
+	cl, ok := evaluated.(*object.Closure)
+	if !ok {
+		t.Fatalf("object is not Closure. got=%T (%+v)", evaluated, evaluated)
+	}
+	fn := cl.Fn
+
+	if len(fn.Parameters) != 1 {
+		t.Fatalf("function has wrong parameters. Parameters=%+v",
+			fn.Parameters)
+	}
+
+	if fn.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", fn.Parameters[0])
+	}
+
+	expectedBody := "(x + 2)"
+
+	if fn.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, fn.Body.String())
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = func(x) { x; }; identity(5);", 5},
+		{"let identity = func(x) { return x; }; identity(5);", 5},
+		{"let double = func(x) { x * 2; }; double(5);", 10},
+		{"let add = func(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = func(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"func(x) { x; }(5)", 5},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestEnclosingEnvironments(t *testing.T) {
+	input := `
+let first = 10;
+let second = 10;
+let third = 10;
+
+let ourFunction = func(first) {
+  let second = 20;
+
+  first + second + third;
+};
+
+ourFunction(20) + first + second;`
+
+	testIntegerObject(t, testEval(input), 70)
+}
+
+func TestClosures(t *testing.T) {
+	input := `
+let newAdder = func(x) {
+  func(y) { x + y };
+};
+
+let addTwo = newAdder(2);
+addTwo(2);`
+
+	testIntegerObject(t, testEval(input), 4)
+
+	// as in the evaluator, assigning to a name of an enclosing scope binds it
+	// in the function, leaving the enclosing variable as it was
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let x = 1; let f = func() { x = 2 }; f(); x", 1},
+		{"let x = 1; let f = func() { x += 2; x++; x }; f() + f()", 8},
+		{"let x = 1; let f = func(c) { if (c) { x = 2 }; x }; f(false)", 1},
+		{"let newCounter = func() { let c = 0; func() { c = c + 1; c } }; let next = newCounter(); next(); next()", 1},
+		{"let f = func() { let n = 1; let g = func() { n }; n = 2; g() }; f()", 2},
+		{"let f = func() { let g = func() { x }; x = 5; g() }; let x = 1; f()", 5},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	if evaluated := testEval("let f = func() { x = 1 }; f()"); evaluated.Type() != object.ERROR_OBJ {
+		t.Errorf("expected assigning to an undefined name to be an error, got=%s", evaluated.Inspect())
+	}
+}
+
+func TestErrorTraceback(t *testing.T) {
+	input := `let f = func(n) {
+	n + "a"
+}
+let g = func() { map([1], f) }
+g()`
+
+	err, ok := testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("expected an error, got=%T", err)
+	}
+	if pos := err.Position(); pos.Line != 2 || pos.Column != 2 {
+		t.Errorf("expected the error at 2:2, got=%d:%d", pos.Line, pos.Column)
+	}
+
+	expected := []object.Frame{
+		{Function: "f", Line: 2},
+		{Function: "g", Line: 4},
+		{Function: object.MODULE_FRAME, Line: 5},
+	}
+	if len(err.Traceback) != len(expected) {
+		t.Fatalf("expected %d frames, got=%+v", len(expected), err.Traceback)
+	}
+	for i, frame := range expected {
+		if err.Traceback[i] != frame {
+			t.Errorf("expected frame %d to be %+v, got=%+v", i, frame, err.Traceback[i])
+		}
+	}
+}
+
+func TestRecursionDepth(t *testing.T) {
+	// as deep as the evaluator allows, however many locals each call has
+	input := "let r = func(n) { let a = n; let b = [a, a]; if (n == 0) { return 0 }; 1 + r(n - 1) }; r(%d)"
+	testIntegerObject(t, testEval(fmt.Sprintf(input, object.DEFAULT_MAX_CALL_DEPTH-1)), object.DEFAULT_MAX_CALL_DEPTH-1)
+
+	err, ok := testEval(fmt.Sprintf(input, object.DEFAULT_MAX_CALL_DEPTH)).(*object.Error)
+	if !ok || !strings.Contains(err.Inspect(), "Maximum call stack size exceeded") {
+		t.Fatalf("expected a stack overflow, got=%+v", err)
+	}
+	// one for each call, including the one that failed, and one for the program
+	if len(err.Traceback) != object.DEFAULT_MAX_CALL_DEPTH+2 {
+		t.Errorf("expected %d frames, got=%d", object.DEFAULT_MAX_CALL_DEPTH+2, len(err.Traceback))
+	}
+}
+
+func TestTryStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let x = 0; try { x = int("abc") } catch { x = -1 }; x`, -1},
+		{`let x = 0; try { x = int("12") } catch { x = -1 }; x`, 12},
+		{`try { throw "boom" } catch (e) { e["message"] }`, "boom"},
+		{`try { throw {"type": "ValueError", "message": "bad"} } catch (e) { e["type"] + ": " + e["message"] }`, "ValueError: bad"},
+		{`try { throw 42 } catch (e) { e["value"] }`, 42},
+		{"let f = func() {\n\tthrow \"inner\"\n}\ntry { f() } catch (e) { e[\"line\"] }", 2},
+		{`let f = func(x) { throw x }; try { each([1], f) } catch (e) { e["value"] }`, 1},
+		{`let log = ""; try { log += "try;" } finally { log += "finally;" }; log`, "try;finally;"},
+		{`let log = ""; try { throw "x" } catch { log += "catch;" } finally { log += "finally;" }; log`, "catch;finally;"},
+		{`let f = func() { try { return 1 } finally { return 2 } }; f()`, 2},
+		{`let f = func() { try { return 1 } catch { return 2 } }; f()`, 1},
+		{`let f = func() { try { throw 1 } catch { return 2 } finally { 3 } }; f()`, 2},
+		{`let n = 0; while (true) { try { break } finally { n += 1 } }; n`, 1},
+		{`let n = 0; for (i, v in [1, 2, 3]) { try { try { continue } finally { n += v } } finally { n += 10 } }; n`, 36},
+		{`let n = 0; while (n < 3) { try { throw n } catch { n += 1 } finally { if (n == 2) { break } } }; n`, 2},
+		{`let f = func() { try { throw "x" } finally { return 1 } }; f()`, 1},
+		{`try { try { throw "a" } finally { 1 } } catch (e) { e["message"] }`, "a"},
+		{`try { try { throw "a" } catch { throw "b" } } catch (e) { e["message"] }`, "b"},
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`let f = func() { try { 1 } catch { 2 } }; f()`, 1},
+		{`try { throw "boom" } catch (e) { str(e) }`, "{'type': 'Error', 'message': 'boom', 'file': '', 'line': 1, 'column': 7}"},
+	}
+
+	for _, tt := range tests {
+		switch expected := tt.expected.(type) {
+		case int:
+			testEvalType[*object.Integer](t, tt.input, expected)
+		case string:
+			testEvalType[*object.String](t, tt.input, expected)
+		}
+	}
+
+	// the catch parameter does not leak into the enclosing scope
+	if _, ok := testEval(`try { throw "x" } catch (e) { 1 }; e`).(*object.Error); !ok {
+		t.Errorf("expected e to be undefined after catch")
+	}
+
+	// errors without a catch block propagate after finally runs
+	err, ok := testEval(`let x = 0; try { throw "uncaught" } finally { x = 1 }`).(*object.Error)
+	if !ok {
+		t.Fatalf("expected uncaught error")
+	}
+	if pos := err.Position(); !strings.Contains(err.Inspect(), "uncaught") || pos.Line != 1 {
+		t.Errorf("expected 'uncaught' at line 1, got=%s", err.Inspect())
+	}
+}
+
+func TestImportStatement(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"lib/math.sonar": `export let add = func(a, b) { a + b }
+export let PI = 3
+export const TWO = 2
+let secret = 42`,
+		"lib/uses_math.sonar": `import "./math"
+export let m = math
+export let twice = func(x) { math["add"](x, x) }`,
+		"cycle_a.sonar":  `import "./cycle_b"`,
+		"cycle_b.sonar":  `import "./cycle_a"`,
+		"broken.sonar":   "export let x = 1\nlet y = x + \"a\"",
+		"exporter.sonar": `export let f = func() { 1 }`,
+	}
+	for name, source := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run := func(input string) object.Object {
+		l := lexer.New(input, &lexer.LexerOptions{Path: filepath.Join(dir, "main.sonar")})
+		p := parser.New(l)
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors: %+v", p.Errors())
+		}
+		evaluator.InitStdlib()
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			return &object.Error{Conf: err}
+		}
+		return New(comp.Bytecode()).Run()
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`import "./lib/math"; math["add"](1, 2)`, 3},
+		{`import "./lib/math" as m; m["PI"]`, 3},
+		{`import { add, PI } from "./lib/math"; add(PI, 1)`, 4},
+		{`import { twice } from "./lib/uses_math.sonar"; twice(5)`, 10},
+		{`import "./lib/math"; import "./lib/uses_math"; uses_math["m"] == math`, 1},
+		{`import { secret } from "./lib/math"`, "ImportError: Module './lib/math' does not export 'secret'"},
+		{`import "./lib/math"; math["secret"]`, "ImportError: Module 'math' does not export 'secret'"},
+		{`import "./missing"`, "ImportError: Cannot find module './missing' (looked for " + filepath.Join(dir, "missing.sonar") + ")"},
+		{`import "./cycle_a"`, "ImportError: Import cycle: cycle_a.sonar -> cycle_b.sonar -> cycle_a.sonar"},
+		{`import "./main"`, "ImportError: Import cycle: main.sonar -> main.sonar"},
+		{`let exporter = 1; import "./exporter"`, "SyntaxError: Identifier 'exporter' has already been defined"},
+		{`import { TWO } from "./lib/math"; TWO = 3`, "AssignmentError: Illegal assignment to constant 'TWO'"},
+		{`import "./lib/math" as m; m = 1`, "AssignmentError: Illegal assignment to constant 'm'"},
+		{`import { TWO } from "./lib/math"; let f = func() { let TWO = 3; TWO }; f() + TWO`, 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := run(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			if b, ok := evaluated.(*object.Boolean); ok {
+				testBooleanObject(t, b, expected == 1)
+				continue
+			}
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			err, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("expected error for %q, got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if conf := err.Conf.(errors.Error); conf.String() != expected {
+				t.Errorf("expected %q, got=%q", expected, conf.String())
+			}
+		}
+	}
+
+	// errors raised in a module are traced back to the import
+	err, ok := run("let x = 1\nimport \"./broken\"").(*object.Error)
+	if !ok {
+		t.Fatalf("expected error")
+	}
+	expected := []object.Frame{
+		{Function: object.MODULE_FRAME, File: filepath.Join(dir, "broken.sonar"), Line: 2},
+		{Function: object.MODULE_FRAME, File: filepath.Join(dir, "main.sonar"), Line: 2},
+	}
+	if len(err.Traceback) != len(expected) {
+		t.Fatalf("expected %d frames, got=%+v", len(expected), err.Traceback)
+	}
+	for i, frame := range expected {
+		if err.Traceback[i] != frame {
+			t.Errorf("frame %d: expected %+v, got=%+v", i, frame, err.Traceback[i])
+		}
+	}
+}
+
+func TestStringLiteral(t *testing.T) {
+	input := `"Hello World!"`
+
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringConcatenation(t *testing.T) {
+	input := `"Hello" + " " + "World!"`
+
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestTemplateStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Ada"; "Hello ${name}!"`, "Hello Ada!"},
+		{`let items = [1, 2]; "${len(items)} items: ${items}"`, "2 items: [1, 2]"},
+		{`"${1.5} ${true} ${{"a": 1}["a"]}"`, "1.5 true 1"},
+		{`let x = 2; "${"x is ${x}"}, twice ${x * 2}"`, "x is 2, twice 4"},
+		{`"a\tb\n\"c\" \\ \${x} \u{263A}"`, "a\tb\n\"c\" \\ ${x} ☺"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. expected=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+
+	if evaluated := testEval(`"${1 + "a"}"`); evaluated.Type() != object.ERROR_OBJ {
+		t.Errorf("expected an error, got=%s", evaluated.Inspect())
+	}
+}
+
+func TestUnicodeStrings(t *testing.T) {
+	testEvalType[*object.Integer](t, `len("héllo")`, 5)
+	testEvalType[*object.String](t, `"日本語"[-1]`, "語")
+	testEvalType[*object.String](t, `let out = ""; for (_, c in "aé😀") { out = c + out }; out`, "😀éa")
+	testEvalType[*object.Array](t, `runes("é")`, `[233]`)
+}
+
+func TestMathBuiltins(t *testing.T) {
+	testEvalType[*object.Integer](t, `pow(2, 10) + abs(-1)`, 1025)
+	testEvalType[*object.Integer](t, `max([3, 1, 7]) + MAX_INT - MAX_INT`, 7)
+	testEvalType[*object.Integer](t, `int(floor(PI))`, 3)
+}
+
+func TestMapsKeepInsertionOrder(t *testing.T) {
+	testEvalType[*object.String](t, `let m = {"z": 1, "a": 2}; m["m"] = 3; str(m)`, "{'z': 1, 'a': 2, 'm': 3}")
+	testEvalType[*object.String](t, `let s = ""; for (k, v in {"z": 1, "a": 2, "m": 3}) { s += k }; s`, "zam")
+	testEvalType[*object.String](t, `join(mapKeys({"z": 1, "a": 2, 3: 3}), ",")`, "z,a,3")
+}
+
+func TestStructuralEquality(t *testing.T) {
+	testEvalType[*object.Boolean](t, `[1, {"a": [2]}] == [1.0, {"a": [2]}]`, "true")
+	testEvalType[*object.Boolean](t, `{"a": 1, "b": 2} != {"b": 2, "a": 1}`, "false")
+	testEvalType[*object.Integer](t, `let m = {[1, 2]: 3, 1.5: 4}; m[[1, 2]] + m[1.5]`, 7)
+}
+
+func TestNullAndOptionalChaining(t *testing.T) {
+	testEvalType[*object.String](t, `{}["a"] ?? "default"`, "default")
+	testEvalType[*object.Integer](t, `0 ?? 1`, 0)
+	testEvalType[*object.Boolean](t, `let x = null; x == {}["a"]`, "true")
+	testEvalType[*object.Integer](t, `let m = {"a": {"b": 1}}; m?.["a"]?.b + (m.x?.b ?? 2)`, 3)
+	testEvalType[*object.Integer](t, `let f = null; let g = func(x) { x }; (f?.(1) ?? 1) + g?.(2)`, 3)
+	testEvalType[*object.Integer](t, `let a = [0]; let f = func() { a[0] = 1 }; let g = null; g?.(f()); a[0]`, 0)
+	testEvalType[*object.Integer](t, `let n = null; (n?.a.b(1)[2] ?? 1) + (n?.(1).x ?? 1)`, 2)
+	testEvalType[*object.Integer](t, `let a = [0]; let f = func() { a[0] = 1 }; let n = null; n?.b[f()]; a[0]`, 0)
+	testEvalType[*object.Integer](t, `let m = {"a": {"b": 1}}; (m?.a).b`, 1)
+
+	// parentheses end the chain ?. skips
+	if _, ok := testEval(`let n = null; (n?.b).c`).(*object.Error); !ok {
+		t.Errorf("expected indexing null to be an error")
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	testEvalType[*object.Integer](t, "const f = func() { const a = 1; a }; const a = 2; f() + a", 3)
+	testEvalType[*object.Integer](t, "let s = 0; for (i, v in [1, 2, 3]) { const x = v; s += x }; s", 6)
+
+	if evaluated := testEval("const a = 1;\na += 1"); evaluated.Type() != object.ERROR_OBJ {
+		t.Errorf("expected an error, got=%s", evaluated.Inspect())
+	}
+}
+
+func TestJSON(t *testing.T) {
+	testEvalType[*object.String](t, `json.stringify({"b": [1, 2.5], "a": "x"})`, `{"b":[1,2.5],"a":"x"}`)
+	testEvalType[*object.Integer](t, `let m = json.parse("{\"a\": {\"b\": 2}}"); m.a.c = 3; m.a.b + m.a.c`, 5)
+}
+
+func TestOSArgs(t *testing.T) {
+	testEvalInteger(t, "len(os.args)", 0)
+
+	program := parser.New(lexer.New(`let a = os.args; a[0] = "x"; join(os.args, ",")`, nil)).ParseProgram()
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	machine := New(comp.Bytecode())
+	machine.SetArgs([]string{"a", "b"})
+	testStringObject(t, machine.Run(), "a,b")
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "len() takes 1 argument, 2 given"},
+		{`len([1, 2, 3])`, 3},
+		{`len([])`, 0},
+		{`push([], 1)`, []int{1}},
+		{`push(1, 1)`, "'array' argument to `push` must be ARRAY, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if _, ok := evaluated.(*object.Error); !ok {
+				t.Errorf("object is not Error. got=%T (%+v)",
+					evaluated, evaluated)
+				continue
+			}
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d",
+					len(expected), len(array.Elements))
+				continue
+			}
+
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElem))
+			}
+		}
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d",
+			len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			"[1, 2, 3][0]",
+			1,
+		},
+		{
+			"[1, 2, 3][1]",
+			2,
+		},
+		{
+			"[1, 2, 3][2]",
+			3,
+		},
+		{
+			"let i = 0; [1][i];",
+			1,
+		},
+		{
+			"[1, 2, 3][1 + 1];",
+			3,
+		},
+		{
+			"let myArray = [1, 2, 3]; myArray[2];",
+			3,
+		},
+		{
+			"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];",
+			6,
+		},
+		{
+			"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]",
+			2,
+		},
+		{
+			"[1, 2, 3][3]",
+			nil,
+		},
+		{
+			"[1, 2, 3][-1]",
+			3,
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			if _, ok := evaluated.(*object.Error); !ok {
+				t.Fatalf("expected evaluated to be object.Error, got %T", evaluated)
+			}
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		evaluator.TRUE.HashKey():                   5,
+		evaluator.FALSE.HashKey():                  6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			`{"foo": 5}["foo"]`,
+			5,
+		},
+		{
+			`{"foo": 5}["bar"]`,
+			nil,
+		},
+		{
+			`let key = "foo"; {"foo": 5}[key]`,
+			5,
+		},
+		{
+			`{}["foo"]`,
+			nil,
+		},
+		{
+			`{5: 5}[5]`,
+			5,
+		},
+		{
+			`{true: 5}[true]`,
+			5,
+		},
+		{
+			`{false: 5}[false]`,
+			5,
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"Icheka"[0]`, "I"},
+		{`""[0]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if _, ok := tt.expected.(string); ok {
+			testStringObject(t, evaluated, tt.expected)
+			return
+		}
+		testNullObject(t, evaluated)
+	}
+}
+
+func testStringObject(t *testing.T, obj object.Object, expected interface{}) bool {
+	result, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("object is not String, got %T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value, got %s, expected %s", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testEval(input string) object.Object {
+	l := lexer.New(input, nil)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	evaluator.InitStdlib()
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return &object.Error{Conf: err}
+	}
+
+	return New(comp.Bytecode()).Run()
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d",
+			result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	if result, ok := obj.(*object.Float); ok {
+		if result.Value != expected {
+			t.Errorf("object has wrong value. got=%f, want=%f",
+				result.Value, expected)
+			return false
+		}
+		return true
+	}
+
+	t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+	return false
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%t, want=%t",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != evaluator.NULL {
+		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+		return false
+	}
+	return true
+}
+
+func testEvalInteger(t *testing.T, input string, expected int) bool {
+	evaluated := testEval(input)
+	obj, ok := evaluated.(*object.Integer)
+	if !ok {
+		t.Errorf("expected evaluated to be INTEGER, got=%s", evaluated.Type())
+		return false
+	}
+	if obj.Value != int64(expected) {
+		t.Errorf("expected obj.Value to be %d, got=%d", expected, obj.Value)
+		return false
+	}
+	return true
+}
+
+func testEvalFloat(t *testing.T, input string, expected float64) bool {
+	evaluated := testEval(input)
+	obj, ok := evaluated.(*object.Float)
+	if !ok {
+		t.Errorf("expected evaluated to be FLOAT, got=%s", evaluated.Type())
+		return false
+	}
+	if obj.Value != expected {
+		t.Errorf("expected obj.Value to be %f, got=%f", expected, obj.Value)
+		return false
+	}
+	return true
+}
+
+func testEvalType[Type *object.Integer | *object.Float | *object.Boolean | *object.String | *object.Closure | *object.Builtin | *object.Array | *object.Hash, Expected int | string | bool](t *testing.T, input string, expected Expected) bool {
+	evaluated := testEval(input)
+	_, ok := evaluated.(*object.Error)
+	if ok {
+		t.Errorf("expected evaluated to be T, got=%s(%+v)", evaluated.Type(), evaluated)
+		return false
+	}
+
+	if evaluated.Type() == object.INTEGER_OBJ {
+		return testEvalInteger(t, input, any(expected).(int))
+	}
+	if evaluated.Type() == object.FLOAT_OBJ {
+		return testEvalFloat(t, input, any(expected).(float64))
+	}
+
+	compareValue := any(expected).(string)
+	var passed bool
+
+	switch evaluated.Type() {
+	case object.BOOLEAN_OBJ:
+		b := any(evaluated).(*object.Boolean)
+		passed = b.Inspect() == compareValue
+
+	case object.STRING_OBJ:
+		s := any(evaluated).(*object.String)
+		passed = s.Inspect() == compareValue
+
+	case object.FUNCTION_OBJ:
+		compareValue = utils.StripWhitespace(compareValue)
+		f := evaluated
+		inspected := utils.StripWhitespace(f.Inspect())
+		passed = inspected == compareValue
+
+	case object.ARRAY_OBJ:
+		a := any(evaluated).(*object.Array)
+		passed = a.Inspect() == compareValue
+
+	case object.HASH_OBJ:
+		h := any(evaluated).(*object.Hash)
+		passed = h.Inspect() == compareValue
+	}
+
+	if !passed {
+		t.Fatalf("%s is not equal to %s", evaluated.Inspect(), compareValue)
+		return false
+	}
+	return true
+}
+
+func TestEvalArrayInfixExpression(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedValue string
+	}{
+		{"[1,2,3,4,5] + [6,7,8]", "[1, 2, 3, 4, 5, 6, 7, 8]"},
+		{"[1,2,3,4,5,6,7] / 2", "[[1, 2], [3, 4], [5, 6], [7]]"},
+		{"[1,2] - 0", "[2]"},
+		{"[1,2] * 2", "[[1, 2], [1, 2]]"},
+	}
+
+	for _, tt := range tests {
+		testEvalType[*object.Array](t, tt.input, tt.expectedValue)
+	}
+
+	tests = []struct {
+		input         string
+		expectedValue string
+	}{
+		{"[1] == [1]", "true"},
+		{"[1] == [2]", "false"},
+		{"[1] != [2]", "true"},
+		{"[1] != [1]", "false"},
+	}
+
+	for _, tt := range tests {
+		testEvalType[*object.Boolean](t, tt.input, tt.expectedValue)
+	}
+}
+
+func TestHigherOrderBuiltins(t *testing.T) {
+	testEvalType[*object.Array](t, `let k = 3; map([1, 2], func(x) { x * k })`, `[3, 6]`)
+	testEvalType[*object.Array](t, `filter([1, 2, 3, 4], func(x, i) { i > 1 })`, `[3, 4]`)
+	testEvalType[*object.Integer](t, `reduce([1, 2, 3], func(acc, x) { acc + x }, 10)`, 16)
+	testEvalType[*object.Array](t, `sort([3, 1, 2], func(a, b) { b - a })`, `[3, 2, 1]`)
+	testEvalType[*object.Array](t, `map([[1, 2], [3]], func(xs) { reduce(xs, func(a, b) { a + b }) })`, `[3, 3]`)
+
+	// the stack is left as it was, so the program carries on after the call
+	testEvalType[*object.Integer](t, `let f = func(n) { n + reduce([1, 2], func(a, b) { a + b }) }; f(1) + f(2)`, 9)
+
+	if evaluated := testEval(`map([1], func(x) { x + "a" })`); evaluated.Type() != object.ERROR_OBJ {
+		t.Errorf("expected an error, got=%s", evaluated.Inspect())
+	}
+}